@@ -1,25 +1,203 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
 	log "github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
+	"github.com/rancher/rancher-auth-service/model"
+	"github.com/rancher/rancher-auth-service/providers/local"
 	"github.com/rancher/rancher-auth-service/server"
 	"github.com/rancher/rancher-auth-service/service"
-	"net/http"
 )
 
 func main() {
-	server.SetEnv()
-	startService()
+	app := cli.NewApp()
+	app.Name = "rancher-auth-service"
+	app.Usage = "Authenticates the users with the configured auth provider and issues the JWT tokens"
+	app.Flags = server.Flags
+	app.Action = serve
+	app.Commands = []cli.Command{
+		{
+			Name:   "serve",
+			Usage:  "Serve the API, the default command",
+			Action: serve,
+		},
+		{
+			Name:   "reencrypt-settings",
+			Usage:  "Encrypt the provider secrets in the settings with the current settings key",
+			Action: reencryptSettings,
+		},
+		{
+			Name:  "revoke",
+			Usage: "Revoke all the tokens issued to an account",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "account-id",
+					Usage: "account_id of the tokens to revoke",
+				},
+			},
+			Action: revoke,
+		},
+		{
+			Name:  "update-config",
+			Usage: "Load the provider with the JSON auth config of the file and save it, e.g. to configure the first provider",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "file",
+					Usage: "Path of the JSON auth config, as posted to /v1-rancher-auth/config, - reads stdin",
+				},
+			},
+			Action: updateConfig,
+		},
+		{
+			Name:  "create-local-user",
+			Usage: "Add a user to the local provider database, e.g. the first admin before the admin APIs can be used",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "username",
+					Usage: "Username of the local user",
+				},
+				cli.StringFlag{
+					Name:  "name",
+					Usage: "Display name of the local user",
+				},
+				cli.StringFlag{
+					Name:   "password",
+					Usage:  "Password of the local user",
+					EnvVar: "RANCHER_AUTH_LOCAL_USER_PASSWORD",
+				},
+			},
+			Action: createLocalUser,
+		},
+		{
+			Name:   "generate-key",
+			Usage:  "Print a new base64 encoded 32 bytes key for the settings-key-file or the access-token-key-file",
+			Action: generateKey,
+		},
+	}
+	app.Run(os.Args)
 }
 
+func serve(c *cli.Context) {
+	server.SetEnv(c)
+	server.InitTokenSigning()
 
-func startService() {
+	//load the provider saved through the config API, the service stays unconfigured until one is posted
+	if err := server.Reload(); err != nil {
+		log.Warnf("No auth provider loaded at startup, post one to /v1-rancher-auth/config: %v", err)
+	}
 
 	log.Info("Starting Rancher Auth service")
 
 	router := service.NewRouter()
 
-	//log.Info("Listening on ", c.GlobalString("listen"))
-	log.Fatal(http.ListenAndServe(":8090", router))
+	httpServer := &http.Server{
+		Addr:      c.GlobalString("listen"),
+		Handler:   router,
+		TLSConfig: server.NewTLSConfig(),
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if httpServer.TLSConfig != nil {
+			log.Info("Listening with TLS on ", httpServer.Addr)
+			serveErr <- httpServer.ListenAndServeTLS("", "")
+			return
+		}
+		log.Info("Listening on ", httpServer.Addr)
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		log.Fatal(err)
+	case sig := <-stop:
+		//a second signal exits right away
+		signal.Stop(stop)
+		log.Infof("Received %v, draining the in-flight requests for up to %v", sig, c.GlobalDuration("shutdown-timeout"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.GlobalDuration("shutdown-timeout"))
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Errorf("Failed to drain the in-flight requests, closing their connections: %v", err)
+		httpServer.Close()
+	}
+	log.Info("Stopped Rancher Auth service")
+	server.Shutdown()
+}
+
+func reencryptSettings(c *cli.Context) {
+	server.SetEnv(c)
+	count, err := server.ReencryptSettings()
+	if err != nil {
+		log.Fatalf("Failed to re-encrypt the settings: %v", err)
+	}
+	log.Infof("Re-encrypted %d settings", count)
+}
+
+func revoke(c *cli.Context) {
+	server.SetEnv(c)
+	if err := server.RevokeAccount(c.String("account-id")); err != nil {
+		log.Fatalf("Failed to revoke the tokens: %v", err)
+	}
+	log.Infof("Revoked the tokens issued to %v", c.String("account-id"))
+}
+
+func updateConfig(c *cli.Context) {
+	server.SetEnv(c)
+	var data []byte
+	var err error
+	switch c.String("file") {
+	case "":
+		log.Fatal("Please provide the file of the auth config, halting")
+	case "-":
+		data, err = ioutil.ReadAll(os.Stdin)
+	default:
+		data, err = ioutil.ReadFile(c.String("file"))
+	}
+	if err != nil {
+		log.Fatalf("Failed to read the auth config: %v", err)
+	}
+	var authConfig model.AuthConfig
+	if err := json.Unmarshal(data, &authConfig); err != nil {
+		log.Fatalf("Failed to parse the auth config: %v", err)
+	}
+	if err := server.UpdateConfig(authConfig); err != nil {
+		log.Fatalf("Failed to update the auth config: %v", err)
+	}
+	log.Infof("Updated the auth config of the %v provider, the running instances pick it up on restart or /reload", authConfig.Provider)
+}
+
+func createLocalUser(c *cli.Context) {
+	server.SetEnv(c)
+	user, err := local.CreateUser(model.LocalUser{
+		Username: c.String("username"),
+		Name:     c.String("name"),
+		Password: c.String("password"),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create the local user: %v", err)
+	}
+	log.Infof("Created the local user %v, list local_user:%v in the admin-identities to make it an admin", user.Username, user.Username)
+}
 
+func generateKey(c *cli.Context) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("Failed to generate the key: %v", err)
+	}
+	fmt.Println(base64.StdEncoding.EncodeToString(key))
 }
@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "rancher_auth"
+)
+
+//Results of the token requests
+const (
+	ResultSuccess = "success"
+	ResultDenied  = "denied"
+	ResultError   = "error"
+)
+
+var (
+	tokenRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_requests_total",
+		Help:      "Token creations and refreshes by provider and result.",
+	}, []string{"operation", "provider", "result"})
+
+	tokenRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "token_request_duration_seconds",
+		Help:      "Latency of the token creations and refreshes, including the provider calls.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "provider", "result"})
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "API requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the API requests by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	providerRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_requests_total",
+		Help:      "Outbound requests to the auth providers by status code, the code is error when no response was received.",
+	}, []string{"provider", "method", "code"})
+
+	providerRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "provider_request_duration_seconds",
+		Help:      "Latency of the outbound requests to the auth providers.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"provider", "method"})
+
+	cattleSettingErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cattle_setting_errors_total",
+		Help:      "Failed reads and writes of the Cattle settings.",
+	}, []string{"operation"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		tokenRequests,
+		tokenRequestDuration,
+		httpRequests,
+		httpRequestDuration,
+		providerRequests,
+		providerRequestDuration,
+		cattleSettingErrors,
+	)
+}
+
+//Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+//ObserveTokenRequest records a token creation or refresh started at start
+func ObserveTokenRequest(operation string, provider string, result string, start time.Time) {
+	tokenRequests.WithLabelValues(operation, provider, result).Inc()
+	tokenRequestDuration.WithLabelValues(operation, provider, result).Observe(time.Since(start).Seconds())
+}
+
+//ObserveProviderRequest records an outbound request to a provider, resp is nil when the request failed
+func ObserveProviderRequest(provider string, method string, resp *http.Response, start time.Time) {
+	code := "error"
+	if resp != nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	providerRequests.WithLabelValues(provider, method, code).Inc()
+	providerRequestDuration.WithLabelValues(provider, method).Observe(time.Since(start).Seconds())
+}
+
+//CountCattleSettingError records a failed read or write of a Cattle setting
+func CountCattleSettingError(operation string) {
+	cattleSettingErrors.WithLabelValues(operation).Inc()
+}
+
+//InstrumentRoute records the requests of the handler under the route label, the path pattern of the route
+func InstrumentRoute(route string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(recorder, r)
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Inc()
+		httpRequestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+//statusRecorder keeps the status code written by the handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.status = status
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
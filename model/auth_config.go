@@ -10,4 +10,12 @@ type AuthConfig struct {
 	AccessMode string `json:"accessMode"`
 	AllowedIdentities []client.Identity `json:"allowedIdentities"`
 	GithubConfig GithubConfig `json:"githubConfig"`
+	GitlabConfig GitlabConfig `json:"gitlabConfig"`
+	BitbucketConfig BitbucketConfig `json:"bitbucketConfig"`
+	LdapConfig LdapConfig `json:"ldapConfig"`
+	OpenLdapConfig OpenLdapConfig `json:"openLdapConfig"`
+	OIDCConfig OIDCConfig `json:"oidcConfig"`
+	SamlConfig SamlConfig `json:"samlConfig"`
+	AzureADConfig AzureADConfig `json:"azureadConfig"`
+	FileConfig FileConfig `json:"fileConfig"`
 }
\ No newline at end of file
@@ -0,0 +1,17 @@
+package model
+
+import "github.com/rancher/go-rancher/client"
+
+//AzureADConfig stores the Azure AD config, it extends the azureadconfig schema of the go-rancher client
+//with the endpoints so a Graph-compatible stand-in can be used instead of Azure
+type AzureADConfig struct {
+	client.Azureadconfig
+	//AdminAccountPassword shadows the field of client.Azureadconfig to tag it as a secret
+	AdminAccountPassword string `json:"adminAccountPassword,omitempty" secret:"true"`
+	ClientSecret         string `json:"clientSecret,omitempty" secret:"true"`
+	RedirectURL          string `json:"redirectUrl,omitempty"`
+	AuthorityURL         string `json:"authorityUrl,omitempty" endpoint:"true"`
+	GraphURL             string `json:"graphUrl,omitempty" endpoint:"true"`
+	//PasswordLogin makes the token requests post the user credentials as username:password instead of an authorization code
+	PasswordLogin bool `json:"passwordLogin"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import "github.com/rancher/go-rancher/client"
+
+//BitbucketConfig stores the bitbucket config, Bitbucket Cloud is used unless Hostname points to a Bitbucket Server
+type BitbucketConfig struct {
+	client.Resource
+	Hostname     string `json:"hostname,omitempty" endpoint:"true"`
+	Scheme       string `json:"scheme,omitempty" endpoint:"true"`
+	ClientID     string `json:"clientId,omitempty"`
+	ClientSecret string `json:"clientSecret,omitempty" secret:"true"`
+	RedirectURL  string `json:"redirectUrl,omitempty"`
+	//ServiceAccountToken is a Bitbucket Server HTTP access token of an admin account, reading the group memberships needs admin permission
+	ServiceAccountToken string `json:"serviceAccountToken,omitempty" secret:"true"`
+}
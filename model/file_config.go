@@ -0,0 +1,10 @@
+package model
+
+import "github.com/rancher/go-rancher/client"
+
+//FileConfig stores the paths of the htpasswd and YAML groups files of the file provider
+type FileConfig struct {
+	client.Resource
+	HtpasswdFile string `json:"htpasswdFile,omitempty"`
+	GroupsFile   string `json:"groupsFile,omitempty"`
+}
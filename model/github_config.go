@@ -5,8 +5,8 @@ import "github.com/rancher/go-rancher/client"
 //GithubConfig stores the github config read from JSON file
 type GithubConfig struct {
 	client.Resource
-	Hostname     string `json:"hostname,omitempty"`
-	Scheme       string `json:"scheme,omitempty"`
+	Hostname     string `json:"hostname,omitempty" endpoint:"true"`
+	Scheme       string `json:"scheme,omitempty" endpoint:"true"`
 	ClientID     string `json:"clientId,omitempty"`
-	ClientSecret string `json:"clientSecret,omitempty"`
+	ClientSecret string `json:"clientSecret,omitempty" secret:"true"`
 }
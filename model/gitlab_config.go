@@ -0,0 +1,13 @@
+package model
+
+import "github.com/rancher/go-rancher/client"
+
+//GitlabConfig stores the gitlab config, Hostname and Scheme point to a self-managed GitLab
+type GitlabConfig struct {
+	client.Resource
+	Hostname     string `json:"hostname,omitempty" endpoint:"true"`
+	Scheme       string `json:"scheme,omitempty" endpoint:"true"`
+	ClientID     string `json:"clientId,omitempty"`
+	ClientSecret string `json:"clientSecret,omitempty" secret:"true"`
+	RedirectURL  string `json:"redirectUrl,omitempty"`
+}
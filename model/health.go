@@ -0,0 +1,22 @@
+package model
+
+//Status of a readiness check
+const (
+	CheckOK      = "ok"
+	CheckFailed  = "failed"
+	CheckSkipped = "skipped"
+)
+
+//HealthCheck is the result of one of the readiness checks
+type HealthCheck struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Message  string `json:"message,omitempty"`
+	Duration string `json:"duration,omitempty"`
+}
+
+//Readiness is the /readyz response, the status is ok when none of the checks failed
+type Readiness struct {
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"github.com/rancher/go-rancher/client"
+)
+
+//IntrospectionResponse is the RFC 7662 token introspection response, only active is set for an invalid token
+type IntrospectionResponse struct {
+	Active     bool              `json:"active"`
+	Scope      string            `json:"scope,omitempty"`
+	ClientID   string            `json:"client_id,omitempty"`
+	Username   string            `json:"username,omitempty"`
+	TokenType  string            `json:"token_type,omitempty"`
+	Exp        int64             `json:"exp,omitempty"`
+	Iat        int64             `json:"iat,omitempty"`
+	Nbf        int64             `json:"nbf,omitempty"`
+	Sub        string            `json:"sub,omitempty"`
+	Aud        string            `json:"aud,omitempty"`
+	Iss        string            `json:"iss,omitempty"`
+	Jti        string            `json:"jti,omitempty"`
+	IDList     []string          `json:"idList,omitempty"`
+	Identities []client.Identity `json:"identities,omitempty"`
+}
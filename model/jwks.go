@@ -0,0 +1,16 @@
+package model
+
+//JSONWebKey is the RFC 7517 representation of a RSA public key verifying the tokens
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+//JSONWebKeySet is the document published at /.well-known/jwks.json
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
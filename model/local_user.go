@@ -0,0 +1,30 @@
+package model
+
+import "github.com/rancher/go-rancher/client"
+
+//LocalUser is a user of the local provider, Password is only read on create and update and never returned
+type LocalUser struct {
+	client.Resource
+	Username string `json:"username,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+//LocalUserCollection is the collection of the local users
+type LocalUserCollection struct {
+	client.Collection
+	Data []LocalUser `json:"data,omitempty"`
+}
+
+//LocalGroup is a group of the local provider, Members lists the usernames of the group
+type LocalGroup struct {
+	client.Resource
+	Name    string   `json:"name,omitempty"`
+	Members []string `json:"members,omitempty"`
+}
+
+//LocalGroupCollection is the collection of the local groups
+type LocalGroupCollection struct {
+	client.Collection
+	Data []LocalGroup `json:"data,omitempty"`
+}
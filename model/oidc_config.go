@@ -0,0 +1,17 @@
+package model
+
+import "github.com/rancher/go-rancher/client"
+
+//OIDCConfig stores the OpenID Connect provider config
+type OIDCConfig struct {
+	client.Resource
+	Issuer        string `json:"issuer,omitempty" endpoint:"true"`
+	ClientID      string `json:"clientId,omitempty"`
+	ClientSecret  string `json:"clientSecret,omitempty" secret:"true"`
+	RedirectURL   string `json:"redirectUrl,omitempty"`
+	Scopes        string `json:"scopes,omitempty"`
+	UserIDClaim   string `json:"userIdClaim,omitempty"`
+	UsernameClaim string `json:"usernameClaim,omitempty"`
+	NameClaim     string `json:"nameClaim,omitempty"`
+	GroupsClaim   string `json:"groupsClaim,omitempty"`
+}
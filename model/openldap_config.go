@@ -0,0 +1,27 @@
+package model
+
+import "github.com/rancher/go-rancher/client"
+
+//OpenLdapConfig stores the OpenLDAP config, it shares its fields with LdapConfig
+type OpenLdapConfig struct {
+	client.Resource
+	Server                      string `json:"server,omitempty" endpoint:"true"`
+	Port                        int64  `json:"port,omitempty" endpoint:"true"`
+	TLS                         bool   `json:"tls,omitempty" endpoint:"true"`
+	Domain                      string `json:"domain,omitempty"`
+	LoginDomain                 string `json:"loginDomain,omitempty"`
+	ConnectionTimeout           int64  `json:"connectionTimeout,omitempty"`
+	ServiceAccountUsername      string `json:"serviceAccountUsername,omitempty"`
+	ServiceAccountPassword      string `json:"serviceAccountPassword,omitempty" secret:"true"`
+	UserSearchField             string `json:"userSearchField,omitempty"`
+	UserLoginField              string `json:"userLoginField,omitempty"`
+	UserObjectClass             string `json:"userObjectClass,omitempty"`
+	UserNameField               string `json:"userNameField,omitempty"`
+	UserEnabledAttribute        string `json:"userEnabledAttribute,omitempty"`
+	UserDisabledBitMask         int64  `json:"userDisabledBitMask,omitempty"`
+	UserMemberAttribute         string `json:"userMemberAttribute,omitempty"`
+	GroupSearchField            string `json:"groupSearchField,omitempty"`
+	GroupObjectClass            string `json:"groupObjectClass,omitempty"`
+	GroupNameField              string `json:"groupNameField,omitempty"`
+	GroupMemberMappingAttribute string `json:"groupMemberMappingAttribute,omitempty"`
+}
@@ -0,0 +1,17 @@
+package model
+
+import "github.com/rancher/go-rancher/client"
+
+//SamlConfig stores the SAML 2.0 service provider config
+type SamlConfig struct {
+	client.Resource
+	IDPMetadataURL     string `json:"idpMetadataUrl,omitempty" endpoint:"true"`
+	IDPMetadataContent string `json:"idpMetadataContent,omitempty" endpoint:"true"`
+	SPCert             string `json:"spCert,omitempty"`
+	SPKey              string `json:"spKey,omitempty" secret:"true"`
+	RancherAPIHost     string `json:"rancherApiHost,omitempty"`
+	UIDField           string `json:"uidField,omitempty"`
+	DisplayNameField   string `json:"displayNameField,omitempty"`
+	UserNameField      string `json:"userNameField,omitempty"`
+	GroupsField        string `json:"groupsField,omitempty"`
+}
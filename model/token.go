@@ -10,4 +10,18 @@ type Token struct {
 	ExternalAccountID   string
 	IdentityList []client.Identity
 	AccessToken string
-}
\ No newline at end of file
+}
+
+//TokenInfo is the verified content of a jwt token issued by the service
+type TokenInfo struct {
+	client.Resource
+	AccountID  string            `json:"accountId"`
+	TokenType  string            `json:"tokenType"`
+	Issuer     string            `json:"issuer"`
+	Audience   string            `json:"audience,omitempty"`
+	TokenID    string            `json:"tokenId"`
+	IssuedAt   string            `json:"issuedAt"`
+	ExpiresAt  string            `json:"expiresAt"`
+	IDList     []string          `json:"idList"`
+	Identities []client.Identity `json:"identities"`
+}
@@ -0,0 +1,55 @@
+package azuread
+
+import (
+	"github.com/rancher/go-rancher/client"
+)
+
+//Account defines properties a user or group in Azure AD has
+type Account struct {
+	ID    string
+	Login string
+	Name  string
+}
+
+func (a *Account) toIdentity(externalIDType string, identity *client.Identity) {
+	identity.ExternalId = a.ID
+	identity.Resource.Id = externalIDType + ":" + a.ID
+	identity.ExternalIdType = externalIDType
+	if a.Name != "" {
+		identity.Name = a.Name
+	} else {
+		identity.Name = a.Login
+	}
+	identity.Login = a.Login
+}
+
+//graphUser is a user object of the Graph API
+type graphUser struct {
+	ID                string `json:"id"`
+	DisplayName       string `json:"displayName"`
+	UserPrincipalName string `json:"userPrincipalName"`
+	Mail              string `json:"mail"`
+}
+
+func (u *graphUser) toAccount() Account {
+	login := u.UserPrincipalName
+	if login == "" {
+		login = u.Mail
+	}
+	return Account{ID: u.ID, Login: login, Name: u.DisplayName}
+}
+
+//graphGroup is a group object of the Graph API
+type graphGroup struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+	Mail        string `json:"mail"`
+}
+
+func (g *graphGroup) toAccount() Account {
+	login := g.DisplayName
+	if login == "" {
+		login = g.Mail
+	}
+	return Account{ID: g.ID, Login: login, Name: g.DisplayName}
+}
@@ -0,0 +1,290 @@
+package azuread
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+const (
+	graphVersion    = "/v1.0"
+	userSelect      = "id,displayName,userPrincipalName,mail"
+	groupSelect     = "id,displayName,mail"
+	tokenExpiryLeap = 60 * time.Second
+)
+
+//tokenResponse is the response of the authority token endpoint
+type tokenResponse struct {
+	AccessToken      string      `json:"access_token"`
+	TokenType        string      `json:"token_type"`
+	ExpiresIn        json.Number `json:"expires_in"`
+	Error            string      `json:"error"`
+	ErrorDescription string      `json:"error_description"`
+}
+
+//AzClient implements a httpclient for the Azure AD authority and the Graph API
+type AzClient struct {
+	httpClient *http.Client
+	config     *model.AzureADConfig
+
+	mutex            sync.Mutex
+	adminToken       string
+	adminTokenExpiry time.Time
+}
+
+func (a *AzClient) tokenURL() string {
+	return strings.TrimSuffix(a.config.AuthorityURL, "/") + "/" + url.QueryEscape(a.config.TenantId) + "/oauth2/v2.0/token"
+}
+
+func (a *AzClient) graphURL(path string) string {
+	return strings.TrimSuffix(a.config.GraphURL, "/") + graphVersion + path
+}
+
+func (a *AzClient) scope() string {
+	return strings.TrimSuffix(a.config.GraphURL, "/") + "/.default"
+}
+
+//qualifiedUsername adds the configured domain to a bare username
+func (a *AzClient) qualifiedUsername(username string) string {
+	if a.config.Domain != "" && !strings.Contains(username, "@") {
+		return username + "@" + a.config.Domain
+	}
+	return username
+}
+
+//exchangeCode performs the authorization code exchange with the authority
+func (a *AzClient) exchangeCode(code string) (tokenResponse, error) {
+	form := url.Values{}
+	form.Add("grant_type", "authorization_code")
+	form.Add("code", code)
+	if a.config.RedirectURL != "" {
+		form.Add("redirect_uri", a.config.RedirectURL)
+	}
+	return a.requestToken(form)
+}
+
+//passwordToken gets a token for the user credentials with the resource owner password grant
+func (a *AzClient) passwordToken(username string, password string) (tokenResponse, error) {
+	form := url.Values{}
+	form.Add("grant_type", "password")
+	form.Add("username", a.qualifiedUsername(username))
+	form.Add("password", password)
+	return a.requestToken(form)
+}
+
+func (a *AzClient) requestToken(form url.Values) (tokenResponse, error) {
+	form.Add("client_id", a.config.ClientId)
+	if a.config.ClientSecret != "" {
+		form.Add("client_secret", a.config.ClientSecret)
+	}
+	form.Add("scope", a.scope())
+
+	req, err := http.NewRequest("POST", a.tokenURL(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokenResponse{}, err
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Accept", "application/json")
+
+	var token tokenResponse
+	if err := a.doJSON(req, &token); err != nil {
+		log.Errorf("AzureAD requestToken: received error from the authority, err: %v", err)
+		return tokenResponse{}, err
+	}
+	if token.Error != "" {
+		return tokenResponse{}, fmt.Errorf("Received Error from Azure AD %v, description %v", token.Error, token.ErrorDescription)
+	}
+	if token.AccessToken == "" {
+		return tokenResponse{}, fmt.Errorf("No access_token received from Azure AD")
+	}
+	return token, nil
+}
+
+//getAdminToken returns a Graph token of the admin account, used for lookups made without a user token
+func (a *AzClient) getAdminToken() (string, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.adminToken != "" && time.Now().Before(a.adminTokenExpiry) {
+		return a.adminToken, nil
+	}
+	if a.config.AdminAccountUsername == "" || a.config.AdminAccountPassword == "" {
+		return "", fmt.Errorf("No Azure AD admin account configured")
+	}
+
+	token, err := a.passwordToken(a.config.AdminAccountUsername, a.config.AdminAccountPassword)
+	if err != nil {
+		return "", err
+	}
+	expiresIn, err := token.ExpiresIn.Int64()
+	if err != nil || expiresIn <= 0 {
+		expiresIn = 3600
+	}
+	a.adminToken = token.AccessToken
+	a.adminTokenExpiry = time.Now().Add(time.Duration(expiresIn)*time.Second - tokenExpiryLeap)
+	return a.adminToken, nil
+}
+
+//lookupToken returns the user token when present else the admin account token
+func (a *AzClient) lookupToken(accessToken string) (string, error) {
+	if accessToken != "" {
+		return accessToken, nil
+	}
+	return a.getAdminToken()
+}
+
+func (a *AzClient) getMe(accessToken string) (Account, error) {
+	var user graphUser
+	if err := a.getJSON(a.graphURL("/me?$select="+userSelect), accessToken, &user); err != nil {
+		log.Errorf("AzureAD getMe: received error from the Graph API, err: %v", err)
+		return Account{}, err
+	}
+	return user.toAccount(), nil
+}
+
+//getTransitiveGroups returns the groups the user is a member of, directly or through nested groups
+func (a *AzClient) getTransitiveGroups(accessToken string) ([]Account, error) {
+	var groups []Account
+	nextURL := a.graphURL("/me/transitiveMemberOf/microsoft.graph.group?$select=" + groupSelect)
+	for nextURL != "" {
+		var page struct {
+			Value    []graphGroup `json:"value"`
+			NextLink string       `json:"@odata.nextLink"`
+		}
+		if err := a.getJSON(nextURL, accessToken, &page); err != nil {
+			log.Errorf("AzureAD getTransitiveGroups: received error from the Graph API, err: %v", err)
+			return nil, err
+		}
+		for _, group := range page.Value {
+			groups = append(groups, group.toAccount())
+		}
+		if page.NextLink != "" && !a.isGraphURL(page.NextLink) {
+			return nil, fmt.Errorf("The next page link %v is not on the Graph API %v", page.NextLink, a.config.GraphURL)
+		}
+		nextURL = page.NextLink
+	}
+	return groups, nil
+}
+
+//isGraphURL tells if the link is on the scheme and host of the Graph API, so that the token is only sent there
+func (a *AzClient) isGraphURL(link string) bool {
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	graphURL, err := url.Parse(a.config.GraphURL)
+	if err != nil {
+		return false
+	}
+	return linkURL.Scheme == graphURL.Scheme && strings.EqualFold(linkURL.Host, graphURL.Host)
+}
+
+func (a *AzClient) getUser(id string, accessToken string) (Account, error) {
+	var user graphUser
+	if err := a.getJSON(a.graphURL("/users/"+url.QueryEscape(id)+"?$select="+userSelect), accessToken, &user); err != nil {
+		log.Errorf("AzureAD getUser: received error from the Graph API, err: %v", err)
+		return Account{}, err
+	}
+	return user.toAccount(), nil
+}
+
+func (a *AzClient) getGroup(id string, accessToken string) (Account, error) {
+	var group graphGroup
+	if err := a.getJSON(a.graphURL("/groups/"+url.QueryEscape(id)+"?$select="+groupSelect), accessToken, &group); err != nil {
+		log.Errorf("AzureAD getGroup: received error from the Graph API, err: %v", err)
+		return Account{}, err
+	}
+	return group.toAccount(), nil
+}
+
+func (a *AzClient) searchUsers(name string, exactMatch bool, accessToken string) ([]Account, error) {
+	filter := matchFilter(name, exactMatch, "displayName", "userPrincipalName", "mail")
+	var page struct {
+		Value []graphUser `json:"value"`
+	}
+	if err := a.getJSON(a.graphURL("/users?$select="+userSelect+"&$filter="+url.QueryEscape(filter)), accessToken, &page); err != nil {
+		log.Errorf("AzureAD searchUsers: received error from the Graph API, err: %v", err)
+		return nil, err
+	}
+	var accounts []Account
+	for _, user := range page.Value {
+		accounts = append(accounts, user.toAccount())
+	}
+	return accounts, nil
+}
+
+func (a *AzClient) searchGroups(name string, exactMatch bool, accessToken string) ([]Account, error) {
+	filter := matchFilter(name, exactMatch, "displayName", "mail")
+	var page struct {
+		Value []graphGroup `json:"value"`
+	}
+	if err := a.getJSON(a.graphURL("/groups?$select="+groupSelect+"&$filter="+url.QueryEscape(filter)), accessToken, &page); err != nil {
+		log.Errorf("AzureAD searchGroups: received error from the Graph API, err: %v", err)
+		return nil, err
+	}
+	var accounts []Account
+	for _, group := range page.Value {
+		accounts = append(accounts, group.toAccount())
+	}
+	return accounts, nil
+}
+
+//matchFilter builds the OData filter matching the name on any of the fields
+func matchFilter(name string, exactMatch bool, fields ...string) string {
+	value := "'" + strings.Replace(name, "'", "''", -1) + "'"
+	var clauses []string
+	for _, field := range fields {
+		if exactMatch {
+			clauses = append(clauses, field+" eq "+value)
+		} else {
+			clauses = append(clauses, "startswith("+field+","+value+")")
+		}
+	}
+	return strings.Join(clauses, " or ")
+}
+
+func (a *AzClient) getJSON(url string, accessToken string, v interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+	req.Header.Add("Accept", "application/json")
+	return a.doJSON(req, v)
+}
+
+func (a *AzClient) doJSON(req *http.Request, v interface{}) error {
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Check the status code
+	switch resp.StatusCode {
+	case 200:
+	case 201:
+	default:
+		var body bytes.Buffer
+		io.Copy(&body, resp.Body)
+		return fmt.Errorf("Request failed, got status code: %d. Response: %s",
+			resp.StatusCode, body.Bytes())
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
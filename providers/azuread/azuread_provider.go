@@ -0,0 +1,290 @@
+package azuread
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/go-rancher/client"
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+//Constants for azuread
+const (
+	Name                        = "azuread"
+	Config                      = Name + "config"
+	TokenType                   = Name + "jwt"
+	UserType                    = Name + "_user"
+	GroupType                   = Name + "_group"
+	defaultAuthorityURL         = "https://login.microsoftonline.com"
+	defaultGraphURL             = "https://graph.microsoft.com"
+	tenantIDSetting             = "api.auth.azuread.tenant.id"
+	clientIDSetting             = "api.auth.azuread.client.id"
+	clientSecretSetting         = "api.auth.azuread.client.secret"
+	domainSetting               = "api.auth.azuread.domain"
+	adminAccountUsernameSetting = "api.auth.azuread.admin.username"
+	adminAccountPasswordSetting = "api.auth.azuread.admin.password"
+	redirectURLSetting          = "api.auth.azuread.redirect.url"
+	authorityURLSetting         = "api.auth.azuread.authority.url"
+	graphURLSetting             = "api.auth.azuread.graph.url"
+	passwordLoginSetting        = "api.auth.azuread.password.login"
+)
+
+//InitializeProvider returns a new instance of the provider
+func InitializeProvider() *AzProvider {
+	azureClient := &AzClient{}
+	azureClient.httpClient = &http.Client{}
+
+	azureProvider := &AzProvider{}
+	azureProvider.azureClient = azureClient
+
+	return azureProvider
+}
+
+//AzProvider implements an IdentityProvider for Azure AD
+type AzProvider struct {
+	azureClient *AzClient
+}
+
+//GetName returns the name of the provider
+func (a *AzProvider) GetName() string {
+	return Name
+}
+
+//GetEndpoint returns the url of the authority token endpoint
+func (a *AzProvider) GetEndpoint() string {
+	return a.azureClient.tokenURL()
+}
+
+//GenerateToken authenticates with Azure AD and returns the token, the securityCode is the authorization code,
+//or the user credentials in the form username:password when the passwordLogin is enabled
+func (a *AzProvider) GenerateToken(securityCode string) (model.Token, error) {
+	log.Debug("AzureADIdentityProvider GenerateToken called")
+	var tokenResp tokenResponse
+	var err error
+	if a.azureClient.config.PasswordLogin {
+		parts := strings.SplitN(securityCode, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return model.Token{}, fmt.Errorf("Invalid credentials, expected username:password")
+		}
+		tokenResp, err = a.azureClient.passwordToken(parts[0], parts[1])
+	} else {
+		tokenResp, err = a.azureClient.exchangeCode(securityCode)
+	}
+	if err != nil {
+		log.Errorf("Error getting the token from Azure AD %v", err)
+		return model.Token{}, err
+	}
+	return a.createToken(tokenResp.AccessToken)
+}
+
+func (a *AzProvider) createToken(accessToken string) (model.Token, error) {
+	var token model.Token
+	user, err := a.azureClient.getMe(accessToken)
+	if err != nil {
+		return token, err
+	}
+	groups, err := a.azureClient.getTransitiveGroups(accessToken)
+	if err != nil {
+		return token, err
+	}
+
+	token.AccessToken = accessToken
+	token.IdentityList = toIdentities(user, groups)
+	token.Type = TokenType
+	token.ExternalAccountID = user.ID
+	return token, nil
+}
+
+func toIdentities(user Account, groups []Account) []client.Identity {
+	var identities []client.Identity
+
+	userIdentity := client.Identity{Resource: client.Resource{
+		Type: "identity",
+	}}
+	user.toIdentity(UserType, &userIdentity)
+	identities = append(identities, userIdentity)
+
+	for _, group := range groups {
+		groupIdentity := client.Identity{Resource: client.Resource{
+			Type: "identity",
+		}}
+		group.toIdentity(GroupType, &groupIdentity)
+		identities = append(identities, groupIdentity)
+	}
+
+	return identities
+}
+
+//RefreshToken re-reads the user and the transitive group membership and generate a new token
+func (a *AzProvider) RefreshToken(accessToken string) (model.Token, error) {
+	log.Debug("AzureADIdentityProvider RefreshToken called")
+	return a.createToken(accessToken)
+}
+
+//GetIdentities returns list of user and group identities associated to this token
+func (a *AzProvider) GetIdentities(accessToken string) ([]client.Identity, error) {
+	user, err := a.azureClient.getMe(accessToken)
+	if err != nil {
+		return []client.Identity{}, err
+	}
+	groups, err := a.azureClient.getTransitiveGroups(accessToken)
+	if err != nil {
+		return []client.Identity{}, err
+	}
+	return toIdentities(user, groups), nil
+}
+
+//GetIdentity returns the identity by externalID and externalIDType, the admin account is used when no token is passed
+func (a *AzProvider) GetIdentity(externalID string, externalIDType string, accessToken string) (client.Identity, error) {
+	identity := client.Identity{Resource: client.Resource{
+		Type: "identity",
+	}}
+
+	lookupToken, err := a.azureClient.lookupToken(accessToken)
+	if err != nil {
+		return identity, err
+	}
+
+	var account Account
+	switch externalIDType {
+	case UserType:
+		account, err = a.azureClient.getUser(externalID, lookupToken)
+	case GroupType:
+		account, err = a.azureClient.getGroup(externalID, lookupToken)
+	default:
+		log.Debugf("Cannot get the azuread account due to invalid externalIDType %v", externalIDType)
+		return identity, fmt.Errorf("Cannot get the azuread account due to invalid externalIDType %v", externalIDType)
+	}
+	if err != nil {
+		return identity, err
+	}
+	account.toIdentity(externalIDType, &identity)
+	return identity, nil
+}
+
+//SearchIdentities returns the user and group identities matching the name
+func (a *AzProvider) SearchIdentities(name string, exactMatch bool, accessToken string) ([]client.Identity, error) {
+	var identities []client.Identity
+
+	lookupToken, err := a.azureClient.lookupToken(accessToken)
+	if err != nil {
+		return identities, err
+	}
+
+	users, err := a.azureClient.searchUsers(name, exactMatch, lookupToken)
+	if err != nil {
+		return identities, err
+	}
+	for _, user := range users {
+		userIdentity := client.Identity{Resource: client.Resource{
+			Type: "identity",
+		}}
+		user.toIdentity(UserType, &userIdentity)
+		identities = append(identities, userIdentity)
+	}
+
+	groups, err := a.azureClient.searchGroups(name, exactMatch, lookupToken)
+	if err != nil {
+		return identities, err
+	}
+	for _, group := range groups {
+		groupIdentity := client.Identity{Resource: client.Resource{
+			Type: "identity",
+		}}
+		group.toIdentity(GroupType, &groupIdentity)
+		identities = append(identities, groupIdentity)
+	}
+
+	return identities, nil
+}
+
+//LoadConfig initializes the provider with the passes config
+func (a *AzProvider) LoadConfig(authConfig model.AuthConfig) error {
+	configObj := authConfig.AzureADConfig
+	if configObj.TenantId == "" || configObj.ClientId == "" {
+		return fmt.Errorf("Missing TenantId or ClientId in azureadConfig")
+	}
+	if configObj.AuthorityURL == "" {
+		configObj.AuthorityURL = defaultAuthorityURL
+	}
+	if configObj.GraphURL == "" {
+		configObj.GraphURL = defaultGraphURL
+	}
+	a.azureClient.config = &configObj
+	return nil
+}
+
+//GetConfig returns the provider config
+func (a *AzProvider) GetConfig() model.AuthConfig {
+	log.Debug("In azuread getConfig")
+
+	authConfig := model.AuthConfig{Resource: client.Resource{
+		Type: "config",
+	}}
+
+	authConfig.Provider = Config
+	authConfig.AzureADConfig = *a.azureClient.config
+
+	authConfig.AzureADConfig.Resource = client.Resource{
+		Type: "azureadconfig",
+	}
+
+	return authConfig
+}
+
+//GetSettings transforms the provider config to db settings
+func (a *AzProvider) GetSettings() map[string]string {
+	settings := make(map[string]string)
+
+	settings[tenantIDSetting] = a.azureClient.config.TenantId
+	settings[clientIDSetting] = a.azureClient.config.ClientId
+	settings[clientSecretSetting] = a.azureClient.config.ClientSecret
+	settings[domainSetting] = a.azureClient.config.Domain
+	settings[adminAccountUsernameSetting] = a.azureClient.config.AdminAccountUsername
+	settings[adminAccountPasswordSetting] = a.azureClient.config.AdminAccountPassword
+	settings[redirectURLSetting] = a.azureClient.config.RedirectURL
+	settings[authorityURLSetting] = a.azureClient.config.AuthorityURL
+	settings[graphURLSetting] = a.azureClient.config.GraphURL
+	settings[passwordLoginSetting] = strconv.FormatBool(a.azureClient.config.PasswordLogin)
+
+	return settings
+}
+
+//GetProviderSettingList returns the provider specific db setting list
+func (a *AzProvider) GetProviderSettingList() []string {
+	var settings []string
+	settings = append(settings, tenantIDSetting)
+	settings = append(settings, clientIDSetting)
+	settings = append(settings, clientSecretSetting)
+	settings = append(settings, domainSetting)
+	settings = append(settings, adminAccountUsernameSetting)
+	settings = append(settings, adminAccountPasswordSetting)
+	settings = append(settings, redirectURLSetting)
+	settings = append(settings, authorityURLSetting)
+	settings = append(settings, graphURLSetting)
+	settings = append(settings, passwordLoginSetting)
+	return settings
+}
+
+//AddProviderConfig adds the provider config into the generic config using the settings from db
+func (a *AzProvider) AddProviderConfig(authConfig *model.AuthConfig, providerSettings map[string]string) {
+	azureConfig := model.AzureADConfig{}
+	azureConfig.Resource = client.Resource{
+		Type: "azureadconfig",
+	}
+	azureConfig.TenantId = providerSettings[tenantIDSetting]
+	azureConfig.ClientId = providerSettings[clientIDSetting]
+	azureConfig.ClientSecret = providerSettings[clientSecretSetting]
+	azureConfig.Domain = providerSettings[domainSetting]
+	azureConfig.AdminAccountUsername = providerSettings[adminAccountUsernameSetting]
+	azureConfig.AdminAccountPassword = providerSettings[adminAccountPasswordSetting]
+	azureConfig.RedirectURL = providerSettings[redirectURLSetting]
+	azureConfig.AuthorityURL = providerSettings[authorityURLSetting]
+	azureConfig.GraphURL = providerSettings[graphURLSetting]
+	azureConfig.PasswordLogin, _ = strconv.ParseBool(providerSettings[passwordLoginSetting])
+
+	authConfig.AzureADConfig = azureConfig
+}
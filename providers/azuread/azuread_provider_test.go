@@ -0,0 +1,236 @@
+package azuread
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rancher/go-rancher/client"
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+const (
+	testTenant      = "tenant-1"
+	testClientID    = "rancher"
+	testCode        = "code-1"
+	userToken       = "user-token"
+	adminToken      = "admin-token"
+	testAdminUser   = "admin@example.com"
+	testAdminSecret = "admin-pw"
+)
+
+//graphStandIn serves the token endpoint of the authority and the Graph API calls of the provider
+type graphStandIn struct {
+	server     *httptest.Server
+	tokenForms []map[string]string
+	nextLink   string
+}
+
+func newGraphStandIn(t *testing.T) *graphStandIn {
+	standIn := &graphStandIn{}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/"+testTenant+"/oauth2/v2.0/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form := map[string]string{}
+		for name := range r.PostForm {
+			form[name] = r.PostForm.Get(name)
+		}
+		standIn.tokenForms = append(standIn.tokenForms, form)
+
+		token := ""
+		switch {
+		case form["grant_type"] == "authorization_code" && form["code"] == testCode:
+			token = userToken
+		case form["grant_type"] == "password" && form["username"] == "alice@example.com" && form["password"] == "pw":
+			token = userToken
+		case form["grant_type"] == "password" && form["username"] == testAdminUser && form["password"] == testAdminSecret:
+			token = adminToken
+		}
+		if token == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": token, "token_type": "Bearer", "expires_in": 3600})
+	})
+
+	graph := func(token string, handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer "+token {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			handler(w, r)
+		}
+	}
+	mux.HandleFunc("/v1.0/me", graph(userToken, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(graphUser{ID: "u-1", DisplayName: "Alice", UserPrincipalName: "alice@example.com"})
+	}))
+	mux.HandleFunc("/v1.0/me/transitiveMemberOf/microsoft.graph.group", graph(userToken, func(w http.ResponseWriter, r *http.Request) {
+		page := map[string]interface{}{"value": []graphGroup{{ID: "g-1", DisplayName: "admins"}}}
+		if r.URL.Query().Get("$skiptoken") == "" {
+			next := standIn.nextLink
+			if next == "" {
+				next = standIn.server.URL + "/v1.0/me/transitiveMemberOf/microsoft.graph.group?$skiptoken=2"
+			}
+			page["@odata.nextLink"] = next
+		} else {
+			page["value"] = []graphGroup{{ID: "g-2", DisplayName: "devs"}}
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	mux.HandleFunc("/v1.0/users/u-2", graph(adminToken, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(graphUser{ID: "u-2", DisplayName: "Bob", Mail: "bob@example.com"})
+	}))
+	mux.HandleFunc("/v1.0/users", graph(userToken, func(w http.ResponseWriter, r *http.Request) {
+		if filter := r.URL.Query().Get("$filter"); !strings.Contains(filter, "startswith(displayName,'Al')") {
+			t.Errorf("Unexpected users filter %v", filter)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": []graphUser{{ID: "u-1", DisplayName: "Alice", UserPrincipalName: "alice@example.com"}}})
+	}))
+	mux.HandleFunc("/v1.0/groups", graph(userToken, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": []graphGroup{}})
+	}))
+
+	standIn.server = httptest.NewServer(mux)
+	return standIn
+}
+
+func newTestProvider(t *testing.T, standIn *graphStandIn, passwordLogin bool) *AzProvider {
+	provider := InitializeProvider()
+	config := model.AzureADConfig{
+		Azureadconfig: client.Azureadconfig{
+			TenantId:             testTenant,
+			ClientId:             testClientID,
+			Domain:               "example.com",
+			AdminAccountUsername: testAdminUser,
+		},
+		AdminAccountPassword: testAdminSecret,
+		AuthorityURL:         standIn.server.URL,
+		GraphURL:             standIn.server.URL,
+		PasswordLogin:        passwordLogin,
+	}
+	if err := provider.LoadConfig(model.AuthConfig{AzureADConfig: config}); err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func identityIDs(identities []client.Identity) string {
+	var ids []string
+	for _, identity := range identities {
+		ids = append(ids, identity.Id)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestGenerateTokenWithACode(t *testing.T) {
+	standIn := newGraphStandIn(t)
+	defer standIn.server.Close()
+	provider := newTestProvider(t, standIn, false)
+
+	token, err := provider.GenerateToken(testCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := identityIDs(token.IdentityList); ids != "azuread_user:u-1,azuread_group:g-1,azuread_group:g-2" {
+		t.Fatalf("Unexpected identities %v", ids)
+	}
+	if token.AccessToken != userToken || token.ExternalAccountID != "u-1" {
+		t.Fatalf("Unexpected token %v", token)
+	}
+}
+
+func TestGenerateTokenSendsCredentialsAsACodeWithoutPasswordLogin(t *testing.T) {
+	standIn := newGraphStandIn(t)
+	defer standIn.server.Close()
+	provider := newTestProvider(t, standIn, false)
+
+	if _, err := provider.GenerateToken("alice:pw"); err == nil {
+		t.Fatal("Expected the credentials to be refused without passwordLogin")
+	}
+	if len(standIn.tokenForms) != 1 || standIn.tokenForms[0]["grant_type"] != "authorization_code" {
+		t.Fatalf("Expected a single authorization_code request, got %v", standIn.tokenForms)
+	}
+}
+
+func TestGenerateTokenWithPasswordLogin(t *testing.T) {
+	standIn := newGraphStandIn(t)
+	defer standIn.server.Close()
+	provider := newTestProvider(t, standIn, true)
+
+	//the domain is added to the bare username
+	token, err := provider.GenerateToken("alice:pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.ExternalAccountID != "u-1" {
+		t.Fatalf("Unexpected account %v", token.ExternalAccountID)
+	}
+
+	if _, err := provider.GenerateToken(testCode); err == nil {
+		t.Fatal("Expected a code to be refused with passwordLogin")
+	}
+	if len(standIn.tokenForms) != 1 || standIn.tokenForms[0]["grant_type"] != "password" {
+		t.Fatalf("Expected a single password request, got %v", standIn.tokenForms)
+	}
+}
+
+func TestGetIdentitiesRefusesANextLinkOffTheGraphHost(t *testing.T) {
+	standIn := newGraphStandIn(t)
+	defer standIn.server.Close()
+
+	var foreignHits int32
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&foreignHits, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": []graphGroup{}})
+	}))
+	defer foreign.Close()
+	standIn.nextLink = foreign.URL + "/v1.0/me/transitiveMemberOf/microsoft.graph.group?$skiptoken=2"
+
+	provider := newTestProvider(t, standIn, false)
+	if _, err := provider.GetIdentities(userToken); err == nil {
+		t.Fatal("Expected the foreign next page link to be refused")
+	}
+	if hits := atomic.LoadInt32(&foreignHits); hits != 0 {
+		t.Fatalf("The token was sent to the foreign host %v times", hits)
+	}
+}
+
+func TestGetIdentityUsesTheAdminAccount(t *testing.T) {
+	standIn := newGraphStandIn(t)
+	defer standIn.server.Close()
+	provider := newTestProvider(t, standIn, false)
+
+	for i := 0; i < 2; i++ {
+		identity, err := provider.GetIdentity("u-2", UserType, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if identity.Id != "azuread_user:u-2" || identity.Login != "bob@example.com" || identity.Name != "Bob" {
+			t.Fatalf("Unexpected identity %v", identity)
+		}
+	}
+	//the admin token is cached
+	if len(standIn.tokenForms) != 1 {
+		t.Fatalf("Expected a single admin token request, got %v", standIn.tokenForms)
+	}
+}
+
+func TestSearchIdentities(t *testing.T) {
+	standIn := newGraphStandIn(t)
+	defer standIn.server.Close()
+	provider := newTestProvider(t, standIn, false)
+
+	identities, err := provider.SearchIdentities("Al", false, userToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := identityIDs(identities); ids != "azuread_user:u-1" {
+		t.Fatalf("Unexpected identities %v", ids)
+	}
+}
@@ -0,0 +1,121 @@
+package bitbucket
+
+import (
+	"github.com/rancher/go-rancher/client"
+)
+
+//Account defines properties a user, workspace, project or group on bitbucket has
+type Account struct {
+	ID        string
+	Login     string
+	Name      string
+	AvatarURL string
+	HTMLURL   string
+}
+
+func (a *Account) toIdentity(externalIDType string, identity *client.Identity) {
+	identity.ExternalId = a.ID
+	identity.Resource.Id = externalIDType + ":" + a.ID
+	identity.ExternalIdType = externalIDType
+	if a.Name != "" {
+		identity.Name = a.Name
+	} else {
+		identity.Name = a.Login
+	}
+	identity.Login = a.Login
+	identity.ProfilePicture = a.AvatarURL
+	identity.ProfileUrl = a.HTMLURL
+}
+
+type link struct {
+	Href string `json:"href"`
+}
+
+//cloudUser is a user of the Bitbucket Cloud 2.0 API
+type cloudUser struct {
+	UUID        string `json:"uuid"`
+	Nickname    string `json:"nickname"`
+	DisplayName string `json:"display_name"`
+	Links       struct {
+		Avatar link `json:"avatar"`
+		HTML   link `json:"html"`
+	} `json:"links"`
+}
+
+func (u *cloudUser) toAccount() Account {
+	return Account{
+		ID:        u.UUID,
+		Login:     u.Nickname,
+		Name:      u.DisplayName,
+		AvatarURL: u.Links.Avatar.Href,
+		HTMLURL:   u.Links.HTML.Href,
+	}
+}
+
+//cloudWorkspace is a workspace of the Bitbucket Cloud 2.0 API
+type cloudWorkspace struct {
+	UUID  string `json:"uuid"`
+	Slug  string `json:"slug"`
+	Name  string `json:"name"`
+	Links struct {
+		Avatar link `json:"avatar"`
+		HTML   link `json:"html"`
+	} `json:"links"`
+}
+
+func (w *cloudWorkspace) toAccount() Account {
+	return Account{
+		ID:        w.UUID,
+		Login:     w.Slug,
+		Name:      w.Name,
+		AvatarURL: w.Links.Avatar.Href,
+		HTMLURL:   w.Links.HTML.Href,
+	}
+}
+
+//serverUser is a user of the Bitbucket Server 1.0 API
+type serverUser struct {
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	DisplayName string `json:"displayName"`
+	Links       struct {
+		Self []link `json:"self"`
+	} `json:"links"`
+}
+
+func (u *serverUser) toAccount() Account {
+	account := Account{
+		ID:    u.Slug,
+		Login: u.Name,
+		Name:  u.DisplayName,
+	}
+	if len(u.Links.Self) > 0 {
+		account.HTMLURL = u.Links.Self[0].Href
+	}
+	return account
+}
+
+//serverProject is a project of the Bitbucket Server 1.0 API
+type serverProject struct {
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	Links struct {
+		Self []link `json:"self"`
+	} `json:"links"`
+}
+
+func (p *serverProject) toAccount() Account {
+	account := Account{
+		ID:    p.Key,
+		Login: p.Key,
+		Name:  p.Name,
+	}
+	if len(p.Links.Self) > 0 {
+		account.HTMLURL = p.Links.Self[0].Href
+	}
+	return account
+}
+
+func groupAccount(name string) Account {
+	return Account{ID: name, Login: name, Name: name}
+}
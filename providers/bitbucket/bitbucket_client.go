@@ -0,0 +1,510 @@
+package bitbucket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+const (
+	cloudHostName = "https://bitbucket.org"
+	cloudAPI      = "https://api.bitbucket.org/2.0"
+	serverAPI     = "/rest/api/1.0"
+	pageSize      = "100"
+)
+
+//BClient implements a httpclient for Bitbucket Cloud and Bitbucket Server
+type BClient struct {
+	httpClient *http.Client
+	config     *model.BitbucketConfig
+}
+
+//isServer tells if the config points to a self-hosted Bitbucket Server instead of Bitbucket Cloud
+func (b *BClient) isServer() bool {
+	return b.config.Hostname != ""
+}
+
+func (b *BClient) getAccessToken(code string) (string, error) {
+	form := url.Values{}
+	form.Add("grant_type", "authorization_code")
+	form.Add("code", code)
+	if b.config.RedirectURL != "" {
+		form.Add("redirect_uri", b.config.RedirectURL)
+	}
+
+	if b.isServer() {
+		form.Add("client_id", b.config.ClientID)
+		form.Add("client_secret", b.config.ClientSecret)
+	}
+
+	req, err := http.NewRequest("POST", b.getURL("TOKEN"), strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	if !b.isServer() {
+		req.SetBasicAuth(b.config.ClientID, b.config.ClientSecret)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Accept", "application/json")
+
+	var respMap map[string]interface{}
+	if err := b.doJSON(req, &respMap); err != nil {
+		log.Errorf("Bitbucket getAccessToken: received error from bitbucket, err: %v", err)
+		return "", err
+	}
+
+	if respMap["error"] != nil {
+		desc := respMap["error_description"]
+		log.Errorf("Received Error from bitbucket %v, description from bitbucket %v", respMap["error"], desc)
+		return "", fmt.Errorf("Received Error from bitbucket %v, description from bitbucket %v", respMap["error"], desc)
+	}
+
+	accessToken, ok := respMap["access_token"].(string)
+	if !ok {
+		return "", fmt.Errorf("Received Error reading accessToken from response %v", respMap)
+	}
+	return accessToken, nil
+}
+
+func (b *BClient) getBitbucketUser(accessToken string) (Account, error) {
+	if b.isServer() {
+		return b.getServerUser(accessToken)
+	}
+
+	var user cloudUser
+	if err := b.getJSON(accessToken, b.getURL("USER_INFO"), &user); err != nil {
+		log.Errorf("Bitbucket getBitbucketUser: received error from bitbucket, err: %v", err)
+		return Account{}, err
+	}
+	return user.toAccount(), nil
+}
+
+//getServerUser resolves the user owning the token, Bitbucket Server has no current user resource
+func (b *BClient) getServerUser(accessToken string) (Account, error) {
+	req, err := http.NewRequest("GET", b.getURL("WHOAMI"), nil)
+	if err != nil {
+		return Account{}, err
+	}
+	resp, err := b.do(accessToken, req)
+	if err != nil {
+		log.Errorf("Bitbucket getServerUser: received error from bitbucket, err: %v", err)
+		return Account{}, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Account{}, err
+	}
+	username := strings.TrimSpace(string(body))
+	if username == "" {
+		return Account{}, fmt.Errorf("The token is not authenticated with bitbucket")
+	}
+
+	users, err := b.searchUsers(username, true, accessToken)
+	if err != nil {
+		return Account{}, err
+	}
+	if len(users) == 0 {
+		return Account{}, fmt.Errorf("User %v not found on bitbucket", username)
+	}
+	return users[0], nil
+}
+
+//getWorkspaces returns the Bitbucket Cloud workspaces the user is a member of
+func (b *BClient) getWorkspaces(accessToken string) ([]Account, error) {
+	var workspaces []Account
+	values, err := b.paginateCloud(accessToken, b.getURL("WORKSPACE_INFO"))
+	if err != nil {
+		log.Errorf("Bitbucket getWorkspaces: received error from bitbucket, err: %v", err)
+		return workspaces, err
+	}
+	for _, value := range values {
+		var permission struct {
+			Workspace cloudWorkspace `json:"workspace"`
+		}
+		if err := json.Unmarshal(value, &permission); err != nil {
+			return workspaces, err
+		}
+		workspaces = append(workspaces, permission.Workspace.toAccount())
+	}
+	return workspaces, nil
+}
+
+//getProjects returns the Bitbucket Server projects the user can read
+func (b *BClient) getProjects(accessToken string) ([]Account, error) {
+	return b.listProjects(b.getURL("PROJECT_INFO"), accessToken)
+}
+
+//getGroups returns the Bitbucket Server groups of the user, reading memberships needs admin permission so the
+//serviceAccountToken is used rather than the token of the user
+func (b *BClient) getGroups(username string) ([]Account, error) {
+	var groups []Account
+	if b.config.ServiceAccountToken == "" {
+		return groups, fmt.Errorf("No serviceAccountToken is configured to read the Bitbucket Server group memberships")
+	}
+	values, err := b.paginateServer(b.config.ServiceAccountToken, b.getURL("GROUP_INFO")+url.QueryEscape(username))
+	if err != nil {
+		return groups, err
+	}
+	for _, value := range values {
+		var group struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(value, &group); err != nil {
+			return groups, err
+		}
+		groups = append(groups, groupAccount(group.Name))
+	}
+	return groups, nil
+}
+
+func (b *BClient) getUserByID(id string, accessToken string) (Account, error) {
+	if b.isServer() {
+		var user serverUser
+		if err := b.getJSON(accessToken, b.getURL("USERS")+"/"+url.QueryEscape(id), &user); err != nil {
+			log.Errorf("Bitbucket getUserByID: received error from bitbucket, err: %v", err)
+			return Account{}, err
+		}
+		return user.toAccount(), nil
+	}
+
+	var user cloudUser
+	if err := b.getJSON(accessToken, b.getURL("USERS")+"/"+url.QueryEscape(id), &user); err != nil {
+		log.Errorf("Bitbucket getUserByID: received error from bitbucket, err: %v", err)
+		return Account{}, err
+	}
+	return user.toAccount(), nil
+}
+
+//getWorkspaceByID accepts the uuid or the slug of a Bitbucket Cloud workspace
+func (b *BClient) getWorkspaceByID(id string, accessToken string) (Account, error) {
+	var workspace cloudWorkspace
+	if err := b.getJSON(accessToken, b.getURL("WORKSPACES")+"/"+url.QueryEscape(id), &workspace); err != nil {
+		log.Errorf("Bitbucket getWorkspaceByID: received error from bitbucket, err: %v", err)
+		return Account{}, err
+	}
+	return workspace.toAccount(), nil
+}
+
+func (b *BClient) getProjectByKey(key string, accessToken string) (Account, error) {
+	var project serverProject
+	if err := b.getJSON(accessToken, b.getURL("PROJECTS")+"/"+url.QueryEscape(key), &project); err != nil {
+		log.Errorf("Bitbucket getProjectByKey: received error from bitbucket, err: %v", err)
+		return Account{}, err
+	}
+	return project.toAccount(), nil
+}
+
+func (b *BClient) searchUsers(name string, exactMatch bool, accessToken string) ([]Account, error) {
+	if !b.isServer() {
+		return b.searchWorkspaceMembers(name, exactMatch, accessToken)
+	}
+
+	var users []Account
+	values, err := b.paginateServer(accessToken, b.getURL("USERS")+"?filter="+url.QueryEscape(name))
+	if err != nil {
+		log.Errorf("Bitbucket searchUsers: received error from bitbucket, err: %v", err)
+		return users, err
+	}
+	for _, value := range values {
+		var user serverUser
+		if err := json.Unmarshal(value, &user); err != nil {
+			return users, err
+		}
+		if !exactMatch || strings.EqualFold(user.Name, name) || strings.EqualFold(user.Slug, name) {
+			users = append(users, user.toAccount())
+		}
+	}
+	return users, nil
+}
+
+//searchWorkspaceMembers looks for the users among the members of the caller's workspaces, Bitbucket Cloud has no user search
+func (b *BClient) searchWorkspaceMembers(name string, exactMatch bool, accessToken string) ([]Account, error) {
+	var users []Account
+	workspaces, err := b.getWorkspaces(accessToken)
+	if err != nil {
+		return users, err
+	}
+	seen := make(map[string]bool)
+	for _, workspace := range workspaces {
+		values, err := b.paginateCloud(accessToken, b.getURL("WORKSPACES")+"/"+url.QueryEscape(workspace.Login)+"/members?pagelen="+pageSize)
+		if err != nil {
+			log.Errorf("Bitbucket searchWorkspaceMembers: received error from bitbucket, err: %v", err)
+			return users, err
+		}
+		for _, value := range values {
+			var member struct {
+				User cloudUser `json:"user"`
+			}
+			if err := json.Unmarshal(value, &member); err != nil {
+				return users, err
+			}
+			if seen[member.User.UUID] || !matches(name, exactMatch, member.User.Nickname, member.User.DisplayName) {
+				continue
+			}
+			seen[member.User.UUID] = true
+			users = append(users, member.User.toAccount())
+		}
+	}
+	return users, nil
+}
+
+func (b *BClient) searchWorkspaces(name string, exactMatch bool, accessToken string) ([]Account, error) {
+	if exactMatch {
+		workspace, err := b.getWorkspaceByID(name, accessToken)
+		if err != nil {
+			return []Account{}, err
+		}
+		return []Account{workspace}, nil
+	}
+
+	var workspaces []Account
+	known, err := b.getWorkspaces(accessToken)
+	if err != nil {
+		return workspaces, err
+	}
+	for _, workspace := range known {
+		if matches(name, exactMatch, workspace.Login, workspace.Name) {
+			workspaces = append(workspaces, workspace)
+		}
+	}
+	return workspaces, nil
+}
+
+func (b *BClient) searchProjects(name string, exactMatch bool, accessToken string) ([]Account, error) {
+	if exactMatch {
+		if project, err := b.getProjectByKey(name, accessToken); err == nil {
+			return []Account{project}, nil
+		}
+	}
+
+	var projects []Account
+	known, err := b.listProjects(b.getURL("PROJECTS")+"?name="+url.QueryEscape(name), accessToken)
+	if err != nil {
+		return projects, err
+	}
+	for _, project := range known {
+		if matches(name, exactMatch, project.Login, project.Name) {
+			projects = append(projects, project)
+		}
+	}
+	return projects, nil
+}
+
+func (b *BClient) searchGroups(name string, exactMatch bool, accessToken string) ([]Account, error) {
+	var groups []Account
+	values, err := b.paginateServer(accessToken, b.getURL("GROUPS")+"?filter="+url.QueryEscape(name))
+	if err != nil {
+		log.Errorf("Bitbucket searchGroups: received error from bitbucket, err: %v", err)
+		return groups, err
+	}
+	for _, value := range values {
+		var group string
+		if err := json.Unmarshal(value, &group); err != nil {
+			return groups, err
+		}
+		if !exactMatch || strings.EqualFold(group, name) {
+			groups = append(groups, groupAccount(group))
+		}
+	}
+	return groups, nil
+}
+
+func (b *BClient) listProjects(url string, accessToken string) ([]Account, error) {
+	var projects []Account
+	values, err := b.paginateServer(accessToken, url)
+	if err != nil {
+		log.Errorf("Bitbucket listProjects: received error from bitbucket, err: %v", err)
+		return projects, err
+	}
+	for _, value := range values {
+		var project serverProject
+		if err := json.Unmarshal(value, &project); err != nil {
+			return projects, err
+		}
+		projects = append(projects, project.toAccount())
+	}
+	return projects, nil
+}
+
+func matches(name string, exactMatch bool, values ...string) bool {
+	for _, value := range values {
+		if exactMatch && strings.EqualFold(value, name) {
+			return true
+		}
+		if !exactMatch && strings.HasPrefix(strings.ToLower(value), strings.ToLower(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+//paginateCloud follows the next links of a Bitbucket Cloud collection and returns all values
+func (b *BClient) paginateCloud(accessToken string, url string) ([]json.RawMessage, error) {
+	var values []json.RawMessage
+	for url != "" {
+		var page struct {
+			Values []json.RawMessage `json:"values"`
+			Next   string            `json:"next"`
+		}
+		if err := b.getJSON(accessToken, url, &page); err != nil {
+			return values, err
+		}
+		values = append(values, page.Values...)
+		if page.Next != "" && !b.isAPIURL(page.Next) {
+			return values, fmt.Errorf("The next page link %v is not on the bitbucket API %v", page.Next, b.getURL("API"))
+		}
+		url = page.Next
+	}
+	return values, nil
+}
+
+//isAPIURL tells if the link is on the scheme and host of the bitbucket API, so that the token is only sent there
+func (b *BClient) isAPIURL(link string) bool {
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	apiURL, err := url.Parse(b.getURL("API"))
+	if err != nil {
+		return false
+	}
+	return linkURL.Scheme == apiURL.Scheme && strings.EqualFold(linkURL.Host, apiURL.Host)
+}
+
+//paginateServer follows the nextPageStart of a Bitbucket Server collection and returns all values
+func (b *BClient) paginateServer(accessToken string, url string) ([]json.RawMessage, error) {
+	var values []json.RawMessage
+	separator := "?"
+	if strings.Contains(url, "?") {
+		separator = "&"
+	}
+	start := 0
+	for {
+		var page struct {
+			Values        []json.RawMessage `json:"values"`
+			IsLastPage    bool              `json:"isLastPage"`
+			NextPageStart int               `json:"nextPageStart"`
+		}
+		pageURL := url + separator + "limit=" + pageSize + "&start=" + strconv.Itoa(start)
+		if err := b.getJSON(accessToken, pageURL, &page); err != nil {
+			return values, err
+		}
+		values = append(values, page.Values...)
+		if page.IsLastPage || page.NextPageStart <= start {
+			return values, nil
+		}
+		start = page.NextPageStart
+	}
+}
+
+func (b *BClient) getJSON(accessToken string, url string, v interface{}) error {
+	log.Debugf("url %v", url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Accept", "application/json")
+	resp, err := b.do(accessToken, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (b *BClient) doJSON(req *http.Request, v interface{}) error {
+	resp, err := b.do("", req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (b *BClient) do(accessToken string, req *http.Request) (*http.Response, error) {
+	if accessToken != "" {
+		req.Header.Add("Authorization", "Bearer "+accessToken)
+	}
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		log.Errorf("Received error from bitbucket: %v", err)
+		return resp, err
+	}
+	// Check the status code
+	switch resp.StatusCode {
+	case 200:
+	case 201:
+	default:
+		defer resp.Body.Close()
+		var body bytes.Buffer
+		io.Copy(&body, resp.Body)
+		return resp, fmt.Errorf("Request failed, got status code: %d. Response: %s",
+			resp.StatusCode, body.Bytes())
+	}
+	return resp, nil
+}
+
+func (b *BClient) getURL(endpoint string) string {
+
+	var hostName, apiEndpoint, toReturn string
+
+	if b.isServer() {
+		hostName = b.config.Scheme + b.config.Hostname
+		apiEndpoint = hostName + serverAPI
+	} else {
+		hostName = cloudHostName
+		apiEndpoint = cloudAPI
+	}
+
+	switch endpoint {
+	case "TOKEN":
+		if b.isServer() {
+			toReturn = hostName + "/rest/oauth2/latest/token"
+		} else {
+			toReturn = hostName + "/site/oauth2/access_token"
+		}
+	case "WHOAMI":
+		toReturn = hostName + "/plugins/servlet/applinks/whoami"
+	case "USER_INFO":
+		toReturn = apiEndpoint + "/user"
+	case "USERS":
+		toReturn = apiEndpoint + "/users"
+	case "WORKSPACES":
+		toReturn = apiEndpoint + "/workspaces"
+	case "WORKSPACE_INFO":
+		toReturn = apiEndpoint + "/user/permissions/workspaces?pagelen=" + pageSize
+	case "PROJECTS":
+		toReturn = apiEndpoint + "/projects"
+	case "PROJECT_INFO":
+		toReturn = apiEndpoint + "/projects?permission=PROJECT_READ"
+	case "GROUPS":
+		toReturn = apiEndpoint + "/groups"
+	case "GROUP_INFO":
+		toReturn = apiEndpoint + "/admin/users/more-members?context="
+	default:
+		toReturn = apiEndpoint
+	}
+
+	return toReturn
+}
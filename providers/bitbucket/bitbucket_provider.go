@@ -0,0 +1,273 @@
+package bitbucket
+
+import (
+	"fmt"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/go-rancher/client"
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+//Constants for bitbucket
+const (
+	Name                = "bitbucket"
+	Config              = Name + "config"
+	TokenType           = Name + "jwt"
+	UserType            = Name + "_user"
+	WorkspaceType       = Name + "_workspace"
+	ProjectType         = Name + "_project"
+	GroupType           = Name + "_group"
+	hostnameSetting     = "api.bitbucket.domain"
+	schemeSetting       = "api.bitbucket.scheme"
+	clientIDSetting     = "api.auth.bitbucket.client.id"
+	clientSecretSetting = "api.auth.bitbucket.client.secret"
+	redirectURLSetting  = "api.auth.bitbucket.redirect.url"
+	//the setting name ends with .secret so that it is encrypted with the settings encryption key
+	serviceAccountTokenSetting = "api.auth.bitbucket.service.account.secret"
+)
+
+//InitializeProvider returns a new instance of the provider
+func InitializeProvider() *BProvider {
+	bitbucketClient := &BClient{}
+	bitbucketClient.httpClient = &http.Client{}
+
+	bitbucketProvider := &BProvider{}
+	bitbucketProvider.bitbucketClient = bitbucketClient
+
+	return bitbucketProvider
+}
+
+//BProvider implements an IdentityProvider for Bitbucket Cloud and Bitbucket Server
+type BProvider struct {
+	bitbucketClient *BClient
+}
+
+//GetName returns the name of the provider
+func (b *BProvider) GetName() string {
+	return Name
+}
+
+//GetEndpoint returns the url of the bitbucket token endpoint
+func (b *BProvider) GetEndpoint() string {
+	return b.bitbucketClient.getURL("TOKEN")
+}
+
+//GenerateToken authenticates the given code and returns the token
+func (b *BProvider) GenerateToken(securityCode string) (model.Token, error) {
+	log.Debug("BitbucketIdentityProvider GenerateToken called")
+	accessToken, err := b.bitbucketClient.getAccessToken(securityCode)
+	if err != nil {
+		log.Errorf("Error generating accessToken from bitbucket %v", err)
+		return model.Token{}, err
+	}
+	return b.createToken(accessToken)
+}
+
+func (b *BProvider) createToken(accessToken string) (model.Token, error) {
+	var token model.Token
+	token.AccessToken = accessToken
+	identities, err := b.GetIdentities(accessToken)
+	if err != nil {
+		log.Errorf("Error getting identities using accessToken from bitbucket %v", err)
+		return model.Token{}, err
+	}
+	token.IdentityList = identities
+	token.Type = TokenType
+	user, ok := getUserIdentity(identities)
+	if !ok {
+		log.Error("User identity not found using accessToken from bitbucket")
+		return model.Token{}, fmt.Errorf("User identity not found using accessToken from bitbucket")
+	}
+	token.ExternalAccountID = user.ExternalId
+	return token, nil
+}
+
+func getUserIdentity(identities []client.Identity) (client.Identity, bool) {
+	for _, identity := range identities {
+		if identity.ExternalIdType == UserType {
+			return identity, true
+		}
+	}
+	return client.Identity{}, false
+}
+
+func appendIdentities(identities []client.Identity, accounts []Account, externalIDType string) []client.Identity {
+	for _, account := range accounts {
+		identity := client.Identity{Resource: client.Resource{
+			Type: "identity",
+		}}
+		account.toIdentity(externalIDType, &identity)
+		identities = append(identities, identity)
+	}
+	return identities
+}
+
+//RefreshToken re-authenticates and generate a new token
+func (b *BProvider) RefreshToken(accessToken string) (model.Token, error) {
+	log.Debug("BitbucketIdentityProvider RefreshToken called")
+	return b.createToken(accessToken)
+}
+
+//GetIdentities returns the user with the workspaces on Bitbucket Cloud, or the projects and groups on Bitbucket Server
+func (b *BProvider) GetIdentities(accessToken string) ([]client.Identity, error) {
+	var identities []client.Identity
+
+	userAcct, err := b.bitbucketClient.getBitbucketUser(accessToken)
+	if err != nil {
+		return identities, err
+	}
+	identities = appendIdentities(identities, []Account{userAcct}, UserType)
+
+	if b.bitbucketClient.isServer() {
+		projectAccts, err := b.bitbucketClient.getProjects(accessToken)
+		if err != nil {
+			log.Errorf("Bitbucket GetIdentities: cannot read the projects of %v, err: %v", userAcct.Login, err)
+		} else {
+			identities = appendIdentities(identities, projectAccts, ProjectType)
+		}
+		groupAccts, err := b.bitbucketClient.getGroups(userAcct.Login)
+		if err != nil {
+			log.Errorf("Bitbucket GetIdentities: cannot read the groups of %v, err: %v", userAcct.Login, err)
+		} else {
+			identities = appendIdentities(identities, groupAccts, GroupType)
+		}
+	} else {
+		workspaceAccts, err := b.bitbucketClient.getWorkspaces(accessToken)
+		if err != nil {
+			log.Errorf("Bitbucket GetIdentities: cannot read the workspaces of %v, err: %v", userAcct.Login, err)
+		} else {
+			identities = appendIdentities(identities, workspaceAccts, WorkspaceType)
+		}
+	}
+
+	return identities, nil
+}
+
+//GetIdentity returns the identity by externalID and externalIDType
+func (b *BProvider) GetIdentity(externalID string, externalIDType string, accessToken string) (client.Identity, error) {
+	identity := client.Identity{Resource: client.Resource{
+		Type: "identity",
+	}}
+
+	var account Account
+	var err error
+	switch externalIDType {
+	case UserType:
+		account, err = b.bitbucketClient.getUserByID(externalID, accessToken)
+	case WorkspaceType:
+		account, err = b.bitbucketClient.getWorkspaceByID(externalID, accessToken)
+	case ProjectType:
+		account, err = b.bitbucketClient.getProjectByKey(externalID, accessToken)
+	case GroupType:
+		account = groupAccount(externalID)
+	default:
+		log.Debugf("Cannot get the bitbucket account due to invalid externalIDType %v", externalIDType)
+		return identity, fmt.Errorf("Cannot get the bitbucket account due to invalid externalIDType %v", externalIDType)
+	}
+	if err != nil {
+		return identity, err
+	}
+	account.toIdentity(externalIDType, &identity)
+	return identity, nil
+}
+
+//SearchIdentities returns the users, workspaces, projects and groups matching the name
+func (b *BProvider) SearchIdentities(name string, exactMatch bool, accessToken string) ([]client.Identity, error) {
+	var identities []client.Identity
+
+	userAccts, err := b.bitbucketClient.searchUsers(name, exactMatch, accessToken)
+	if err == nil {
+		identities = appendIdentities(identities, userAccts, UserType)
+	}
+
+	if b.bitbucketClient.isServer() {
+		projectAccts, err := b.bitbucketClient.searchProjects(name, exactMatch, accessToken)
+		if err == nil {
+			identities = appendIdentities(identities, projectAccts, ProjectType)
+		}
+		groupAccts, err := b.bitbucketClient.searchGroups(name, exactMatch, accessToken)
+		if err == nil {
+			identities = appendIdentities(identities, groupAccts, GroupType)
+		}
+	} else {
+		workspaceAccts, err := b.bitbucketClient.searchWorkspaces(name, exactMatch, accessToken)
+		if err == nil {
+			identities = appendIdentities(identities, workspaceAccts, WorkspaceType)
+		}
+	}
+
+	return identities, nil
+}
+
+//LoadConfig initializes the provider with the passes config
+func (b *BProvider) LoadConfig(authConfig model.AuthConfig) error {
+	configObj := authConfig.BitbucketConfig
+	if configObj.ClientID == "" || configObj.ClientSecret == "" {
+		return fmt.Errorf("Missing ClientID or ClientSecret in bitbucketConfig")
+	}
+	if configObj.Hostname != "" && configObj.Scheme == "" {
+		configObj.Scheme = "https://"
+	}
+	b.bitbucketClient.config = &configObj
+	return nil
+}
+
+//GetConfig returns the provider config
+func (b *BProvider) GetConfig() model.AuthConfig {
+	log.Debug("In bitbucket getConfig")
+
+	authConfig := model.AuthConfig{Resource: client.Resource{
+		Type: "config",
+	}}
+
+	authConfig.Provider = Config
+	authConfig.BitbucketConfig = *b.bitbucketClient.config
+
+	authConfig.BitbucketConfig.Resource = client.Resource{
+		Type: "bitbucketconfig",
+	}
+
+	return authConfig
+}
+
+//GetSettings transforms the provider config to db settings
+func (b *BProvider) GetSettings() map[string]string {
+	settings := make(map[string]string)
+
+	settings[hostnameSetting] = b.bitbucketClient.config.Hostname
+	settings[schemeSetting] = b.bitbucketClient.config.Scheme
+	settings[clientIDSetting] = b.bitbucketClient.config.ClientID
+	settings[clientSecretSetting] = b.bitbucketClient.config.ClientSecret
+	settings[redirectURLSetting] = b.bitbucketClient.config.RedirectURL
+	settings[serviceAccountTokenSetting] = b.bitbucketClient.config.ServiceAccountToken
+
+	return settings
+}
+
+//GetProviderSettingList returns the provider specific db setting list
+func (b *BProvider) GetProviderSettingList() []string {
+	var settings []string
+	settings = append(settings, hostnameSetting)
+	settings = append(settings, schemeSetting)
+	settings = append(settings, clientIDSetting)
+	settings = append(settings, clientSecretSetting)
+	settings = append(settings, redirectURLSetting)
+	settings = append(settings, serviceAccountTokenSetting)
+	return settings
+}
+
+//AddProviderConfig adds the provider config into the generic config using the settings from db
+func (b *BProvider) AddProviderConfig(authConfig *model.AuthConfig, providerSettings map[string]string) {
+	bitbucketConfig := model.BitbucketConfig{Resource: client.Resource{
+		Type: "bitbucketconfig",
+	}}
+	bitbucketConfig.Hostname = providerSettings[hostnameSetting]
+	bitbucketConfig.Scheme = providerSettings[schemeSetting]
+	bitbucketConfig.ClientID = providerSettings[clientIDSetting]
+	bitbucketConfig.ClientSecret = providerSettings[clientSecretSetting]
+	bitbucketConfig.RedirectURL = providerSettings[redirectURLSetting]
+	bitbucketConfig.ServiceAccountToken = providerSettings[serviceAccountTokenSetting]
+
+	authConfig.BitbucketConfig = bitbucketConfig
+}
@@ -0,0 +1,186 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+const (
+	pollInterval = 5 * time.Second
+)
+
+//only the last loaded client is watched, the providers loaded before it are no longer in use
+var (
+	watchOnce  sync.Once
+	watchMutex sync.Mutex
+	watched    *FClient
+)
+
+//FClient serves the users and groups read from the htpasswd and groups files
+type FClient struct {
+	config *model.FileConfig
+
+	mutex    sync.RWMutex
+	users    map[string]string
+	groups   map[string][]string
+	modTimes map[string]time.Time
+}
+
+//load reads both files and swaps the in-memory users and groups
+func (f *FClient) load() error {
+	modTimes := make(map[string]time.Time)
+
+	stat, err := os.Stat(f.config.HtpasswdFile)
+	if err != nil {
+		return err
+	}
+	modTimes[f.config.HtpasswdFile] = stat.ModTime()
+	users, err := readHtpasswd(f.config.HtpasswdFile)
+	if err != nil {
+		return err
+	}
+
+	groups := make(map[string][]string)
+	if f.config.GroupsFile != "" {
+		stat, err := os.Stat(f.config.GroupsFile)
+		if err != nil {
+			return err
+		}
+		modTimes[f.config.GroupsFile] = stat.ModTime()
+		if groups, err = readGroups(f.config.GroupsFile); err != nil {
+			return err
+		}
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.users = users
+	f.groups = groups
+	f.modTimes = modTimes
+	log.Infof("Loaded %d users and %d groups for the file provider", len(users), len(groups))
+	return nil
+}
+
+//readGroups reads the YAML groups file, a map of the group name to the list of member usernames
+func readGroups(path string) (map[string][]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[string][]string)
+	if err := yaml.Unmarshal(data, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+//reloadIfChanged loads the files again when one of them was modified, the current data is kept on errors
+func (f *FClient) reloadIfChanged() {
+	f.mutex.RLock()
+	changed := false
+	for path, modTime := range f.modTimes {
+		stat, err := os.Stat(path)
+		if err != nil || !stat.ModTime().Equal(modTime) {
+			changed = true
+			break
+		}
+	}
+	f.mutex.RUnlock()
+
+	if changed {
+		if err := f.load(); err != nil {
+			log.Errorf("Failed to reload the file provider files, keeping the previous users and groups: %v", err)
+		}
+	}
+}
+
+//watch polls the files of the client for changes
+func (f *FClient) watch() {
+	watchMutex.Lock()
+	watched = f
+	watchMutex.Unlock()
+
+	watchOnce.Do(func() {
+		go func() {
+			for range time.Tick(pollInterval) {
+				watchMutex.Lock()
+				current := watched
+				watchMutex.Unlock()
+				current.reloadIfChanged()
+			}
+		}()
+	})
+}
+
+func (f *FClient) authenticate(username string, password string) bool {
+	f.mutex.RLock()
+	hash, ok := f.users[username]
+	f.mutex.RUnlock()
+	return ok && verifyPassword(hash, password)
+}
+
+func (f *FClient) hasUser(username string) bool {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	_, ok := f.users[username]
+	return ok
+}
+
+func (f *FClient) hasGroup(name string) bool {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	_, ok := f.groups[name]
+	return ok
+}
+
+//groupsOf returns the sorted names of the groups the user is a member of
+func (f *FClient) groupsOf(username string) []string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	var names []string
+	for name, members := range f.groups {
+		for _, member := range members {
+			if member == username {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+//userNames returns the sorted usernames for searching
+func (f *FClient) userNames() []string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	var names []string
+	for name := range f.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//groupNames returns the sorted group names for searching
+func (f *FClient) groupNames() []string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	var names []string
+	for name := range f.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,205 @@
+package file
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/go-rancher/client"
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+//Constants for file
+const (
+	Name                = "file"
+	Config              = Name + "config"
+	TokenType           = Name + "jwt"
+	UserType            = Name + "_user"
+	GroupType           = Name + "_group"
+	htpasswdFileSetting = "api.auth.file.htpasswd.file"
+	groupsFileSetting   = "api.auth.file.groups.file"
+)
+
+//InitializeProvider returns a new instance of the provider
+func InitializeProvider() *FProvider {
+	fileProvider := &FProvider{}
+	fileProvider.fileClient = &FClient{}
+
+	return fileProvider
+}
+
+//FProvider implements an IdentityProvider backed by a htpasswd file and a YAML groups file
+type FProvider struct {
+	fileClient *FClient
+}
+
+//GetName returns the name of the provider
+func (f *FProvider) GetName() string {
+	return Name
+}
+
+//GenerateToken authenticates the credentials passed as username:password and returns the token
+func (f *FProvider) GenerateToken(securityCode string) (model.Token, error) {
+	log.Debug("FileIdentityProvider GenerateToken called")
+	parts := strings.SplitN(securityCode, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return model.Token{}, fmt.Errorf("Invalid credentials, expected username:password")
+	}
+	if !f.fileClient.authenticate(parts[0], parts[1]) {
+		log.Errorf("Error authenticating the user %v against the htpasswd file", parts[0])
+		return model.Token{}, fmt.Errorf("Invalid username or password")
+	}
+	return f.createToken(parts[0]), nil
+}
+
+func (f *FProvider) createToken(username string) model.Token {
+	var token model.Token
+	token.AccessToken = username
+	token.IdentityList = f.toIdentities(username)
+	token.Type = TokenType
+	token.ExternalAccountID = username
+	return token
+}
+
+func (f *FProvider) toIdentities(username string) []client.Identity {
+	identities := []client.Identity{toIdentity(UserType, username)}
+	for _, group := range f.fileClient.groupsOf(username) {
+		identities = append(identities, toIdentity(GroupType, group))
+	}
+	return identities
+}
+
+func toIdentity(externalIDType string, name string) client.Identity {
+	identity := client.Identity{Resource: client.Resource{
+		Type: "identity",
+	}}
+	identity.ExternalId = name
+	identity.Resource.Id = externalIDType + ":" + name
+	identity.ExternalIdType = externalIDType
+	identity.Login = name
+	identity.Name = name
+	return identity
+}
+
+//AccessTokenIsIdentifier tells that the access token is the username, it must not be accepted as a bearer
+func (f *FProvider) AccessTokenIsIdentifier() bool {
+	return true
+}
+
+//RefreshToken reads the user and its groups again from memory and generate a new token
+func (f *FProvider) RefreshToken(accessToken string) (model.Token, error) {
+	if !f.fileClient.hasUser(accessToken) {
+		return model.Token{}, fmt.Errorf("User %v not found in the htpasswd file", accessToken)
+	}
+	return f.createToken(accessToken), nil
+}
+
+//GetIdentities returns list of user and group identities associated to this token
+func (f *FProvider) GetIdentities(accessToken string) ([]client.Identity, error) {
+	if !f.fileClient.hasUser(accessToken) {
+		return []client.Identity{}, fmt.Errorf("User %v not found in the htpasswd file", accessToken)
+	}
+	return f.toIdentities(accessToken), nil
+}
+
+//GetIdentity returns the identity by externalID and externalIDType
+func (f *FProvider) GetIdentity(externalID string, externalIDType string, accessToken string) (client.Identity, error) {
+	switch externalIDType {
+	case UserType:
+		if !f.fileClient.hasUser(externalID) {
+			return client.Identity{}, fmt.Errorf("User %v not found in the htpasswd file", externalID)
+		}
+	case GroupType:
+		if !f.fileClient.hasGroup(externalID) {
+			return client.Identity{}, fmt.Errorf("Group %v not found in the groups file", externalID)
+		}
+	default:
+		log.Debugf("Cannot get the file account due to invalid externalIDType %v", externalIDType)
+		return client.Identity{}, fmt.Errorf("Cannot get the file account due to invalid externalIDType %v", externalIDType)
+	}
+	return toIdentity(externalIDType, externalID), nil
+}
+
+//SearchIdentities returns the users and groups matching the name
+func (f *FProvider) SearchIdentities(name string, exactMatch bool, accessToken string) ([]client.Identity, error) {
+	var identities []client.Identity
+	for _, username := range f.fileClient.userNames() {
+		if matches(name, exactMatch, username) {
+			identities = append(identities, toIdentity(UserType, username))
+		}
+	}
+	for _, group := range f.fileClient.groupNames() {
+		if matches(name, exactMatch, group) {
+			identities = append(identities, toIdentity(GroupType, group))
+		}
+	}
+	return identities, nil
+}
+
+func matches(name string, exactMatch bool, value string) bool {
+	if exactMatch {
+		return strings.EqualFold(value, name)
+	}
+	return strings.HasPrefix(strings.ToLower(value), strings.ToLower(name))
+}
+
+//LoadConfig reads the files and starts watching them for changes
+func (f *FProvider) LoadConfig(authConfig model.AuthConfig) error {
+	configObj := authConfig.FileConfig
+	if configObj.HtpasswdFile == "" {
+		return fmt.Errorf("Missing HtpasswdFile in fileConfig")
+	}
+	f.fileClient.config = &configObj
+	if err := f.fileClient.load(); err != nil {
+		return fmt.Errorf("Error loading the file provider files: %v", err)
+	}
+	f.fileClient.watch()
+	return nil
+}
+
+//GetConfig returns the provider config
+func (f *FProvider) GetConfig() model.AuthConfig {
+	log.Debug("In file getConfig")
+
+	authConfig := model.AuthConfig{Resource: client.Resource{
+		Type: "config",
+	}}
+
+	authConfig.Provider = Config
+	authConfig.FileConfig = *f.fileClient.config
+
+	authConfig.FileConfig.Resource = client.Resource{
+		Type: "fileconfig",
+	}
+
+	return authConfig
+}
+
+//GetSettings transforms the provider config to db settings
+func (f *FProvider) GetSettings() map[string]string {
+	settings := make(map[string]string)
+
+	settings[htpasswdFileSetting] = f.fileClient.config.HtpasswdFile
+	settings[groupsFileSetting] = f.fileClient.config.GroupsFile
+
+	return settings
+}
+
+//GetProviderSettingList returns the provider specific db setting list
+func (f *FProvider) GetProviderSettingList() []string {
+	var settings []string
+	settings = append(settings, htpasswdFileSetting)
+	settings = append(settings, groupsFileSetting)
+	return settings
+}
+
+//AddProviderConfig adds the provider config into the generic config using the settings from db
+func (f *FProvider) AddProviderConfig(authConfig *model.AuthConfig, providerSettings map[string]string) {
+	fileConfig := model.FileConfig{Resource: client.Resource{
+		Type: "fileconfig",
+	}}
+	fileConfig.HtpasswdFile = providerSettings[htpasswdFileSetting]
+	fileConfig.GroupsFile = providerSettings[groupsFileSetting]
+
+	authConfig.FileConfig = fileConfig
+}
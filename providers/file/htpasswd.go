@@ -0,0 +1,141 @@
+package file
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"os"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	apr1Magic = "$apr1$"
+	shaPrefix = "{SHA}"
+	itoa64    = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+//readHtpasswd returns the password hash by username, lines with an unsupported hash are skipped
+func readHtpasswd(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			log.Warnf("Skipping malformed line in %v", path)
+			continue
+		}
+		if !supportedHash(parts[1]) {
+			log.Warnf("Skipping user %v in %v, only bcrypt, apr1 and SHA hashes are supported", parts[0], path)
+			continue
+		}
+		users[parts[0]] = parts[1]
+	}
+	return users, scanner.Err()
+}
+
+func supportedHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2") || strings.HasPrefix(hash, apr1Magic) || strings.HasPrefix(hash, shaPrefix)
+}
+
+//verifyPassword checks the password against a htpasswd hash
+func verifyPassword(hash string, password string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$2"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, apr1Magic):
+		salt := strings.SplitN(strings.TrimPrefix(hash, apr1Magic), "$", 2)[0]
+		return subtle.ConstantTimeCompare([]byte(apr1(password, salt)), []byte(hash)) == 1
+	case strings.HasPrefix(hash, shaPrefix):
+		sum := sha1.Sum([]byte(password))
+		expected := shaPrefix + base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(expected), []byte(hash)) == 1
+	default:
+		return false
+	}
+}
+
+//apr1 computes the Apache MD5 crypt of the password, the default htpasswd hash
+func apr1(password string, salt string) string {
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	pw := []byte(password)
+
+	alt := md5.New()
+	alt.Write(pw)
+	alt.Write([]byte(salt))
+	alt.Write(pw)
+	altSum := alt.Sum(nil)
+
+	ctx := md5.New()
+	ctx.Write(pw)
+	ctx.Write([]byte(apr1Magic))
+	ctx.Write([]byte(salt))
+	for i := len(pw); i > 0; i -= 16 {
+		if i > 16 {
+			ctx.Write(altSum)
+		} else {
+			ctx.Write(altSum[:i])
+		}
+	}
+	for i := len(pw); i != 0; i >>= 1 {
+		if i&1 != 0 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write(pw[:1])
+		}
+	}
+	final := ctx.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		round := md5.New()
+		if i&1 != 0 {
+			round.Write(pw)
+		} else {
+			round.Write(final)
+		}
+		if i%3 != 0 {
+			round.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			round.Write(pw)
+		}
+		if i&1 != 0 {
+			round.Write(final)
+		} else {
+			round.Write(pw)
+		}
+		final = round.Sum(nil)
+	}
+
+	var result []byte
+	to64 := func(v uint, n int) {
+		for ; n > 0; n-- {
+			result = append(result, itoa64[v&0x3f])
+			v >>= 6
+		}
+	}
+	to64(uint(final[0])<<16|uint(final[6])<<8|uint(final[12]), 4)
+	to64(uint(final[1])<<16|uint(final[7])<<8|uint(final[13]), 4)
+	to64(uint(final[2])<<16|uint(final[8])<<8|uint(final[14]), 4)
+	to64(uint(final[3])<<16|uint(final[9])<<8|uint(final[15]), 4)
+	to64(uint(final[4])<<16|uint(final[10])<<8|uint(final[5]), 4)
+	to64(uint(final[11]), 2)
+
+	return apr1Magic + salt + "$" + string(result)
+}
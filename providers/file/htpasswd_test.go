@@ -0,0 +1,77 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestApr1(t *testing.T) {
+	//hashes generated with openssl passwd -apr1
+	for _, test := range []struct {
+		password string
+		salt     string
+		hash     string
+	}{
+		{"password", "xxxxxxxx", "$apr1$xxxxxxxx$dxHfLAsjHkDRmG83UXe8K0"},
+		{"a long password longer than sixteen bytes", "r31.....", "$apr1$r31.....$yi4JgNY54vpeUhQoZfIgw1"},
+		{"", "ab", "$apr1$ab$S8K6Sgp3W8c9Jb6LxgywZ."},
+	} {
+		if hash := apr1(test.password, test.salt); hash != test.hash {
+			t.Errorf("Unexpected hash %v of %q, expected %v", hash, test.password, test.hash)
+		}
+	}
+	//the salt is cut to 8 characters
+	if hash := apr1("password", "xxxxxxxxyy"); hash != "$apr1$xxxxxxxx$dxHfLAsjHkDRmG83UXe8K0" {
+		t.Errorf("Unexpected hash %v with the long salt", hash)
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hash := range []string{
+		"$apr1$xxxxxxxx$dxHfLAsjHkDRmG83UXe8K0",
+		"{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=",
+		string(bcryptHash),
+	} {
+		if !verifyPassword(hash, "password") {
+			t.Errorf("Expected the password to match %v", hash)
+		}
+		if verifyPassword(hash, "Password") {
+			t.Errorf("Expected another password not to match %v", hash)
+		}
+	}
+	if verifyPassword("password", "password") {
+		t.Error("Expected the plain text hash to be refused")
+	}
+}
+
+func TestReadHtpasswd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "htpasswd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "htpasswd")
+	content := "# users\n\nalice:$apr1$xxxxxxxx$dxHfLAsjHkDRmG83UXe8K0\nbob:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\ncarol:plaintext\nmalformed\n:nouser\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := readHtpasswd(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users["alice"] != "$apr1$xxxxxxxx$dxHfLAsjHkDRmG83UXe8K0" || users["bob"] != "{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=" {
+		t.Fatalf("Unexpected users %v", users)
+	}
+	if _, err := readHtpasswd(filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("Expected the missing file to be rejected")
+	}
+}
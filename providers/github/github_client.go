@@ -11,7 +11,9 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 	
+	"github.com/rancher/rancher-auth-service/metrics"
 	"github.com/rancher/rancher-auth-service/model"
 )
 
@@ -358,7 +360,9 @@ func (g *GClient) postToGithub(url string, form url.Values) (*http.Response, err
 	req.PostForm = form
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Accept", "application/json")
+	start := time.Now()
 	resp, err := g.httpClient.Do(req)
+	metrics.ObserveProviderRequest(Name, req.Method, resp, start)
 	if err != nil {
 		log.Error("Received error from github: %v", err)
 		return resp, err
@@ -384,7 +388,9 @@ func (g *GClient) getFromGithub(githubAccessToken string, url string) (*http.Res
 	req.Header.Add("Authorization", "token "+githubAccessToken)
 	req.Header.Add("Accept", "application/json")
 	req.Header.Add("user-agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_10_5) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/51.0.2704.103 Safari/537.36)")
+	start := time.Now()
 	resp, err := g.httpClient.Do(req)
+	metrics.ObserveProviderRequest(Name, req.Method, resp, start)
 	if err != nil {
 		log.Error("Received error from github: %v", err)
 		return resp, err
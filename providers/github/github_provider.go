@@ -47,6 +47,11 @@ func (g *GProvider) GetName() string {
 	return Name
 }
 
+//GetEndpoint returns the github API url
+func (g *GProvider) GetEndpoint() string {
+	return g.githubClient.getURL("API")
+}
+
 //GenerateToken authenticates the given code and returns the token
 func (g *GProvider) GenerateToken(securityCode string) (model.Token, error) {
 	//getAccessToken
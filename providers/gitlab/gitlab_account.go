@@ -0,0 +1,56 @@
+package gitlab
+
+import (
+	"strconv"
+
+	"github.com/rancher/go-rancher/client"
+)
+
+//Account defines properties an account on gitlab has
+type Account struct {
+	ID        int    `json:"id,omitempty"`
+	Login     string `json:"username,omitempty"`
+	Name      string `json:"name,omitempty"`
+	AvatarURL string `json:"avatar_url,omitempty"`
+	HTMLURL   string `json:"web_url,omitempty"`
+}
+
+func (a *Account) toIdentity(externalIDType string, identity *client.Identity) {
+	identity.ExternalId = strconv.Itoa(a.ID)
+	identity.Resource.Id = externalIDType + ":" + strconv.Itoa(a.ID)
+	identity.ExternalIdType = externalIDType
+	if a.Name != "" {
+		identity.Name = a.Name
+	} else {
+		identity.Name = a.Login
+	}
+	identity.Login = a.Login
+	identity.ProfilePicture = a.AvatarURL
+	identity.ProfileUrl = a.HTMLURL
+}
+
+//Group defines properties a group or subgroup on gitlab has
+type Group struct {
+	ID        int    `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Path      string `json:"path,omitempty"`
+	FullName  string `json:"full_name,omitempty"`
+	FullPath  string `json:"full_path,omitempty"`
+	AvatarURL string `json:"avatar_url,omitempty"`
+	HTMLURL   string `json:"web_url,omitempty"`
+}
+
+//toGitlabAccount uses the full path so subgroups are told apart from the same named groups elsewhere
+func (g *Group) toGitlabAccount(account *Account) {
+	account.ID = g.ID
+	account.Login = g.FullPath
+	if account.Login == "" {
+		account.Login = g.Path
+	}
+	account.Name = g.FullName
+	if account.Name == "" {
+		account.Name = g.Name
+	}
+	account.AvatarURL = g.AvatarURL
+	account.HTMLURL = g.HTMLURL
+}
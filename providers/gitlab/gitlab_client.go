@@ -0,0 +1,330 @@
+package gitlab
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/tomnomnom/linkheader"
+
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+const (
+	gitlabAPI             = "/api/v4"
+	gitlabDefaultHostName = "https://gitlab.com"
+)
+
+//GLClient implements a httpclient for gitlab
+type GLClient struct {
+	httpClient *http.Client
+	config     *model.GitlabConfig
+}
+
+func (g *GLClient) getAccessToken(code string) (string, error) {
+	form := url.Values{}
+	form.Add("client_id", g.config.ClientID)
+	form.Add("client_secret", g.config.ClientSecret)
+	form.Add("code", code)
+	form.Add("grant_type", "authorization_code")
+	form.Add("redirect_uri", g.config.RedirectURL)
+
+	url := g.getURL("TOKEN")
+
+	resp, err := g.postToGitlab(url, form)
+	if err != nil {
+		log.Errorf("Gitlab getAccessToken: received error from gitlab, err: %v", err)
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Decode the response
+	var respMap map[string]interface{}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Errorf("Gitlab getAccessToken: received error reading response body, err: %v", err)
+		return "", err
+	}
+
+	if err := json.Unmarshal(b, &respMap); err != nil {
+		log.Errorf("Gitlab getAccessToken: received error unmarshalling response body, err: %v", err)
+		return "", err
+	}
+
+	if respMap["error"] != nil {
+		desc := respMap["error_description"]
+		log.Errorf("Received Error from gitlab %v, description from gitlab %v", respMap["error"], desc)
+		return "", fmt.Errorf("Received Error from gitlab %v, description from gitlab %v", respMap["error"], desc)
+	}
+
+	accessToken, ok := respMap["access_token"].(string)
+	if !ok {
+		return "", fmt.Errorf("Received Error reading accessToken from response %v", respMap)
+	}
+	return accessToken, nil
+}
+
+func (g *GLClient) getGitlabUser(gitlabAccessToken string) (Account, error) {
+	var gitlabAcct Account
+	err := g.getObject(gitlabAccessToken, g.getURL("USER_INFO"), &gitlabAcct)
+	if err != nil {
+		log.Errorf("Gitlab getGitlabUser: received error from gitlab, err: %v", err)
+		return Account{}, err
+	}
+	return gitlabAcct, nil
+}
+
+//getGitlabGroups returns the groups and subgroups the user is a member of, directly or inherited
+func (g *GLClient) getGitlabGroups(gitlabAccessToken string) ([]Account, error) {
+	var groups []Account
+	responses, err := g.paginateGitlab(gitlabAccessToken, g.getURL("GROUP_INFO"))
+	if err != nil {
+		log.Errorf("Gitlab getGitlabGroups: received error from gitlab, err: %v", err)
+		return groups, err
+	}
+
+	for _, response := range responses {
+		defer response.Body.Close()
+		var groupObjs []Group
+		b, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			log.Errorf("Gitlab getGitlabGroups: error reading the response from gitlab, err: %v", err)
+			return groups, err
+		}
+		if err := json.Unmarshal(b, &groupObjs); err != nil {
+			log.Errorf("Gitlab getGitlabGroups: received error unmarshalling group array, err: %v", err)
+			return groups, err
+		}
+		for _, groupObj := range groupObjs {
+			groupAcct := Account{}
+			groupObj.toGitlabAccount(&groupAcct)
+			groups = append(groups, groupAcct)
+		}
+	}
+
+	return groups, nil
+}
+
+func (g *GLClient) paginateGitlab(gitlabAccessToken string, url string) ([]*http.Response, error) {
+	var responses []*http.Response
+
+	response, err := g.getFromGitlab(gitlabAccessToken, url)
+	if err != nil {
+		return responses, err
+	}
+	responses = append(responses, response)
+	nextURL, err := g.nextGitlabPage(response)
+	for nextURL != "" && err == nil {
+		response, err = g.getFromGitlab(gitlabAccessToken, nextURL)
+		if err != nil {
+			break
+		}
+		responses = append(responses, response)
+		nextURL, err = g.nextGitlabPage(response)
+	}
+	if err != nil {
+		for _, response := range responses {
+			response.Body.Close()
+		}
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+//nextGitlabPage returns the next link of the response, it must be on the scheme and host of the gitlab API
+//so that the token is only sent there
+func (g *GLClient) nextGitlabPage(response *http.Response) (string, error) {
+	header := response.Header.Get("link")
+
+	if header != "" {
+		links := linkheader.Parse(header)
+		for _, link := range links {
+			if link.Rel == "next" {
+				if !g.isAPIURL(link.URL) {
+					return "", fmt.Errorf("The next page link %v is not on the gitlab API %v", link.URL, g.getURL("API"))
+				}
+				return link.URL, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+func (g *GLClient) isAPIURL(link string) bool {
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	apiURL, err := url.Parse(g.getURL("API"))
+	if err != nil {
+		return false
+	}
+	return linkURL.Scheme == apiURL.Scheme && strings.EqualFold(linkURL.Host, apiURL.Host)
+}
+
+func (g *GLClient) getUserByID(id string, gitlabAccessToken string) (Account, error) {
+	var gitlabAcct Account
+	err := g.getObject(gitlabAccessToken, g.getURL("USERS")+"/"+url.QueryEscape(id), &gitlabAcct)
+	if err != nil {
+		log.Errorf("Gitlab getUserByID: received error from gitlab, err: %v", err)
+		return Account{}, err
+	}
+	return gitlabAcct, nil
+}
+
+//getGroupByID accepts the numeric id or the full path of the group
+func (g *GLClient) getGroupByID(id string, gitlabAccessToken string) (Account, error) {
+	var groupObj Group
+	err := g.getObject(gitlabAccessToken, g.getURL("GROUPS")+"/"+url.QueryEscape(id)+"?with_projects=false", &groupObj)
+	if err != nil {
+		log.Errorf("Gitlab getGroupByID: received error from gitlab, err: %v", err)
+		return Account{}, err
+	}
+	groupAcct := Account{}
+	groupObj.toGitlabAccount(&groupAcct)
+	return groupAcct, nil
+}
+
+func (g *GLClient) searchUsers(name string, exactMatch bool, gitlabAccessToken string) ([]Account, error) {
+	var users []Account
+	query := "?search="
+	if exactMatch {
+		query = "?username="
+	}
+	err := g.getObject(gitlabAccessToken, g.getURL("USERS")+query+url.QueryEscape(name), &users)
+	if err != nil {
+		log.Errorf("Gitlab searchUsers: received error from gitlab, err: %v", err)
+		return users, err
+	}
+	return users, nil
+}
+
+func (g *GLClient) searchGroups(name string, exactMatch bool, gitlabAccessToken string) ([]Account, error) {
+	if exactMatch {
+		groupAcct, err := g.getGroupByID(name, gitlabAccessToken)
+		if err != nil {
+			return []Account{}, err
+		}
+		return []Account{groupAcct}, nil
+	}
+
+	var groups []Account
+	var groupObjs []Group
+	err := g.getObject(gitlabAccessToken, g.getURL("GROUPS")+"?search="+url.QueryEscape(name), &groupObjs)
+	if err != nil {
+		log.Errorf("Gitlab searchGroups: received error from gitlab, err: %v", err)
+		return groups, err
+	}
+	for _, groupObj := range groupObjs {
+		groupAcct := Account{}
+		groupObj.toGitlabAccount(&groupAcct)
+		groups = append(groups, groupAcct)
+	}
+	return groups, nil
+}
+
+func (g *GLClient) getObject(gitlabAccessToken string, url string, v interface{}) error {
+	log.Debugf("url %v", url)
+	resp, err := g.getFromGitlab(gitlabAccessToken, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func (g *GLClient) postToGitlab(url string, form url.Values) (*http.Response, error) {
+	req, err := http.NewRequest("POST", url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Accept", "application/json")
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		log.Errorf("Received error from gitlab: %v", err)
+		return resp, err
+	}
+	// Check the status code
+	switch resp.StatusCode {
+	case 200:
+	case 201:
+	default:
+		defer resp.Body.Close()
+		var body bytes.Buffer
+		io.Copy(&body, resp.Body)
+		return resp, fmt.Errorf("Request failed, got status code: %d. Response: %s",
+			resp.StatusCode, body.Bytes())
+	}
+	return resp, nil
+}
+
+func (g *GLClient) getFromGitlab(gitlabAccessToken string, url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "Bearer "+gitlabAccessToken)
+	req.Header.Add("Accept", "application/json")
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		log.Errorf("Received error from gitlab: %v", err)
+		return resp, err
+	}
+	// Check the status code
+	switch resp.StatusCode {
+	case 200:
+	case 201:
+	default:
+		defer resp.Body.Close()
+		var body bytes.Buffer
+		io.Copy(&body, resp.Body)
+		return resp, fmt.Errorf("Request failed, got status code: %d. Response: %s",
+			resp.StatusCode, body.Bytes())
+	}
+	return resp, nil
+}
+
+func (g *GLClient) getURL(endpoint string) string {
+
+	var hostName, apiEndpoint, toReturn string
+
+	if g.config.Hostname != "" {
+		hostName = g.config.Scheme + g.config.Hostname
+	} else {
+		hostName = gitlabDefaultHostName
+	}
+	apiEndpoint = hostName + gitlabAPI
+
+	switch endpoint {
+	case "API":
+		toReturn = apiEndpoint
+	case "TOKEN":
+		toReturn = hostName + "/oauth/token"
+	case "USERS":
+		toReturn = apiEndpoint + "/users"
+	case "GROUPS":
+		toReturn = apiEndpoint + "/groups"
+	case "USER_INFO":
+		toReturn = apiEndpoint + "/user"
+	case "GROUP_INFO":
+		toReturn = apiEndpoint + "/groups?min_access_level=10&per_page=100"
+	default:
+		toReturn = apiEndpoint
+	}
+
+	return toReturn
+}
@@ -0,0 +1,31 @@
+package gitlab
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+func TestNextGitlabPageStaysOnTheAPIHost(t *testing.T) {
+	gitlabClient := &GLClient{config: &model.GitlabConfig{Hostname: "gitlab.example.com", Scheme: "https://"}}
+	page := func(link string) *http.Response {
+		return &http.Response{Header: http.Header{"Link": []string{link}}}
+	}
+
+	next, err := gitlabClient.nextGitlabPage(page(`<https://gitlab.example.com/api/v4/groups?page=2>; rel="next"`))
+	if err != nil || next != "https://gitlab.example.com/api/v4/groups?page=2" {
+		t.Fatalf("Unexpected next page %v, %v", next, err)
+	}
+	if next, err := gitlabClient.nextGitlabPage(page(`<https://gitlab.example.com/api/v4/groups?page=1>; rel="prev"`)); err != nil || next != "" {
+		t.Fatalf("Expected no next page, got %v, %v", next, err)
+	}
+	for _, link := range []string{
+		`<https://attacker.example.com/api/v4/groups?page=2>; rel="next"`,
+		`<http://gitlab.example.com/api/v4/groups?page=2>; rel="next"`,
+	} {
+		if _, err := gitlabClient.nextGitlabPage(page(link)); err == nil {
+			t.Errorf("Expected the next page %v to be refused", link)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package gitlab
+
+import (
+	"fmt"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/go-rancher/client"
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+//Constants for gitlab
+const (
+	Name                = "gitlab"
+	Config              = Name + "config"
+	TokenType           = Name + "jwt"
+	UserType            = Name + "_user"
+	GroupType           = Name + "_group"
+	hostnameSetting     = "api.gitlab.domain"
+	schemeSetting       = "api.gitlab.scheme"
+	clientIDSetting     = "api.auth.gitlab.client.id"
+	clientSecretSetting = "api.auth.gitlab.client.secret"
+	redirectURLSetting  = "api.auth.gitlab.redirect.url"
+)
+
+//InitializeProvider returns a new instance of the provider
+func InitializeProvider() *GLProvider {
+	gitlabClient := &GLClient{}
+	gitlabClient.httpClient = &http.Client{}
+
+	gitlabProvider := &GLProvider{}
+	gitlabProvider.gitlabClient = gitlabClient
+
+	return gitlabProvider
+}
+
+//GLProvider implements an IdentityProvider for gitlab
+type GLProvider struct {
+	gitlabClient *GLClient
+}
+
+//GetName returns the name of the provider
+func (g *GLProvider) GetName() string {
+	return Name
+}
+
+//GetEndpoint returns the gitlab API url
+func (g *GLProvider) GetEndpoint() string {
+	return g.gitlabClient.getURL("API")
+}
+
+//GenerateToken authenticates the given code and returns the token
+func (g *GLProvider) GenerateToken(securityCode string) (model.Token, error) {
+	log.Debug("GitlabIdentityProvider GenerateToken called")
+	accessToken, err := g.gitlabClient.getAccessToken(securityCode)
+	if err != nil {
+		log.Errorf("Error generating accessToken from gitlab %v", err)
+		return model.Token{}, err
+	}
+	return g.createToken(accessToken)
+}
+
+func (g *GLProvider) createToken(accessToken string) (model.Token, error) {
+	var token model.Token
+	token.AccessToken = accessToken
+	identities, err := g.GetIdentities(accessToken)
+	if err != nil {
+		log.Errorf("Error getting identities using accessToken from gitlab %v", err)
+		return model.Token{}, err
+	}
+	token.IdentityList = identities
+	token.Type = TokenType
+	user, ok := getUserIdentity(identities)
+	if !ok {
+		log.Error("User identity not found using accessToken from gitlab")
+		return model.Token{}, fmt.Errorf("User identity not found using accessToken from gitlab")
+	}
+	token.ExternalAccountID = user.ExternalId
+	return token, nil
+}
+
+func getUserIdentity(identities []client.Identity) (client.Identity, bool) {
+	for _, identity := range identities {
+		if identity.ExternalIdType == UserType {
+			return identity, true
+		}
+	}
+	return client.Identity{}, false
+}
+
+//RefreshToken re-authenticates and generate a new token
+func (g *GLProvider) RefreshToken(accessToken string) (model.Token, error) {
+	log.Debug("GitlabIdentityProvider RefreshToken called")
+	return g.createToken(accessToken)
+}
+
+//GetIdentities returns list of user and group identities associated to this token
+func (g *GLProvider) GetIdentities(accessToken string) ([]client.Identity, error) {
+	var identities []client.Identity
+
+	userAcct, err := g.gitlabClient.getGitlabUser(accessToken)
+	if err != nil {
+		return identities, err
+	}
+	userIdentity := client.Identity{Resource: client.Resource{
+		Type: "identity",
+	}}
+	userAcct.toIdentity(UserType, &userIdentity)
+	identities = append(identities, userIdentity)
+
+	groupAccts, err := g.gitlabClient.getGitlabGroups(accessToken)
+	if err == nil {
+		for _, groupAcct := range groupAccts {
+			groupIdentity := client.Identity{Resource: client.Resource{
+				Type: "identity",
+			}}
+			groupAcct.toIdentity(GroupType, &groupIdentity)
+			identities = append(identities, groupIdentity)
+		}
+	}
+
+	return identities, nil
+}
+
+//GetIdentity returns the identity by externalID and externalIDType
+func (g *GLProvider) GetIdentity(externalID string, externalIDType string, accessToken string) (client.Identity, error) {
+	identity := client.Identity{Resource: client.Resource{
+		Type: "identity",
+	}}
+
+	switch externalIDType {
+	case UserType:
+		gitlabAcct, err := g.gitlabClient.getUserByID(externalID, accessToken)
+		if err != nil {
+			return identity, err
+		}
+		gitlabAcct.toIdentity(externalIDType, &identity)
+		return identity, nil
+	case GroupType:
+		gitlabAcct, err := g.gitlabClient.getGroupByID(externalID, accessToken)
+		if err != nil {
+			return identity, err
+		}
+		gitlabAcct.toIdentity(externalIDType, &identity)
+		return identity, nil
+	default:
+		log.Debugf("Cannot get the gitlab account due to invalid externalIDType %v", externalIDType)
+		return identity, fmt.Errorf("Cannot get the gitlab account due to invalid externalIDType %v", externalIDType)
+	}
+}
+
+//SearchIdentities returns the identity by name, groups are matched on their full path
+func (g *GLProvider) SearchIdentities(name string, exactMatch bool, accessToken string) ([]client.Identity, error) {
+	var identities []client.Identity
+
+	userAccts, err := g.gitlabClient.searchUsers(name, exactMatch, accessToken)
+	if err == nil {
+		for _, userAcct := range userAccts {
+			userIdentity := client.Identity{Resource: client.Resource{
+				Type: "identity",
+			}}
+			userAcct.toIdentity(UserType, &userIdentity)
+			identities = append(identities, userIdentity)
+		}
+	}
+
+	groupAccts, err := g.gitlabClient.searchGroups(name, exactMatch, accessToken)
+	if err == nil {
+		for _, groupAcct := range groupAccts {
+			groupIdentity := client.Identity{Resource: client.Resource{
+				Type: "identity",
+			}}
+			groupAcct.toIdentity(GroupType, &groupIdentity)
+			identities = append(identities, groupIdentity)
+		}
+	}
+
+	return identities, nil
+}
+
+//LoadConfig initializes the provider with the passes config
+func (g *GLProvider) LoadConfig(authConfig model.AuthConfig) error {
+	configObj := authConfig.GitlabConfig
+	if configObj.ClientID == "" || configObj.ClientSecret == "" {
+		return fmt.Errorf("Missing ClientID or ClientSecret in gitlabConfig")
+	}
+	if configObj.RedirectURL == "" {
+		return fmt.Errorf("Missing RedirectURL in gitlabConfig")
+	}
+	g.gitlabClient.config = &configObj
+	return nil
+}
+
+//GetConfig returns the provider config
+func (g *GLProvider) GetConfig() model.AuthConfig {
+	log.Debug("In gitlab getConfig")
+
+	authConfig := model.AuthConfig{Resource: client.Resource{
+		Type: "config",
+	}}
+
+	authConfig.Provider = Config
+	authConfig.GitlabConfig = *g.gitlabClient.config
+
+	authConfig.GitlabConfig.Resource = client.Resource{
+		Type: "gitlabconfig",
+	}
+
+	return authConfig
+}
+
+//GetSettings transforms the provider config to db settings
+func (g *GLProvider) GetSettings() map[string]string {
+	settings := make(map[string]string)
+
+	settings[hostnameSetting] = g.gitlabClient.config.Hostname
+	settings[schemeSetting] = g.gitlabClient.config.Scheme
+	settings[clientIDSetting] = g.gitlabClient.config.ClientID
+	settings[clientSecretSetting] = g.gitlabClient.config.ClientSecret
+	settings[redirectURLSetting] = g.gitlabClient.config.RedirectURL
+
+	return settings
+}
+
+//GetProviderSettingList returns the provider specific db setting list
+func (g *GLProvider) GetProviderSettingList() []string {
+	var settings []string
+	settings = append(settings, hostnameSetting)
+	settings = append(settings, schemeSetting)
+	settings = append(settings, clientIDSetting)
+	settings = append(settings, clientSecretSetting)
+	settings = append(settings, redirectURLSetting)
+	return settings
+}
+
+//AddProviderConfig adds the provider config into the generic config using the settings from db
+func (g *GLProvider) AddProviderConfig(authConfig *model.AuthConfig, providerSettings map[string]string) {
+	gitlabConfig := model.GitlabConfig{Resource: client.Resource{
+		Type: "gitlabconfig",
+	}}
+	gitlabConfig.Hostname = providerSettings[hostnameSetting]
+	gitlabConfig.Scheme = providerSettings[schemeSetting]
+	gitlabConfig.ClientID = providerSettings[clientIDSetting]
+	gitlabConfig.ClientSecret = providerSettings[clientSecretSetting]
+	gitlabConfig.RedirectURL = providerSettings[redirectURLSetting]
+
+	authConfig.GitlabConfig = gitlabConfig
+}
@@ -3,7 +3,15 @@ package providers
 import (
 	"github.com/rancher/go-rancher/client"
 	"github.com/rancher/rancher-auth-service/model"
+	"github.com/rancher/rancher-auth-service/providers/azuread"
+	"github.com/rancher/rancher-auth-service/providers/bitbucket"
+	"github.com/rancher/rancher-auth-service/providers/file"
 	"github.com/rancher/rancher-auth-service/providers/github"
+	"github.com/rancher/rancher-auth-service/providers/gitlab"
+	"github.com/rancher/rancher-auth-service/providers/ldap"
+	"github.com/rancher/rancher-auth-service/providers/local"
+	"github.com/rancher/rancher-auth-service/providers/oidc"
+	"github.com/rancher/rancher-auth-service/providers/saml"
 )
 
 //IdentityProvider interfacse defines what methods an identity provider should implement
@@ -21,11 +29,61 @@ type IdentityProvider interface {
 	AddProviderConfig(authConfig *model.AuthConfig, providerSettings map[string]string)
 }
 
+//IdentifierAccessTokenProvider is implemented by the providers whose access token identifies the user rather than
+//being a secret, the service only trusts it from the encrypted claim of the tokens it issued
+type IdentifierAccessTokenProvider interface {
+	AccessTokenIsIdentifier() bool
+}
+
+//SessionAccessTokenProvider is implemented by the providers whose access token can be a session asserting the
+//identities of the login, the service refuses a session passed as a bearer rather than in the encrypted claim
+type SessionAccessTokenProvider interface {
+	IsSessionAccessToken(accessToken string) bool
+}
+
+//EndpointProvider is implemented by the providers talking to a remote server, the readiness check dials it
+type EndpointProvider interface {
+	//GetEndpoint returns the url or the host:port of the server, empty when it is not known
+	GetEndpoint() string
+}
+
+//ProviderNames lists the providers GetProvider returns
+var ProviderNames = []string{
+	"githubconfig",
+	"gitlabconfig",
+	"bitbucketconfig",
+	"fileconfig",
+	"localconfig",
+	"ldapconfig",
+	"openldapconfig",
+	"oidcconfig",
+	"samlconfig",
+	"azureadconfig",
+}
+
 //GetProvider returns an instance of an identyityProvider by name
 func GetProvider(name string) IdentityProvider {
 	switch name{
 		case "githubconfig":
 			return github.InitializeProvider()
+		case "gitlabconfig":
+			return gitlab.InitializeProvider()
+		case "bitbucketconfig":
+			return bitbucket.InitializeProvider()
+		case "fileconfig":
+			return file.InitializeProvider()
+		case "localconfig":
+			return local.InitializeProvider()
+		case "ldapconfig":
+			return ldap.InitializeProvider()
+		case "openldapconfig":
+			return ldap.InitializeOpenLdapProvider()
+		case "oidcconfig":
+			return oidc.InitializeProvider()
+		case "samlconfig":
+			return saml.InitializeProvider()
+		case "azureadconfig":
+			return azuread.InitializeProvider()
 		default: 
 			return nil	
 	}
@@ -0,0 +1,24 @@
+package ldap
+
+import (
+	"github.com/rancher/go-rancher/client"
+)
+
+//Account defines properties a user or group entry in the directory has
+type Account struct {
+	DN    string
+	Login string
+	Name  string
+}
+
+func (a *Account) toIdentity(externalIDType string, identity *client.Identity) {
+	identity.ExternalId = a.DN
+	identity.Resource.Id = externalIDType + ":" + a.DN
+	identity.ExternalIdType = externalIDType
+	if a.Name != "" {
+		identity.Name = a.Name
+	} else {
+		identity.Name = a.Login
+	}
+	identity.Login = a.Login
+}
@@ -0,0 +1,351 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"gopkg.in/ldap.v3"
+
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+const (
+	defaultPort        = 389
+	defaultTLSPort     = 636
+	memberUIDAttribute = "memberUid"
+	upnAttribute       = "userPrincipalName"
+)
+
+//LClient implements a client for an Active Directory/LDAP server
+type LClient struct {
+	config *model.LdapConfig
+	//searchGroupMembership looks up groups by their member attribute instead of relying on the user memberOf only
+	searchGroupMembership bool
+}
+
+//address returns the host:port of the server, the port defaults by the TLS setting
+func (l *LClient) address() string {
+	port := l.config.Port
+	if port == 0 {
+		if l.config.TLS {
+			port = defaultTLSPort
+		} else {
+			port = defaultPort
+		}
+	}
+	return net.JoinHostPort(l.config.Server, strconv.FormatInt(port, 10))
+}
+
+func (l *LClient) newConnection() (*ldap.Conn, error) {
+	address := l.address()
+
+	dialer := &net.Dialer{}
+	if l.config.ConnectionTimeout > 0 {
+		dialer.Timeout = time.Duration(l.config.ConnectionTimeout) * time.Millisecond
+	}
+
+	var netConn net.Conn
+	var err error
+	if l.config.TLS {
+		netConn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: l.config.Server})
+	} else {
+		netConn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		log.Errorf("Ldap newConnection: error connecting to %v, err: %v", address, err)
+		return nil, err
+	}
+
+	conn := ldap.NewConn(netConn, l.config.TLS)
+	if dialer.Timeout > 0 {
+		conn.SetTimeout(dialer.Timeout)
+	}
+	conn.Start()
+	return conn, nil
+}
+
+//newServiceConnection returns a connection bound with the service account
+func (l *LClient) newServiceConnection() (*ldap.Conn, error) {
+	conn, err := l.newConnection()
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Bind(l.qualifiedUsername(l.config.ServiceAccountUsername), l.config.ServiceAccountPassword); err != nil {
+		conn.Close()
+		log.Errorf("Ldap newServiceConnection: error binding the service account, err: %v", err)
+		return nil, err
+	}
+	return conn, nil
+}
+
+//qualifiedUsername prefixes the login domain for usernames that are not already qualified
+func (l *LClient) qualifiedUsername(username string) string {
+	if l.config.LoginDomain == "" || strings.Contains(username, "\\") || strings.Contains(username, "@") || strings.Contains(username, "=") {
+		return username
+	}
+	return l.config.LoginDomain + "\\" + username
+}
+
+//authenticate looks up the user with the service account, binds with the user credentials and returns the user account
+func (l *LClient) authenticate(username string, password string) (Account, []Account, error) {
+	if password == "" {
+		//an empty password would result in an unauthenticated bind being accepted
+		return Account{}, nil, fmt.Errorf("Password is required to authenticate user %v", username)
+	}
+
+	conn, err := l.newServiceConnection()
+	if err != nil {
+		return Account{}, nil, err
+	}
+	defer conn.Close()
+
+	login := username
+	if i := strings.LastIndex(login, "\\"); i >= 0 {
+		login = login[i+1:]
+	}
+	loginFilter := fmt.Sprintf("(%v=%v)", l.config.UserLoginField, ldap.EscapeFilter(login))
+	if strings.Contains(login, "@") {
+		//a user@domain login is the Active Directory userPrincipalName, or a login field holding the mail address
+		loginFilter = fmt.Sprintf("(|(%v=%v)%v)", upnAttribute, ldap.EscapeFilter(login), loginFilter)
+	}
+	filter := fmt.Sprintf("(&(objectClass=%v)%v)", l.config.UserObjectClass, loginFilter)
+	entries, err := l.search(conn, l.config.Domain, ldap.ScopeWholeSubtree, filter, l.userAttributes())
+	if err != nil {
+		return Account{}, nil, err
+	}
+	if len(entries) != 1 {
+		log.Errorf("Ldap authenticate: expected one entry for user %v, found %v", username, len(entries))
+		return Account{}, nil, fmt.Errorf("Invalid credentials for user %v", username)
+	}
+
+	if err := conn.Bind(entries[0].DN, password); err != nil {
+		log.Errorf("Ldap authenticate: error binding user %v, err: %v", username, err)
+		return Account{}, nil, fmt.Errorf("Invalid credentials for user %v", username)
+	}
+
+	//rebind with the service account to resolve the group memberships
+	if err := conn.Bind(l.qualifiedUsername(l.config.ServiceAccountUsername), l.config.ServiceAccountPassword); err != nil {
+		log.Errorf("Ldap authenticate: error binding the service account, err: %v", err)
+		return Account{}, nil, err
+	}
+
+	return l.toUserAccount(conn, entries[0])
+}
+
+//getUserByDN returns the user account and group accounts for the user DN
+func (l *LClient) getUserByDN(dn string) (Account, []Account, error) {
+	conn, err := l.newServiceConnection()
+	if err != nil {
+		return Account{}, nil, err
+	}
+	defer conn.Close()
+
+	filter := fmt.Sprintf("(objectClass=%v)", l.config.UserObjectClass)
+	entries, err := l.search(conn, dn, ldap.ScopeBaseObject, filter, l.userAttributes())
+	if err != nil {
+		return Account{}, nil, err
+	}
+	if len(entries) != 1 {
+		return Account{}, nil, fmt.Errorf("Could not find the user %v", dn)
+	}
+
+	return l.toUserAccount(conn, entries[0])
+}
+
+func (l *LClient) toUserAccount(conn *ldap.Conn, entry *ldap.Entry) (Account, []Account, error) {
+	if !l.isEnabled(entry) {
+		log.Errorf("Ldap toUserAccount: user %v is disabled", entry.DN)
+		return Account{}, nil, fmt.Errorf("User %v is disabled", entry.DN)
+	}
+
+	user := Account{
+		DN:    entry.DN,
+		Login: entry.GetAttributeValue(l.config.UserLoginField),
+		Name:  entry.GetAttributeValue(l.config.UserNameField),
+	}
+
+	var groups []Account
+	seen := make(map[string]bool)
+	for _, groupDN := range entry.GetAttributeValues(l.config.UserMemberAttribute) {
+		group, err := l.getGroup(conn, groupDN)
+		if err != nil {
+			log.Debugf("Ldap toUserAccount: skipping group %v, err: %v", groupDN, err)
+			continue
+		}
+		seen[strings.ToLower(group.DN)] = true
+		groups = append(groups, group)
+	}
+
+	if l.searchGroupMembership {
+		memberGroups, err := l.getGroupsByMember(conn, entry)
+		if err != nil {
+			return Account{}, nil, err
+		}
+		for _, group := range memberGroups {
+			if !seen[strings.ToLower(group.DN)] {
+				seen[strings.ToLower(group.DN)] = true
+				groups = append(groups, group)
+			}
+		}
+	}
+
+	return user, groups, nil
+}
+
+//getGroupsByMember searches the groups listing the user in the group member mapping attribute,
+//posixGroup memberUid values hold the user login while groupOfNames member values hold the user DN
+func (l *LClient) getGroupsByMember(conn *ldap.Conn, entry *ldap.Entry) ([]Account, error) {
+	member := entry.DN
+	if strings.EqualFold(l.config.GroupMemberMappingAttribute, memberUIDAttribute) {
+		member = entry.GetAttributeValue(l.config.UserLoginField)
+	}
+	if member == "" {
+		return nil, nil
+	}
+
+	filter := fmt.Sprintf("(&(objectClass=%v)(%v=%v))", l.config.GroupObjectClass, l.config.GroupMemberMappingAttribute, ldap.EscapeFilter(member))
+	entries, err := l.search(conn, l.config.Domain, ldap.ScopeWholeSubtree, filter, l.groupAttributes())
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []Account
+	for _, groupEntry := range entries {
+		groups = append(groups, l.toGroupAccount(groupEntry))
+	}
+	return groups, nil
+}
+
+func (l *LClient) isEnabled(entry *ldap.Entry) bool {
+	if l.config.UserEnabledAttribute == "" || l.config.UserDisabledBitMask == 0 {
+		return true
+	}
+	value := entry.GetAttributeValue(l.config.UserEnabledAttribute)
+	if value == "" {
+		return true
+	}
+	flags, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Debugf("Ldap isEnabled: cannot parse %v value %v, err: %v", l.config.UserEnabledAttribute, value, err)
+		return true
+	}
+	return flags&l.config.UserDisabledBitMask == 0
+}
+
+func (l *LClient) getGroup(conn *ldap.Conn, dn string) (Account, error) {
+	filter := fmt.Sprintf("(objectClass=%v)", l.config.GroupObjectClass)
+	entries, err := l.search(conn, dn, ldap.ScopeBaseObject, filter, l.groupAttributes())
+	if err != nil {
+		return Account{}, err
+	}
+	if len(entries) != 1 {
+		return Account{}, fmt.Errorf("Could not find the group %v", dn)
+	}
+	return l.toGroupAccount(entries[0]), nil
+}
+
+func (l *LClient) toGroupAccount(entry *ldap.Entry) Account {
+	return Account{
+		DN:    entry.DN,
+		Login: entry.GetAttributeValue(l.config.GroupSearchField),
+		Name:  entry.GetAttributeValue(l.config.GroupNameField),
+	}
+}
+
+//getGroupByDN returns the group account for the group DN
+func (l *LClient) getGroupByDN(dn string) (Account, error) {
+	conn, err := l.newServiceConnection()
+	if err != nil {
+		return Account{}, err
+	}
+	defer conn.Close()
+
+	return l.getGroup(conn, dn)
+}
+
+//searchUsers returns the users whose search field matches the name
+func (l *LClient) searchUsers(name string, exactMatch bool) ([]Account, error) {
+	conn, err := l.newServiceConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	filter := fmt.Sprintf("(&(objectClass=%v)(%v=%v))", l.config.UserObjectClass, l.config.UserSearchField, matchValue(name, exactMatch))
+	entries, err := l.search(conn, l.config.Domain, ldap.ScopeWholeSubtree, filter, l.userAttributes())
+	if err != nil {
+		return nil, err
+	}
+
+	var users []Account
+	for _, entry := range entries {
+		if !l.isEnabled(entry) {
+			continue
+		}
+		users = append(users, Account{
+			DN:    entry.DN,
+			Login: entry.GetAttributeValue(l.config.UserLoginField),
+			Name:  entry.GetAttributeValue(l.config.UserNameField),
+		})
+	}
+	return users, nil
+}
+
+//searchGroups returns the groups whose search field matches the name
+func (l *LClient) searchGroups(name string, exactMatch bool) ([]Account, error) {
+	conn, err := l.newServiceConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	filter := fmt.Sprintf("(&(objectClass=%v)(%v=%v))", l.config.GroupObjectClass, l.config.GroupSearchField, matchValue(name, exactMatch))
+	entries, err := l.search(conn, l.config.Domain, ldap.ScopeWholeSubtree, filter, l.groupAttributes())
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []Account
+	for _, entry := range entries {
+		groups = append(groups, l.toGroupAccount(entry))
+	}
+	return groups, nil
+}
+
+func matchValue(name string, exactMatch bool) string {
+	if exactMatch {
+		return ldap.EscapeFilter(name)
+	}
+	return ldap.EscapeFilter(name) + "*"
+}
+
+func (l *LClient) search(conn *ldap.Conn, baseDN string, scope int, filter string, attributes []string) ([]*ldap.Entry, error) {
+	log.Debugf("Ldap search: base %v, filter %v", baseDN, filter)
+	request := ldap.NewSearchRequest(baseDN, scope, ldap.NeverDerefAliases, 0, 0, false, filter, attributes, nil)
+	result, err := conn.Search(request)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, nil
+		}
+		log.Errorf("Ldap search: error searching %v with filter %v, err: %v", baseDN, filter, err)
+		return nil, err
+	}
+	return result.Entries, nil
+}
+
+func (l *LClient) userAttributes() []string {
+	attributes := []string{"objectClass", l.config.UserLoginField, l.config.UserNameField, l.config.UserMemberAttribute}
+	if l.config.UserEnabledAttribute != "" {
+		attributes = append(attributes, l.config.UserEnabledAttribute)
+	}
+	return attributes
+}
+
+func (l *LClient) groupAttributes() []string {
+	return []string{"objectClass", l.config.GroupSearchField, l.config.GroupNameField}
+}
@@ -0,0 +1,438 @@
+package ldap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/go-rancher/client"
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+//Constants for ldap
+const (
+	Name                               = "ldap"
+	Config                             = Name + "config"
+	TokenType                          = Name + "jwt"
+	UserType                           = Name + "_user"
+	GroupType                          = Name + "_group"
+	OpenLdapName                       = "openldap"
+	OpenLdapConfig                     = OpenLdapName + "config"
+	OpenLdapTokenType                  = OpenLdapName + "jwt"
+	OpenLdapUserType                   = OpenLdapName + "_user"
+	OpenLdapGroupType                  = OpenLdapName + "_group"
+	serverSetting                      = "server"
+	portSetting                        = "port"
+	tlsSetting                         = "tls"
+	domainSetting                      = "domain"
+	loginDomainSetting                 = "login.domain"
+	connectionTimeoutSetting           = "connection.timeout"
+	serviceAccountUsernameSetting      = "service.account.user"
+	serviceAccountPasswordSetting      = "service.account.password"
+	userSearchFieldSetting             = "user.search.field"
+	userLoginFieldSetting              = "user.login.field"
+	userObjectClassSetting             = "user.object.class"
+	userNameFieldSetting               = "user.name.field"
+	userEnabledAttributeSetting        = "user.enabled.attribute"
+	userDisabledBitMaskSetting         = "user.disabled.bit.mask"
+	userMemberAttributeSetting         = "user.member.attribute"
+	groupSearchFieldSetting            = "group.search.field"
+	groupObjectClassSetting            = "group.object.class"
+	groupNameFieldSetting              = "group.name.field"
+	groupMemberMappingAttributeSetting = "group.member.mapping.attribute"
+)
+
+//InitializeProvider returns a new instance of the Active Directory provider
+func InitializeProvider() *LProvider {
+	ldapProvider := &LProvider{
+		name:      Name,
+		tokenType: TokenType,
+		userType:  UserType,
+		groupType: GroupType,
+	}
+	ldapProvider.ldapClient = &LClient{}
+
+	return ldapProvider
+}
+
+//InitializeOpenLdapProvider returns a new instance of the OpenLDAP provider
+func InitializeOpenLdapProvider() *LProvider {
+	ldapProvider := &LProvider{
+		name:      OpenLdapName,
+		tokenType: OpenLdapTokenType,
+		userType:  OpenLdapUserType,
+		groupType: OpenLdapGroupType,
+	}
+	ldapProvider.ldapClient = &LClient{searchGroupMembership: true}
+
+	return ldapProvider
+}
+
+//LProvider implements an IdentityProvider for Active Directory and OpenLDAP
+type LProvider struct {
+	name       string
+	tokenType  string
+	userType   string
+	groupType  string
+	ldapClient *LClient
+}
+
+func (l *LProvider) isOpenLdap() bool {
+	return l.name == OpenLdapName
+}
+
+//setting returns the db setting name, e.g. api.auth.openldap.server
+func (l *LProvider) setting(name string) string {
+	return "api.auth." + l.name + "." + name
+}
+
+//GetName returns the name of the provider
+func (l *LProvider) GetName() string {
+	return l.name
+}
+
+//GetEndpoint returns the host:port of the ldap server
+func (l *LProvider) GetEndpoint() string {
+	return l.ldapClient.address()
+}
+
+//GenerateToken authenticates the "username:password" code and returns the token
+func (l *LProvider) GenerateToken(securityCode string) (model.Token, error) {
+	log.Debug("LdapIdentityProvider GenerateToken called")
+	parts := strings.SplitN(securityCode, ":", 2)
+	if len(parts) != 2 {
+		return model.Token{}, fmt.Errorf("Malformed code, expected username:password")
+	}
+
+	user, groups, err := l.ldapClient.authenticate(parts[0], parts[1])
+	if err != nil {
+		log.Errorf("Error authenticating the user with ldap %v", err)
+		return model.Token{}, err
+	}
+	return l.createToken(user, groups), nil
+}
+
+func (l *LProvider) createToken(user Account, groups []Account) model.Token {
+	var token model.Token
+	//the user DN is used as the accessToken to look up the identities later, it is only trusted from the
+	//encrypted claim of the service token since anyone can know a DN
+	token.AccessToken = user.DN
+	token.IdentityList = l.toIdentities(user, groups)
+	token.Type = l.tokenType
+	token.ExternalAccountID = user.DN
+	return token
+}
+
+func (l *LProvider) toIdentities(user Account, groups []Account) []client.Identity {
+	var identities []client.Identity
+
+	userIdentity := client.Identity{Resource: client.Resource{
+		Type: "identity",
+	}}
+	user.toIdentity(l.userType, &userIdentity)
+	identities = append(identities, userIdentity)
+
+	for _, group := range groups {
+		groupIdentity := client.Identity{Resource: client.Resource{
+			Type: "identity",
+		}}
+		group.toIdentity(l.groupType, &groupIdentity)
+		identities = append(identities, groupIdentity)
+	}
+
+	return identities
+}
+
+//AccessTokenIsIdentifier tells that the access token is the user DN, it must not be accepted as a bearer
+func (l *LProvider) AccessTokenIsIdentifier() bool {
+	return true
+}
+
+//RefreshToken re-reads the user from the directory and generate a new token
+func (l *LProvider) RefreshToken(accessToken string) (model.Token, error) {
+	log.Debugf("LdapIdentityProvider RefreshToken called for accessToken %v", accessToken)
+	user, groups, err := l.ldapClient.getUserByDN(accessToken)
+	if err != nil {
+		return model.Token{}, err
+	}
+	return l.createToken(user, groups), nil
+}
+
+//GetIdentities returns list of user and group identities associated to this token
+func (l *LProvider) GetIdentities(accessToken string) ([]client.Identity, error) {
+	user, groups, err := l.ldapClient.getUserByDN(accessToken)
+	if err != nil {
+		return []client.Identity{}, err
+	}
+	return l.toIdentities(user, groups), nil
+}
+
+//GetIdentity returns the identity by externalID and externalIDType
+func (l *LProvider) GetIdentity(externalID string, externalIDType string, accessToken string) (client.Identity, error) {
+	identity := client.Identity{Resource: client.Resource{
+		Type: "identity",
+	}}
+
+	switch externalIDType {
+	case l.userType:
+		user, _, err := l.ldapClient.getUserByDN(externalID)
+		if err != nil {
+			return identity, err
+		}
+		user.toIdentity(externalIDType, &identity)
+		return identity, nil
+	case l.groupType:
+		group, err := l.ldapClient.getGroupByDN(externalID)
+		if err != nil {
+			return identity, err
+		}
+		group.toIdentity(externalIDType, &identity)
+		return identity, nil
+	default:
+		log.Debugf("Cannot get the ldap account due to invalid externalIDType %v", externalIDType)
+		return identity, fmt.Errorf("Cannot get the ldap account due to invalid externalIDType %v", externalIDType)
+	}
+}
+
+//SearchIdentities returns the identity by name
+func (l *LProvider) SearchIdentities(name string, exactMatch bool, accessToken string) ([]client.Identity, error) {
+	var identities []client.Identity
+
+	users, err := l.ldapClient.searchUsers(name, exactMatch)
+	if err != nil {
+		return identities, err
+	}
+	for _, user := range users {
+		userIdentity := client.Identity{Resource: client.Resource{
+			Type: "identity",
+		}}
+		user.toIdentity(l.userType, &userIdentity)
+		identities = append(identities, userIdentity)
+	}
+
+	groups, err := l.ldapClient.searchGroups(name, exactMatch)
+	if err != nil {
+		return identities, err
+	}
+	for _, group := range groups {
+		groupIdentity := client.Identity{Resource: client.Resource{
+			Type: "identity",
+		}}
+		group.toIdentity(l.groupType, &groupIdentity)
+		identities = append(identities, groupIdentity)
+	}
+
+	return identities, nil
+}
+
+//LoadConfig initializes the provider with the passes config
+func (l *LProvider) LoadConfig(authConfig model.AuthConfig) error {
+	configObj := authConfig.LdapConfig
+	if l.isOpenLdap() {
+		configObj = model.LdapConfig(authConfig.OpenLdapConfig)
+	}
+	if configObj.Server == "" || configObj.Domain == "" {
+		return fmt.Errorf("Missing Server or Domain in %vConfig", l.name)
+	}
+	if configObj.ServiceAccountUsername == "" || configObj.ServiceAccountPassword == "" {
+		return fmt.Errorf("Missing ServiceAccountUsername or ServiceAccountPassword in %vConfig", l.name)
+	}
+	if l.isOpenLdap() {
+		setOpenLdapDefaults(&configObj)
+	} else {
+		setDefaults(&configObj)
+	}
+	l.ldapClient.config = &configObj
+	return nil
+}
+
+//setDefaults fills in the Active Directory defaults for the fields not set
+func setDefaults(config *model.LdapConfig) {
+	if config.UserSearchField == "" {
+		config.UserSearchField = "name"
+	}
+	if config.UserLoginField == "" {
+		config.UserLoginField = "sAMAccountName"
+	}
+	if config.UserObjectClass == "" {
+		config.UserObjectClass = "person"
+	}
+	if config.UserNameField == "" {
+		config.UserNameField = "name"
+	}
+	if config.UserEnabledAttribute == "" {
+		config.UserEnabledAttribute = "userAccountControl"
+	}
+	if config.UserDisabledBitMask == 0 {
+		config.UserDisabledBitMask = 2
+	}
+	if config.UserMemberAttribute == "" {
+		config.UserMemberAttribute = "memberOf"
+	}
+	if config.GroupSearchField == "" {
+		config.GroupSearchField = "sAMAccountName"
+	}
+	if config.GroupObjectClass == "" {
+		config.GroupObjectClass = "group"
+	}
+	if config.GroupNameField == "" {
+		config.GroupNameField = "name"
+	}
+	if config.GroupMemberMappingAttribute == "" {
+		config.GroupMemberMappingAttribute = "member"
+	}
+}
+
+//setOpenLdapDefaults fills in the OpenLDAP defaults for the fields not set,
+//groupOfNames membership is used unless configured with posixGroup and memberUid
+func setOpenLdapDefaults(config *model.LdapConfig) {
+	if config.UserSearchField == "" {
+		config.UserSearchField = "uid"
+	}
+	if config.UserLoginField == "" {
+		config.UserLoginField = "uid"
+	}
+	if config.UserObjectClass == "" {
+		config.UserObjectClass = "inetOrgPerson"
+	}
+	if config.UserNameField == "" {
+		config.UserNameField = "cn"
+	}
+	if config.UserMemberAttribute == "" {
+		config.UserMemberAttribute = "memberOf"
+	}
+	if config.GroupSearchField == "" {
+		config.GroupSearchField = "cn"
+	}
+	if config.GroupObjectClass == "" {
+		config.GroupObjectClass = "groupOfNames"
+	}
+	if config.GroupNameField == "" {
+		config.GroupNameField = "cn"
+	}
+	if config.GroupMemberMappingAttribute == "" {
+		if strings.EqualFold(config.GroupObjectClass, "posixGroup") {
+			config.GroupMemberMappingAttribute = memberUIDAttribute
+		} else {
+			config.GroupMemberMappingAttribute = "member"
+		}
+	}
+}
+
+//GetConfig returns the provider config
+func (l *LProvider) GetConfig() model.AuthConfig {
+	log.Debugf("In %v getConfig", l.name)
+
+	authConfig := model.AuthConfig{Resource: client.Resource{
+		Type: "config",
+	}}
+
+	config := *l.ldapClient.config
+	if l.isOpenLdap() {
+		authConfig.Provider = OpenLdapConfig
+		authConfig.OpenLdapConfig = model.OpenLdapConfig(config)
+		authConfig.OpenLdapConfig.Resource = client.Resource{
+			Type: "openldapconfig",
+		}
+		return authConfig
+	}
+
+	authConfig.Provider = Config
+	authConfig.LdapConfig = config
+	authConfig.LdapConfig.Resource = client.Resource{
+		Type: "ldapconfig",
+	}
+
+	return authConfig
+}
+
+//GetSettings transforms the provider config to db settings
+func (l *LProvider) GetSettings() map[string]string {
+	settings := make(map[string]string)
+	config := l.ldapClient.config
+
+	settings[l.setting(serverSetting)] = config.Server
+	settings[l.setting(portSetting)] = strconv.FormatInt(config.Port, 10)
+	settings[l.setting(tlsSetting)] = strconv.FormatBool(config.TLS)
+	settings[l.setting(domainSetting)] = config.Domain
+	settings[l.setting(loginDomainSetting)] = config.LoginDomain
+	settings[l.setting(connectionTimeoutSetting)] = strconv.FormatInt(config.ConnectionTimeout, 10)
+	settings[l.setting(serviceAccountUsernameSetting)] = config.ServiceAccountUsername
+	settings[l.setting(serviceAccountPasswordSetting)] = config.ServiceAccountPassword
+	settings[l.setting(userSearchFieldSetting)] = config.UserSearchField
+	settings[l.setting(userLoginFieldSetting)] = config.UserLoginField
+	settings[l.setting(userObjectClassSetting)] = config.UserObjectClass
+	settings[l.setting(userNameFieldSetting)] = config.UserNameField
+	settings[l.setting(userEnabledAttributeSetting)] = config.UserEnabledAttribute
+	settings[l.setting(userDisabledBitMaskSetting)] = strconv.FormatInt(config.UserDisabledBitMask, 10)
+	settings[l.setting(userMemberAttributeSetting)] = config.UserMemberAttribute
+	settings[l.setting(groupSearchFieldSetting)] = config.GroupSearchField
+	settings[l.setting(groupObjectClassSetting)] = config.GroupObjectClass
+	settings[l.setting(groupNameFieldSetting)] = config.GroupNameField
+	settings[l.setting(groupMemberMappingAttributeSetting)] = config.GroupMemberMappingAttribute
+
+	return settings
+}
+
+//GetProviderSettingList returns the provider specific db setting list
+func (l *LProvider) GetProviderSettingList() []string {
+	var settings []string
+	settings = append(settings, l.setting(serverSetting))
+	settings = append(settings, l.setting(portSetting))
+	settings = append(settings, l.setting(tlsSetting))
+	settings = append(settings, l.setting(domainSetting))
+	settings = append(settings, l.setting(loginDomainSetting))
+	settings = append(settings, l.setting(connectionTimeoutSetting))
+	settings = append(settings, l.setting(serviceAccountUsernameSetting))
+	settings = append(settings, l.setting(serviceAccountPasswordSetting))
+	settings = append(settings, l.setting(userSearchFieldSetting))
+	settings = append(settings, l.setting(userLoginFieldSetting))
+	settings = append(settings, l.setting(userObjectClassSetting))
+	settings = append(settings, l.setting(userNameFieldSetting))
+	settings = append(settings, l.setting(userEnabledAttributeSetting))
+	settings = append(settings, l.setting(userDisabledBitMaskSetting))
+	settings = append(settings, l.setting(userMemberAttributeSetting))
+	settings = append(settings, l.setting(groupSearchFieldSetting))
+	settings = append(settings, l.setting(groupObjectClassSetting))
+	settings = append(settings, l.setting(groupNameFieldSetting))
+	settings = append(settings, l.setting(groupMemberMappingAttributeSetting))
+	return settings
+}
+
+//AddProviderConfig adds the provider config into the generic config using the settings from db
+func (l *LProvider) AddProviderConfig(authConfig *model.AuthConfig, providerSettings map[string]string) {
+	ldapConfig := model.LdapConfig{}
+	ldapConfig.Server = providerSettings[l.setting(serverSetting)]
+	ldapConfig.Port, _ = strconv.ParseInt(providerSettings[l.setting(portSetting)], 10, 64)
+	ldapConfig.TLS, _ = strconv.ParseBool(providerSettings[l.setting(tlsSetting)])
+	ldapConfig.Domain = providerSettings[l.setting(domainSetting)]
+	ldapConfig.LoginDomain = providerSettings[l.setting(loginDomainSetting)]
+	ldapConfig.ConnectionTimeout, _ = strconv.ParseInt(providerSettings[l.setting(connectionTimeoutSetting)], 10, 64)
+	ldapConfig.ServiceAccountUsername = providerSettings[l.setting(serviceAccountUsernameSetting)]
+	ldapConfig.ServiceAccountPassword = providerSettings[l.setting(serviceAccountPasswordSetting)]
+	ldapConfig.UserSearchField = providerSettings[l.setting(userSearchFieldSetting)]
+	ldapConfig.UserLoginField = providerSettings[l.setting(userLoginFieldSetting)]
+	ldapConfig.UserObjectClass = providerSettings[l.setting(userObjectClassSetting)]
+	ldapConfig.UserNameField = providerSettings[l.setting(userNameFieldSetting)]
+	ldapConfig.UserEnabledAttribute = providerSettings[l.setting(userEnabledAttributeSetting)]
+	ldapConfig.UserDisabledBitMask, _ = strconv.ParseInt(providerSettings[l.setting(userDisabledBitMaskSetting)], 10, 64)
+	ldapConfig.UserMemberAttribute = providerSettings[l.setting(userMemberAttributeSetting)]
+	ldapConfig.GroupSearchField = providerSettings[l.setting(groupSearchFieldSetting)]
+	ldapConfig.GroupObjectClass = providerSettings[l.setting(groupObjectClassSetting)]
+	ldapConfig.GroupNameField = providerSettings[l.setting(groupNameFieldSetting)]
+	ldapConfig.GroupMemberMappingAttribute = providerSettings[l.setting(groupMemberMappingAttributeSetting)]
+
+	if l.isOpenLdap() {
+		authConfig.OpenLdapConfig = model.OpenLdapConfig(ldapConfig)
+		authConfig.OpenLdapConfig.Resource = client.Resource{
+			Type: "openldapconfig",
+		}
+		return
+	}
+
+	authConfig.LdapConfig = ldapConfig
+	authConfig.LdapConfig.Resource = client.Resource{
+		Type: "ldapconfig",
+	}
+}
@@ -0,0 +1,432 @@
+package ldap
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.in/asn1-ber.v1"
+	"gopkg.in/ldap.v3"
+
+	"github.com/rancher/go-rancher/client"
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+const (
+	testDomain          = "dc=example,dc=com"
+	testServiceAccount  = "svc"
+	testServicePassword = "svc-pw"
+)
+
+//entry is an object of the stand-in directory, the password is set for the entries that can bind
+type entry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+func (e *entry) values(name string) []string {
+	for attribute, values := range e.attributes {
+		if strings.EqualFold(attribute, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+//directoryStandIn serves the bind and search operations of the LDAP protocol over a directory held in memory
+type directoryStandIn struct {
+	listener net.Listener
+	entries  []*entry
+	binds    map[string]string
+	mutex    sync.Mutex
+	bound    []string
+}
+
+func newDirectoryStandIn(t *testing.T, entries []*entry) *directoryStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	standIn := &directoryStandIn{listener: listener, entries: entries, binds: map[string]string{}}
+	for _, e := range entries {
+		if e.password != "" {
+			standIn.binds[strings.ToLower(e.dn)] = e.password
+		}
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go standIn.serve(conn)
+		}
+	}()
+	return standIn
+}
+
+func (d *directoryStandIn) port() int64 {
+	port, _ := strconv.ParseInt(strings.Split(d.listener.Addr().String(), ":")[1], 10, 64)
+	return port
+}
+
+func (d *directoryStandIn) boundNames() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]string(nil), d.bound...)
+}
+
+func (d *directoryStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		messageID := packet.Children[0].Value.(int64)
+		request := packet.Children[1]
+		switch request.Tag {
+		case ldap.ApplicationBindRequest:
+			name := request.Children[1].Data.String()
+			password := request.Children[2].Data.String()
+			code := uint16(ldap.LDAPResultInvalidCredentials)
+			if expected, ok := d.binds[strings.ToLower(name)]; ok && password != "" && expected == password {
+				code = ldap.LDAPResultSuccess
+				d.mutex.Lock()
+				d.bound = append(d.bound, name)
+				d.mutex.Unlock()
+			}
+			conn.Write(response(messageID, ldap.ApplicationBindResponse, code).Bytes())
+		case ldap.ApplicationSearchRequest:
+			for _, p := range d.search(messageID, request) {
+				conn.Write(p.Bytes())
+			}
+		case ldap.ApplicationUnbindRequest:
+			return
+		default:
+			conn.Write(response(messageID, ldap.ApplicationExtendedResponse, ldap.LDAPResultUnwillingToPerform).Bytes())
+		}
+	}
+}
+
+func (d *directoryStandIn) search(messageID int64, request *ber.Packet) []*ber.Packet {
+	baseDN := strings.ToLower(request.Children[0].Data.String())
+	scope := request.Children[1].Value.(int64)
+	filter := request.Children[6]
+
+	var packets []*ber.Packet
+	found := false
+	for _, e := range d.entries {
+		dn := strings.ToLower(e.dn)
+		if dn == baseDN {
+			found = true
+		}
+		inScope := dn == baseDN
+		if scope == ldap.ScopeWholeSubtree {
+			inScope = inScope || strings.HasSuffix(dn, ","+baseDN)
+		}
+		if !inScope || !matches(e, filter) {
+			continue
+		}
+		result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+		result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, ""))
+		attributes := ber.NewSequence("")
+		for name, values := range e.attributes {
+			attribute := ber.NewSequence("")
+			attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+			set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+			for _, value := range values {
+				set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, ""))
+			}
+			attribute.AppendChild(set)
+			attributes.AppendChild(attribute)
+		}
+		result.AppendChild(attributes)
+		packets = append(packets, envelope(messageID, result))
+	}
+
+	code := uint16(ldap.LDAPResultSuccess)
+	if !found {
+		code = ldap.LDAPResultNoSuchObject
+	}
+	return append(packets, response(messageID, ldap.ApplicationSearchResultDone, code))
+}
+
+//matches evaluates the and, or, not, equality, substrings and present filters, the values are case insensitive
+func matches(e *entry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matches(e, child) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if matches(e, child) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !matches(e, filter.Children[0])
+	case ldap.FilterEqualityMatch:
+		expected := filter.Children[1].Data.String()
+		for _, value := range e.values(filter.Children[0].Data.String()) {
+			if strings.EqualFold(value, expected) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterSubstrings:
+		for _, value := range e.values(filter.Children[0].Data.String()) {
+			value = strings.ToLower(value)
+			matched := true
+			for _, part := range filter.Children[1].Children {
+				substring := strings.ToLower(part.Data.String())
+				switch part.Tag {
+				case ldap.FilterSubstringsInitial:
+					matched = matched && strings.HasPrefix(value, substring)
+				case ldap.FilterSubstringsFinal:
+					matched = matched && strings.HasSuffix(value, substring)
+				default:
+					matched = matched && strings.Contains(value, substring)
+				}
+			}
+			if matched {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		return len(e.values(filter.Data.String())) > 0
+	}
+	return false
+}
+
+func envelope(messageID int64, operation *ber.Packet) *ber.Packet {
+	packet := ber.NewSequence("")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, ""))
+	packet.AppendChild(operation)
+	return packet
+}
+
+func response(messageID int64, tag ber.Tag, code uint16) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	return envelope(messageID, result)
+}
+
+func activeDirectory() []*entry {
+	return []*entry{
+		{dn: testDomain, attributes: map[string][]string{"objectClass": {"domain"}}},
+		{dn: "cn=svc,cn=Users," + testDomain, password: testServicePassword, attributes: map[string][]string{
+			"objectClass": {"person"}, "sAMAccountName": {testServiceAccount}, "name": {"Service"},
+		}},
+		{dn: "cn=Alice Smith,cn=Users," + testDomain, password: "alice-pw", attributes: map[string][]string{
+			"objectClass":        {"top", "person", "user"},
+			"sAMAccountName":     {"alice"},
+			"userPrincipalName":  {"alice@example.com"},
+			"name":               {"Alice Smith"},
+			"userAccountControl": {"512"},
+			"memberOf":           {"cn=Admins,cn=Groups," + testDomain, "cn=Removed,cn=Groups," + testDomain},
+		}},
+		{dn: "cn=Bob,cn=Users," + testDomain, password: "bob-pw", attributes: map[string][]string{
+			"objectClass":        {"person"},
+			"sAMAccountName":     {"bob"},
+			"name":               {"Bob"},
+			"userAccountControl": {"514"},
+		}},
+		{dn: "cn=Admins,cn=Groups," + testDomain, attributes: map[string][]string{
+			"objectClass": {"group"}, "sAMAccountName": {"admins"}, "name": {"Admins"},
+		}},
+	}
+}
+
+func openLdapDirectory() []*entry {
+	return []*entry{
+		{dn: testDomain, attributes: map[string][]string{"objectClass": {"domain"}}},
+		{dn: "uid=svc,ou=people," + testDomain, password: testServicePassword, attributes: map[string][]string{
+			"objectClass": {"inetOrgPerson"}, "uid": {testServiceAccount}, "cn": {"Service"},
+		}},
+		{dn: "uid=alice,ou=people," + testDomain, password: "alice-pw", attributes: map[string][]string{
+			"objectClass": {"inetOrgPerson"}, "uid": {"alice"}, "cn": {"Alice Smith"},
+		}},
+		{dn: "cn=devs,ou=groups," + testDomain, attributes: map[string][]string{
+			"objectClass": {"groupOfNames"}, "cn": {"devs"}, "member": {"uid=alice,ou=people," + testDomain},
+		}},
+		{dn: "cn=ops,ou=groups," + testDomain, attributes: map[string][]string{
+			"objectClass": {"groupOfNames"}, "cn": {"ops"}, "member": {"uid=bob,ou=people," + testDomain},
+		}},
+	}
+}
+
+func identityIDs(identities []client.Identity) string {
+	var ids []string
+	for _, identity := range identities {
+		ids = append(ids, identity.Id)
+	}
+	return strings.Join(ids, ",")
+}
+
+func newActiveDirectoryProvider(t *testing.T) (*LProvider, *directoryStandIn) {
+	standIn := newDirectoryStandIn(t, activeDirectory())
+	standIn.binds[strings.ToLower("EXAMPLE\\"+testServiceAccount)] = testServicePassword
+	provider := InitializeProvider()
+	config := model.LdapConfig{
+		Server:                 "127.0.0.1",
+		Port:                   standIn.port(),
+		Domain:                 testDomain,
+		LoginDomain:            "EXAMPLE",
+		ServiceAccountUsername: testServiceAccount,
+		ServiceAccountPassword: testServicePassword,
+	}
+	if err := provider.LoadConfig(model.AuthConfig{LdapConfig: config}); err != nil {
+		t.Fatal(err)
+	}
+	return provider, standIn
+}
+
+func TestGenerateTokenWithActiveDirectory(t *testing.T) {
+	provider, standIn := newActiveDirectoryProvider(t)
+	defer standIn.listener.Close()
+
+	token, err := provider.GenerateToken("EXAMPLE\\alice:alice-pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userDN := "cn=Alice Smith,cn=Users," + testDomain
+	//the group that is not in the directory anymore is skipped
+	if ids := identityIDs(token.IdentityList); ids != "ldap_user:"+userDN+",ldap_group:cn=Admins,cn=Groups,"+testDomain {
+		t.Fatalf("Unexpected identities %v", ids)
+	}
+	if token.AccessToken != userDN || token.ExternalAccountID != userDN || token.IdentityList[0].Login != "alice" {
+		t.Fatalf("Unexpected token %v", token)
+	}
+	if !provider.AccessTokenIsIdentifier() {
+		t.Fatal("The DN access token must not be accepted as a bearer")
+	}
+
+	//the user binds with the DN found by the service account
+	if bound := strings.Join(standIn.boundNames(), ";"); bound != "EXAMPLE\\svc;"+userDN+";EXAMPLE\\svc" {
+		t.Fatalf("Unexpected binds %v", bound)
+	}
+
+	refreshed, err := provider.RefreshToken(token.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identityIDs(refreshed.IdentityList) != identityIDs(token.IdentityList) {
+		t.Fatalf("Unexpected refreshed identities %v", identityIDs(refreshed.IdentityList))
+	}
+}
+
+func TestGenerateTokenWithAUserPrincipalName(t *testing.T) {
+	provider, standIn := newActiveDirectoryProvider(t)
+	defer standIn.listener.Close()
+
+	token, err := provider.GenerateToken("alice@example.com:alice-pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.ExternalAccountID != "cn=Alice Smith,cn=Users,"+testDomain {
+		t.Fatalf("Unexpected account %v", token.ExternalAccountID)
+	}
+	if _, err := provider.GenerateToken("alice@other.example.com:alice-pw"); err == nil {
+		t.Fatal("Expected the user principal name of another domain to be rejected")
+	}
+}
+
+func TestGenerateTokenRejectsInvalidCredentials(t *testing.T) {
+	provider, standIn := newActiveDirectoryProvider(t)
+	defer standIn.listener.Close()
+
+	for _, code := range []string{
+		"alice:wrong",
+		"alice:",
+		"bob:bob-pw",
+		"nobody:pw",
+		"*:alice-pw",
+		"alice",
+	} {
+		if _, err := provider.GenerateToken(code); err == nil {
+			t.Errorf("Expected the code %q to be rejected", code)
+		}
+	}
+	for _, name := range standIn.boundNames() {
+		if name != "EXAMPLE\\svc" && name != "cn=Bob,cn=Users,"+testDomain {
+			t.Fatalf("Unexpected bind %v", name)
+		}
+	}
+}
+
+func TestGetIdentityAndSearchIdentities(t *testing.T) {
+	provider, standIn := newActiveDirectoryProvider(t)
+	defer standIn.listener.Close()
+
+	group, err := provider.GetIdentity("cn=Admins,cn=Groups,"+testDomain, GroupType, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group.Login != "admins" || group.Name != "Admins" {
+		t.Fatalf("Unexpected group %v", group)
+	}
+	if _, err := provider.GetIdentity("cn=Missing,cn=Groups,"+testDomain, GroupType, ""); err == nil {
+		t.Fatal("Expected the missing group to be rejected")
+	}
+	if _, err := provider.GetIdentities("cn=Admins,cn=Groups," + testDomain); err == nil {
+		t.Fatal("Expected the group DN not to be accepted as a user")
+	}
+
+	identities, err := provider.SearchIdentities("Al", false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := identityIDs(identities); ids != "ldap_user:cn=Alice Smith,cn=Users,"+testDomain {
+		t.Fatalf("Unexpected identities %v", ids)
+	}
+	identities, err = provider.SearchIdentities("Ad", false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := identityIDs(identities); ids != "ldap_group:cn=Admins,cn=Groups,"+testDomain {
+		t.Fatalf("Unexpected identities %v", ids)
+	}
+	identities, err = provider.SearchIdentities("Al", true, "")
+	if err != nil || len(identities) != 0 {
+		t.Fatalf("Expected no exact match, got %v %v", identityIDs(identities), err)
+	}
+}
+
+func TestGenerateTokenWithOpenLdapGroupMembership(t *testing.T) {
+	standIn := newDirectoryStandIn(t, openLdapDirectory())
+	defer standIn.listener.Close()
+	provider := InitializeOpenLdapProvider()
+	config := model.OpenLdapConfig{
+		Server:                 "127.0.0.1",
+		Port:                   standIn.port(),
+		Domain:                 testDomain,
+		ServiceAccountUsername: "uid=svc,ou=people," + testDomain,
+		ServiceAccountPassword: testServicePassword,
+	}
+	if err := provider.LoadConfig(model.AuthConfig{OpenLdapConfig: config}); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := provider.GenerateToken("alice:alice-pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := identityIDs(token.IdentityList); ids != "openldap_user:uid=alice,ou=people,"+testDomain+",openldap_group:cn=devs,ou=groups,"+testDomain {
+		t.Fatalf("Unexpected identities %v", ids)
+	}
+	if token.Type != OpenLdapTokenType || token.IdentityList[0].Name != "Alice Smith" {
+		t.Fatalf("Unexpected token %v", token)
+	}
+}
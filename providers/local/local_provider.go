@@ -0,0 +1,250 @@
+package local
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/go-rancher/client"
+	"github.com/rancher/rancher-auth-service/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+//Constants for local
+const (
+	Name      = "local"
+	Config    = Name + "config"
+	TokenType = Name + "jwt"
+	UserType  = Name + "_user"
+	GroupType = Name + "_group"
+)
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+//InitializeProvider returns a new instance of the provider
+func InitializeProvider() *LocalProvider {
+	return &LocalProvider{}
+}
+
+//LocalProvider implements an IdentityProvider backed by the local user database
+type LocalProvider struct {
+}
+
+//GetName returns the name of the provider
+func (l *LocalProvider) GetName() string {
+	return Name
+}
+
+//GenerateToken authenticates the credentials passed as username:password and returns the token
+func (l *LocalProvider) GenerateToken(securityCode string) (model.Token, error) {
+	log.Debug("LocalIdentityProvider GenerateToken called")
+	parts := strings.SplitN(securityCode, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return model.Token{}, fmt.Errorf("Invalid credentials, expected username:password")
+	}
+
+	user, groups, err := authenticate(parts[0], parts[1])
+	if err != nil {
+		log.Errorf("Error authenticating the local user %v: %v", parts[0], err)
+		return model.Token{}, err
+	}
+	return createToken(user, groups), nil
+}
+
+//authenticate checks the password against the bcrypt hash, a missing user costs the same time as a wrong password
+func authenticate(username string, password string) (User, []Group, error) {
+	mutex.Lock()
+	db, err := load()
+	mutex.Unlock()
+	if err != nil {
+		return User{}, nil, err
+	}
+
+	i := db.findUser(username)
+	if i < 0 {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+		})
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return User{}, nil, fmt.Errorf("Invalid username or password")
+	}
+	user := db.Users[i]
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return User{}, nil, fmt.Errorf("Invalid username or password")
+	}
+	return user, db.groupsOf(username), nil
+}
+
+func createToken(user User, groups []Group) model.Token {
+	var token model.Token
+	token.AccessToken = user.Username
+	token.IdentityList = toIdentities(user, groups)
+	token.Type = TokenType
+	token.ExternalAccountID = user.Username
+	return token
+}
+
+func userIdentity(user User) client.Identity {
+	identity := client.Identity{Resource: client.Resource{
+		Type: "identity",
+	}}
+	identity.ExternalId = user.Username
+	identity.Resource.Id = UserType + ":" + user.Username
+	identity.ExternalIdType = UserType
+	identity.Login = user.Username
+	if user.Name != "" {
+		identity.Name = user.Name
+	} else {
+		identity.Name = user.Username
+	}
+	return identity
+}
+
+func groupIdentity(group Group) client.Identity {
+	identity := client.Identity{Resource: client.Resource{
+		Type: "identity",
+	}}
+	identity.ExternalId = group.Name
+	identity.Resource.Id = GroupType + ":" + group.Name
+	identity.ExternalIdType = GroupType
+	identity.Login = group.Name
+	identity.Name = group.Name
+	return identity
+}
+
+func toIdentities(user User, groups []Group) []client.Identity {
+	identities := []client.Identity{userIdentity(user)}
+	for _, group := range groups {
+		identities = append(identities, groupIdentity(group))
+	}
+	return identities
+}
+
+//AccessTokenIsIdentifier tells that the access token is the username, it must not be accepted as a bearer
+func (l *LocalProvider) AccessTokenIsIdentifier() bool {
+	return true
+}
+
+//RefreshToken reads the user and its groups again from the local database and generate a new token
+func (l *LocalProvider) RefreshToken(accessToken string) (model.Token, error) {
+	mutex.Lock()
+	db, err := load()
+	mutex.Unlock()
+	if err != nil {
+		return model.Token{}, err
+	}
+
+	i := db.findUser(accessToken)
+	if i < 0 {
+		return model.Token{}, fmt.Errorf("Local user %v not found", accessToken)
+	}
+	return createToken(db.Users[i], db.groupsOf(accessToken)), nil
+}
+
+//GetIdentities returns list of user and group identities associated to this token
+func (l *LocalProvider) GetIdentities(accessToken string) ([]client.Identity, error) {
+	token, err := l.RefreshToken(accessToken)
+	if err != nil {
+		return []client.Identity{}, err
+	}
+	return token.IdentityList, nil
+}
+
+//GetIdentity returns the identity by externalID and externalIDType
+func (l *LocalProvider) GetIdentity(externalID string, externalIDType string, accessToken string) (client.Identity, error) {
+	mutex.Lock()
+	db, err := load()
+	mutex.Unlock()
+	if err != nil {
+		return client.Identity{}, err
+	}
+
+	switch externalIDType {
+	case UserType:
+		i := db.findUser(externalID)
+		if i < 0 {
+			return client.Identity{}, ErrNotFound
+		}
+		return userIdentity(db.Users[i]), nil
+	case GroupType:
+		i := db.findGroup(externalID)
+		if i < 0 {
+			return client.Identity{}, ErrNotFound
+		}
+		return groupIdentity(db.Groups[i]), nil
+	default:
+		log.Debugf("Cannot get the local account due to invalid externalIDType %v", externalIDType)
+		return client.Identity{}, fmt.Errorf("Cannot get the local account due to invalid externalIDType %v", externalIDType)
+	}
+}
+
+//SearchIdentities returns the users and groups matching the name
+func (l *LocalProvider) SearchIdentities(name string, exactMatch bool, accessToken string) ([]client.Identity, error) {
+	var identities []client.Identity
+
+	mutex.Lock()
+	db, err := load()
+	mutex.Unlock()
+	if err != nil {
+		return identities, err
+	}
+
+	for _, user := range db.Users {
+		if matches(name, exactMatch, user.Username, user.Name) {
+			identities = append(identities, userIdentity(user))
+		}
+	}
+	for _, group := range db.Groups {
+		if matches(name, exactMatch, group.Name) {
+			identities = append(identities, groupIdentity(group))
+		}
+	}
+	return identities, nil
+}
+
+func matches(name string, exactMatch bool, values ...string) bool {
+	for _, value := range values {
+		if exactMatch && strings.EqualFold(value, name) {
+			return true
+		}
+		if !exactMatch && strings.HasPrefix(strings.ToLower(value), strings.ToLower(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+//LoadConfig initializes the provider, the local users and groups are read from the store on each call
+func (l *LocalProvider) LoadConfig(authConfig model.AuthConfig) error {
+	return nil
+}
+
+//GetConfig returns the provider config
+func (l *LocalProvider) GetConfig() model.AuthConfig {
+	log.Debug("In local getConfig")
+
+	authConfig := model.AuthConfig{Resource: client.Resource{
+		Type: "config",
+	}}
+	authConfig.Provider = Config
+
+	return authConfig
+}
+
+//GetSettings transforms the provider config to db settings, the local provider has no config of its own
+func (l *LocalProvider) GetSettings() map[string]string {
+	return make(map[string]string)
+}
+
+//GetProviderSettingList returns the provider specific db setting list
+func (l *LocalProvider) GetProviderSettingList() []string {
+	return []string{}
+}
+
+//AddProviderConfig adds the provider config into the generic config using the settings from db
+func (l *LocalProvider) AddProviderConfig(authConfig *model.AuthConfig, providerSettings map[string]string) {
+}
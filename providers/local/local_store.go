@@ -0,0 +1,224 @@
+package local
+
+import (
+	"sort"
+	"sync"
+)
+
+//User is the stored record of a local user
+type User struct {
+	Username     string `json:"username"`
+	Name         string `json:"name,omitempty"`
+	PasswordHash string `json:"passwordHash"`
+}
+
+//Group is the stored record of a local group
+type Group struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members,omitempty"`
+}
+
+//Membership is the stored record of a user being a member of a group
+type Membership struct {
+	Group    string `json:"group"`
+	Username string `json:"username"`
+}
+
+//Records are the users, groups and memberships as stored, the groups carry no members
+type Records struct {
+	Users       []User
+	Groups      []Group
+	Memberships []Membership
+}
+
+//Database holds all the local users and groups along with their members
+type Database struct {
+	Users  []User  `json:"users"`
+	Groups []Group `json:"groups"`
+	//memberships are all the stored memberships, including those of the users and groups deleted
+	memberships []Membership
+}
+
+//Store persists the local users and groups, the server plugs in a store backed by Cattle settings.
+//Each user, group and membership is a record of its own, so that the instances sharing the store change
+//different users and groups at the same time without overwriting each other
+type Store interface {
+	Load() (Records, error)
+	SaveUser(user User) error
+	DeleteUser(username string) error
+	SaveGroup(name string) error
+	DeleteGroup(name string) error
+	AddMember(group string, username string) error
+	RemoveMember(group string, username string) error
+}
+
+//memoryStore keeps the records in memory, it is used until another store is set
+type memoryStore struct {
+	users       map[string]User
+	groups      map[string]bool
+	memberships map[Membership]bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		users:       make(map[string]User),
+		groups:      make(map[string]bool),
+		memberships: make(map[Membership]bool),
+	}
+}
+
+func (m *memoryStore) Load() (Records, error) {
+	var records Records
+	for _, user := range m.users {
+		records.Users = append(records.Users, user)
+	}
+	for name := range m.groups {
+		records.Groups = append(records.Groups, Group{Name: name})
+	}
+	for membership := range m.memberships {
+		records.Memberships = append(records.Memberships, membership)
+	}
+	return records, nil
+}
+
+func (m *memoryStore) SaveUser(user User) error {
+	m.users[user.Username] = user
+	return nil
+}
+
+func (m *memoryStore) DeleteUser(username string) error {
+	delete(m.users, username)
+	return nil
+}
+
+func (m *memoryStore) SaveGroup(name string) error {
+	m.groups[name] = true
+	return nil
+}
+
+func (m *memoryStore) DeleteGroup(name string) error {
+	delete(m.groups, name)
+	return nil
+}
+
+func (m *memoryStore) AddMember(group string, username string) error {
+	m.memberships[Membership{Group: group, Username: username}] = true
+	return nil
+}
+
+func (m *memoryStore) RemoveMember(group string, username string) error {
+	delete(m.memberships, Membership{Group: group, Username: username})
+	return nil
+}
+
+var (
+	mutex sync.Mutex
+	store Store = newMemoryStore()
+)
+
+//SetStore sets the store the local users and groups are persisted in
+func SetStore(newStore Store) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	store = newStore
+}
+
+//update reads the database and applies the change to the store, the caller must not hold the mutex
+func update(change func(db *Database) error) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	db, err := load()
+	if err != nil {
+		return err
+	}
+	return change(&db)
+}
+
+//load reads the database, the caller holds the mutex
+func load() (Database, error) {
+	records, err := store.Load()
+	if err != nil {
+		return Database{}, err
+	}
+	return records.database(), nil
+}
+
+//database sorts the records and sets the members of the groups. The memberships left by a user or group
+//deleted while another instance added the membership are skipped
+func (r Records) database() Database {
+	users := make(map[string]User)
+	var usernames []string
+	for _, user := range r.Users {
+		users[user.Username] = user
+		usernames = append(usernames, user.Username)
+	}
+	sort.Strings(usernames)
+
+	members := make(map[string][]string)
+	for _, membership := range r.Memberships {
+		if _, ok := users[membership.Username]; ok {
+			members[membership.Group] = append(members[membership.Group], membership.Username)
+		}
+	}
+	var names []string
+	for _, group := range r.Groups {
+		names = append(names, group.Name)
+	}
+	sort.Strings(names)
+
+	db := Database{memberships: r.Memberships}
+	for _, username := range usernames {
+		db.Users = append(db.Users, users[username])
+	}
+	for _, name := range names {
+		sort.Strings(members[name])
+		db.Groups = append(db.Groups, Group{Name: name, Members: members[name]})
+	}
+	return db
+}
+
+func (db *Database) findUser(username string) int {
+	for i, user := range db.Users {
+		if user.Username == username {
+			return i
+		}
+	}
+	return -1
+}
+
+func (db *Database) findGroup(name string) int {
+	for i, group := range db.Groups {
+		if group.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+//removeMemberships removes the stored memberships matching, those left by a deleted user or group of the
+//same name are dropped before it is created again so that they are not inherited
+func (db *Database) removeMemberships(match func(membership Membership) bool) error {
+	for _, membership := range db.memberships {
+		if match(membership) {
+			if err := store.RemoveMember(membership.Group, membership.Username); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//groupsOf returns the groups the user is a member of
+func (db *Database) groupsOf(username string) []Group {
+	var groups []Group
+	for _, group := range db.Groups {
+		for _, member := range group.Members {
+			if member == username {
+				groups = append(groups, group)
+				break
+			}
+		}
+	}
+	return groups
+}
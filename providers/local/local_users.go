@@ -0,0 +1,316 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rancher/go-rancher/client"
+	"github.com/rancher/rancher-auth-service/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+//Errors returned by the local user and group operations
+var (
+	ErrNotFound = errors.New("The local user or group does not exist")
+	ErrExists   = errors.New("The local user or group already exists")
+)
+
+//InvalidError is returned when the user or group in the request is not valid
+type InvalidError struct {
+	Message string
+}
+
+func (e *InvalidError) Error() string {
+	return e.Message
+}
+
+func validateName(kind string, name string) error {
+	if name == "" {
+		return &InvalidError{fmt.Sprintf("The %v name is required", kind)}
+	}
+	if strings.ContainsAny(name, ":/,") {
+		return &InvalidError{fmt.Sprintf("The %v name %v must not contain ':', '/' or ','", kind, name)}
+	}
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", &InvalidError{"The password is required"}
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func toLocalUser(user User) model.LocalUser {
+	return model.LocalUser{
+		Resource: client.Resource{
+			Id:   user.Username,
+			Type: "localuser",
+		},
+		Username: user.Username,
+		Name:     user.Name,
+	}
+}
+
+func toLocalGroup(group Group) model.LocalGroup {
+	return model.LocalGroup{
+		Resource: client.Resource{
+			Id:   group.Name,
+			Type: "localgroup",
+		},
+		Name:    group.Name,
+		Members: group.Members,
+	}
+}
+
+//ListUsers returns all the local users
+func ListUsers() ([]model.LocalUser, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	db, err := load()
+	if err != nil {
+		return nil, err
+	}
+	users := []model.LocalUser{}
+	for _, user := range db.Users {
+		users = append(users, toLocalUser(user))
+	}
+	return users, nil
+}
+
+//GetUser returns the local user by username
+func GetUser(username string) (model.LocalUser, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	db, err := load()
+	if err != nil {
+		return model.LocalUser{}, err
+	}
+	i := db.findUser(username)
+	if i < 0 {
+		return model.LocalUser{}, ErrNotFound
+	}
+	return toLocalUser(db.Users[i]), nil
+}
+
+//CreateUser adds a local user with the bcrypt hash of the password
+func CreateUser(localUser model.LocalUser) (model.LocalUser, error) {
+	if err := validateName("user", localUser.Username); err != nil {
+		return model.LocalUser{}, err
+	}
+	hash, err := hashPassword(localUser.Password)
+	if err != nil {
+		return model.LocalUser{}, err
+	}
+
+	user := User{Username: localUser.Username, Name: localUser.Name, PasswordHash: hash}
+	err = update(func(db *Database) error {
+		if db.findUser(user.Username) >= 0 {
+			return ErrExists
+		}
+		err := db.removeMemberships(func(membership Membership) bool {
+			return membership.Username == user.Username
+		})
+		if err != nil {
+			return err
+		}
+		return store.SaveUser(user)
+	})
+	if err != nil {
+		return model.LocalUser{}, err
+	}
+	return toLocalUser(user), nil
+}
+
+//UpdateUser changes the name and, when passed, the password of the local user
+func UpdateUser(username string, localUser model.LocalUser) (model.LocalUser, error) {
+	var hash string
+	if localUser.Password != "" {
+		var err error
+		if hash, err = hashPassword(localUser.Password); err != nil {
+			return model.LocalUser{}, err
+		}
+	}
+
+	var user User
+	err := update(func(db *Database) error {
+		i := db.findUser(username)
+		if i < 0 {
+			return ErrNotFound
+		}
+		user = db.Users[i]
+		user.Name = localUser.Name
+		if hash != "" {
+			user.PasswordHash = hash
+		}
+		return store.SaveUser(user)
+	})
+	if err != nil {
+		return model.LocalUser{}, err
+	}
+	return toLocalUser(user), nil
+}
+
+//DeleteUser removes the local user and its group memberships
+func DeleteUser(username string) error {
+	return update(func(db *Database) error {
+		if db.findUser(username) < 0 {
+			return ErrNotFound
+		}
+		if err := store.DeleteUser(username); err != nil {
+			return err
+		}
+		return db.removeMemberships(func(membership Membership) bool {
+			return membership.Username == username
+		})
+	})
+}
+
+//ListGroups returns all the local groups
+func ListGroups() ([]model.LocalGroup, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	db, err := load()
+	if err != nil {
+		return nil, err
+	}
+	groups := []model.LocalGroup{}
+	for _, group := range db.Groups {
+		groups = append(groups, toLocalGroup(group))
+	}
+	return groups, nil
+}
+
+//GetGroup returns the local group by name
+func GetGroup(name string) (model.LocalGroup, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	db, err := load()
+	if err != nil {
+		return model.LocalGroup{}, err
+	}
+	i := db.findGroup(name)
+	if i < 0 {
+		return model.LocalGroup{}, ErrNotFound
+	}
+	return toLocalGroup(db.Groups[i]), nil
+}
+
+//CreateGroup adds a local group, the members must be existing local users
+func CreateGroup(localGroup model.LocalGroup) (model.LocalGroup, error) {
+	if err := validateName("group", localGroup.Name); err != nil {
+		return model.LocalGroup{}, err
+	}
+
+	var group Group
+	err := update(func(db *Database) error {
+		if db.findGroup(localGroup.Name) >= 0 {
+			return ErrExists
+		}
+		members, err := db.checkMembers(localGroup.Members)
+		if err != nil {
+			return err
+		}
+		err = db.removeMemberships(func(membership Membership) bool {
+			return membership.Group == localGroup.Name
+		})
+		if err != nil {
+			return err
+		}
+		//the members are added first, the group shows up with all of them
+		for _, member := range members {
+			if err := store.AddMember(localGroup.Name, member); err != nil {
+				return err
+			}
+		}
+		group = Group{Name: localGroup.Name, Members: members}
+		return store.SaveGroup(group.Name)
+	})
+	if err != nil {
+		return model.LocalGroup{}, err
+	}
+	return toLocalGroup(group), nil
+}
+
+//UpdateGroup replaces the members of the local group
+func UpdateGroup(name string, localGroup model.LocalGroup) (model.LocalGroup, error) {
+	var group Group
+	err := update(func(db *Database) error {
+		i := db.findGroup(name)
+		if i < 0 {
+			return ErrNotFound
+		}
+		members, err := db.checkMembers(localGroup.Members)
+		if err != nil {
+			return err
+		}
+		//only the memberships added or removed are written
+		current := make(map[string]bool)
+		for _, member := range db.Groups[i].Members {
+			current[member] = true
+		}
+		for _, member := range members {
+			if current[member] {
+				delete(current, member)
+				continue
+			}
+			if err := store.AddMember(name, member); err != nil {
+				return err
+			}
+		}
+		for member := range current {
+			if err := store.RemoveMember(name, member); err != nil {
+				return err
+			}
+		}
+		group = Group{Name: name, Members: members}
+		return nil
+	})
+	if err != nil {
+		return model.LocalGroup{}, err
+	}
+	return toLocalGroup(group), nil
+}
+
+//DeleteGroup removes the local group
+func DeleteGroup(name string) error {
+	return update(func(db *Database) error {
+		if db.findGroup(name) < 0 {
+			return ErrNotFound
+		}
+		if err := store.DeleteGroup(name); err != nil {
+			return err
+		}
+		return db.removeMemberships(func(membership Membership) bool {
+			return membership.Group == name
+		})
+	})
+}
+
+//checkMembers returns the sorted and deduplicated members, failing on unknown users
+func (db *Database) checkMembers(members []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var result []string
+	for _, member := range members {
+		if seen[member] {
+			continue
+		}
+		if db.findUser(member) < 0 {
+			return nil, &InvalidError{fmt.Sprintf("The member %v is not a local user", member)}
+		}
+		seen[member] = true
+		result = append(result, member)
+	}
+	sort.Strings(result)
+	return result, nil
+}
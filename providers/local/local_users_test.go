@@ -0,0 +1,93 @@
+package local
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+func TestGroupMembers(t *testing.T) {
+	SetStore(newMemoryStore())
+	defer SetStore(newMemoryStore())
+
+	for _, username := range []string{"carol", "alice", "bob"} {
+		if _, err := CreateUser(model.LocalUser{Username: username, Password: username + "-pw"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := CreateUser(model.LocalUser{Username: "alice", Password: "pw"}); err != ErrExists {
+		t.Fatalf("Expected the existing user to be refused, got %v", err)
+	}
+	group, err := CreateGroup(model.LocalGroup{Name: "devs", Members: []string{"carol", "alice", "carol"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(group.Members, []string{"alice", "carol"}) {
+		t.Fatalf("Unexpected members %v", group.Members)
+	}
+	if _, err := CreateGroup(model.LocalGroup{Name: "ops", Members: []string{"dave"}}); err == nil {
+		t.Fatal("Expected the unknown member to be refused")
+	}
+
+	if _, err := UpdateGroup("devs", model.LocalGroup{Members: []string{"bob", "carol"}}); err != nil {
+		t.Fatal(err)
+	}
+	group, err = GetGroup("devs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(group.Members, []string{"bob", "carol"}) {
+		t.Fatalf("Unexpected updated members %v", group.Members)
+	}
+
+	//a user created again under the name of a deleted user is not a member of its groups
+	if err := DeleteUser("bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreateUser(model.LocalUser{Username: "bob", Password: "pw"}); err != nil {
+		t.Fatal(err)
+	}
+	group, err = GetGroup("devs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(group.Members, []string{"carol"}) {
+		t.Fatalf("Unexpected members after deleting bob %v", group.Members)
+	}
+
+	if err := DeleteGroup("devs"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetGroup("devs"); err != ErrNotFound {
+		t.Fatalf("Expected the deleted group to be gone, got %v", err)
+	}
+	users, err := ListUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 3 || users[0].Username != "alice" {
+		t.Fatalf("Unexpected users %v", users)
+	}
+}
+
+func TestRecordsSkipTheMembershipsOfDeletedUsers(t *testing.T) {
+	records := Records{
+		Users:  []User{{Username: "bob"}, {Username: "alice"}},
+		Groups: []Group{{Name: "ops"}, {Name: "devs"}},
+		Memberships: []Membership{
+			{Group: "devs", Username: "bob"},
+			{Group: "devs", Username: "alice"},
+			{Group: "devs", Username: "deleted"},
+			{Group: "deleted", Username: "alice"},
+		},
+	}
+	db := records.database()
+	if db.Users[0].Username != "alice" || db.Users[1].Username != "bob" {
+		t.Fatalf("Unexpected users %v", db.Users)
+	}
+	expected := []Group{{Name: "devs", Members: []string{"alice", "bob"}}, {Name: "ops"}}
+	if !reflect.DeepEqual(db.Groups, expected) {
+		t.Fatalf("Unexpected groups %v", db.Groups)
+	}
+}
@@ -0,0 +1,67 @@
+package oidc
+
+import (
+	"fmt"
+
+	"github.com/rancher/go-rancher/client"
+)
+
+//Account defines properties a user or group mapped from the OpenID Connect claims has
+type Account struct {
+	ID    string
+	Login string
+	Name  string
+}
+
+func (a *Account) toIdentity(externalIDType string, identity *client.Identity) {
+	identity.ExternalId = a.ID
+	identity.Resource.Id = externalIDType + ":" + a.ID
+	identity.ExternalIdType = externalIDType
+	if a.Name != "" {
+		identity.Name = a.Name
+	} else {
+		identity.Name = a.Login
+	}
+	identity.Login = a.Login
+}
+
+//claimsToAccounts maps the configured claims into the user and group accounts
+func (o *OClient) claimsToAccounts(claims map[string]interface{}) (Account, []Account, error) {
+	userID := claimString(claims, o.config.UserIDClaim)
+	if userID == "" {
+		return Account{}, nil, fmt.Errorf("Claim %v not found for the user", o.config.UserIDClaim)
+	}
+
+	user := Account{
+		ID:    userID,
+		Login: claimString(claims, o.config.UsernameClaim),
+		Name:  claimString(claims, o.config.NameClaim),
+	}
+
+	var groups []Account
+	switch values := claims[o.config.GroupsClaim].(type) {
+	case []interface{}:
+		for _, value := range values {
+			if name, ok := value.(string); ok && name != "" {
+				groups = append(groups, Account{ID: name, Login: name, Name: name})
+			}
+		}
+	case string:
+		if values != "" {
+			groups = append(groups, Account{ID: values, Login: values, Name: values})
+		}
+	}
+
+	return user, groups, nil
+}
+
+func claimString(claims map[string]interface{}, claim string) string {
+	switch value := claims[claim].(type) {
+	case string:
+		return value
+	case float64:
+		return fmt.Sprintf("%v", int64(value))
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,408 @@
+package oidc
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+)
+
+//discoveryDocument holds the endpoints published by the issuer
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+//jsonWebKey is a single RSA key from the issuer JWKS
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+//tokenResponse is the response of the token endpoint
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	TokenType        string `json:"token_type"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+//accessSession is the access token kept in the jwt token, the access token of the issuer along with the id_token
+//asserting the groups at login, as many issuers leave the groups out of the userinfo response
+type accessSession struct {
+	AccessToken string `json:"accessToken"`
+	IDToken     string `json:"idToken,omitempty"`
+}
+
+func encodeSession(accessToken string, idToken string) string {
+	session, err := json.Marshal(accessSession{AccessToken: accessToken, IDToken: idToken})
+	if err != nil {
+		return accessToken
+	}
+	return string(session)
+}
+
+//isSession tells if the value is a session rather than an access token of the issuer
+func isSession(value string) bool {
+	return strings.HasPrefix(value, "{")
+}
+
+//decodeSession reads the session of the jwt token, any other value is taken as an access token of the issuer
+func decodeSession(value string) accessSession {
+	var session accessSession
+	if isSession(value) && json.Unmarshal([]byte(value), &session) == nil && session.AccessToken != "" {
+		return session
+	}
+	return accessSession{AccessToken: value}
+}
+
+//sessionTTL is the age past which the id_token of a session is refused, the groups it asserted are then stale
+var sessionTTL = 16 * time.Hour
+
+//SetSessionTTL sets the age past which the id_token of a session is refused, the lifetime of the jwt tokens
+func SetSessionTTL(ttl time.Duration) {
+	sessionTTL = ttl
+}
+
+//OClient implements a httpclient for an OpenID Connect issuer
+type OClient struct {
+	httpClient *http.Client
+	config     *model.OIDCConfig
+
+	mutex     sync.Mutex
+	discovery *discoveryDocument
+	keys      map[string]*rsa.PublicKey
+}
+
+func (o *OClient) getDiscovery() (*discoveryDocument, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.discovery != nil {
+		return o.discovery, nil
+	}
+
+	discoveryURL := strings.TrimSuffix(o.config.Issuer, "/") + discoveryPath
+	var discovery discoveryDocument
+	if err := o.getJSON(discoveryURL, "", &discovery); err != nil {
+		log.Errorf("OIDC getDiscovery: error reading %v, err: %v", discoveryURL, err)
+		return nil, err
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != strings.TrimSuffix(o.config.Issuer, "/") {
+		return nil, fmt.Errorf("Issuer %v in the discovery document does not match the configured issuer %v", discovery.Issuer, o.config.Issuer)
+	}
+	if discovery.TokenEndpoint == "" || discovery.JwksURI == "" {
+		return nil, fmt.Errorf("Discovery document of %v is missing the token endpoint or jwks_uri", o.config.Issuer)
+	}
+
+	o.discovery = &discovery
+	return o.discovery, nil
+}
+
+//getKey returns the issuer key for kid, the JWKS is fetched again when the kid is unknown to pick up rotated keys
+func (o *OClient) getKey(kid string) (*rsa.PublicKey, error) {
+	discovery, err := o.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if key, ok := o.lookupKey(kid); ok {
+		return key, nil
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := o.getJSON(discovery.JwksURI, "", &jwks); err != nil {
+		log.Errorf("OIDC getKey: error reading the JWKS %v, err: %v", discovery.JwksURI, err)
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := jwk.toPublicKey()
+		if err != nil {
+			log.Debugf("OIDC getKey: skipping key %v, err: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	o.keys = keys
+
+	if key, ok := o.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("No signing key found for kid %v", kid)
+}
+
+func (o *OClient) lookupKey(kid string) (*rsa.PublicKey, bool) {
+	if kid == "" && len(o.keys) == 1 {
+		for _, key := range o.keys {
+			return key, true
+		}
+	}
+	key, ok := o.keys[kid]
+	return key, ok
+}
+
+func (k *jsonWebKey) toPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.N, "="))
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.E, "="))
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+//authorizationURL returns the authorization endpoint url the browser is sent to, requesting a code for the
+//configured scopes, openid is always requested. The state is passed back to the redirect url with the code
+func (o *OClient) authorizationURL(state string) (string, error) {
+	discovery, err := o.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+	if discovery.AuthorizationEndpoint == "" {
+		return "", fmt.Errorf("Issuer %v does not publish an authorization endpoint", o.config.Issuer)
+	}
+	authURL, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := strings.Fields(o.config.Scopes)
+	hasOpenID := false
+	for _, scope := range scopes {
+		if scope == "openid" {
+			hasOpenID = true
+		}
+	}
+	if !hasOpenID {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", o.config.ClientID)
+	query.Set("scope", strings.Join(scopes, " "))
+	if o.config.RedirectURL != "" {
+		query.Set("redirect_uri", o.config.RedirectURL)
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	authURL.RawQuery = query.Encode()
+	return authURL.String(), nil
+}
+
+//exchangeCode performs the authorization code exchange with the token endpoint
+func (o *OClient) exchangeCode(code string) (tokenResponse, error) {
+	discovery, err := o.getDiscovery()
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	form := url.Values{}
+	form.Add("grant_type", "authorization_code")
+	form.Add("code", code)
+	if o.config.RedirectURL != "" {
+		form.Add("redirect_uri", o.config.RedirectURL)
+	}
+
+	req, err := http.NewRequest("POST", discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokenResponse{}, err
+	}
+	req.SetBasicAuth(url.QueryEscape(o.config.ClientID), url.QueryEscape(o.config.ClientSecret))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Accept", "application/json")
+
+	var token tokenResponse
+	if err := o.doJSON(req, &token); err != nil {
+		log.Errorf("OIDC exchangeCode: received error from the token endpoint, err: %v", err)
+		return tokenResponse{}, err
+	}
+	if token.Error != "" {
+		return tokenResponse{}, fmt.Errorf("Received Error from the issuer %v, description %v", token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return tokenResponse{}, fmt.Errorf("No id_token received from the issuer")
+	}
+	return token, nil
+}
+
+//verifyIDToken checks the ID token signature against the issuer JWKS, its issuer and audience, and returns its claims.
+//The expiry is not checked with allowExpired, for the id_token kept in the session to read the groups asserted at login
+func (o *OClient) verifyIDToken(rawIDToken string, allowExpired bool) (map[string]interface{}, error) {
+	discovery, err := o.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.Parse(rawIDToken, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("Unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return o.getKey(kid)
+	})
+	if err != nil && !(allowExpired && isExpiredOnly(err)) {
+		log.Errorf("OIDC verifyIDToken: invalid id_token, err: %v", err)
+		return nil, err
+	}
+	if err == nil && !token.Valid {
+		return nil, fmt.Errorf("Invalid id_token")
+	}
+
+	claims := token.Claims
+	if iss, _ := claims["iss"].(string); iss != discovery.Issuer {
+		return nil, fmt.Errorf("Unexpected id_token issuer %v", claims["iss"])
+	}
+	if !hasAudience(claims["aud"], o.config.ClientID) {
+		return nil, fmt.Errorf("The id_token was not issued for client %v", o.config.ClientID)
+	}
+	return claims, nil
+}
+
+//isExpiredOnly tells if the expiry is the only failed check, the signature was then verified
+func isExpiredOnly(err error) bool {
+	validationErr, ok := err.(*jwt.ValidationError)
+	return ok && validationErr.Errors == jwt.ValidationErrorExpired
+}
+
+func hasAudience(aud interface{}, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, value := range aud {
+			if value == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//getUserInfo returns the claims of the userinfo endpoint for the access token
+func (o *OClient) getUserInfo(accessToken string) (map[string]interface{}, error) {
+	discovery, err := o.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+	if discovery.UserinfoEndpoint == "" {
+		return nil, fmt.Errorf("Issuer %v does not publish a userinfo endpoint", o.config.Issuer)
+	}
+
+	var claims map[string]interface{}
+	if err := o.getJSON(discovery.UserinfoEndpoint, accessToken, &claims); err != nil {
+		log.Errorf("OIDC getUserInfo: received error from the userinfo endpoint, err: %v", err)
+		return nil, err
+	}
+	return claims, nil
+}
+
+//getSessionClaims returns the userinfo claims of the session, the groups are taken from its id_token when the
+//userinfo endpoint does not return them
+func (o *OClient) getSessionClaims(value string) (map[string]interface{}, error) {
+	session := decodeSession(value)
+	claims, err := o.getUserInfo(session.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := claims[o.config.GroupsClaim]; ok || session.IDToken == "" {
+		return claims, nil
+	}
+
+	idClaims, err := o.verifyIDToken(session.IDToken, true)
+	if err != nil {
+		return nil, err
+	}
+	if claimString(idClaims, "sub") != claimString(claims, "sub") {
+		return nil, fmt.Errorf("The id_token of the session does not belong to the userinfo subject")
+	}
+	iat, ok := idClaims["iat"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("The id_token of the session has no iat")
+	}
+	if time.Since(time.Unix(int64(iat), 0)) > sessionTTL {
+		return nil, fmt.Errorf("The id_token of the session was issued more than %v ago, please log in again", sessionTTL)
+	}
+	if groups, ok := idClaims[o.config.GroupsClaim]; ok {
+		claims[o.config.GroupsClaim] = groups
+	}
+	return claims, nil
+}
+
+func (o *OClient) getJSON(url string, accessToken string, v interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	if accessToken != "" {
+		req.Header.Add("Authorization", "Bearer "+accessToken)
+	}
+	req.Header.Add("Accept", "application/json")
+	return o.doJSON(req, v)
+}
+
+func (o *OClient) doJSON(req *http.Request, v interface{}) error {
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Check the status code
+	switch resp.StatusCode {
+	case 200:
+	case 201:
+	default:
+		var body bytes.Buffer
+		io.Copy(&body, resp.Body)
+		return fmt.Errorf("Request failed, got status code: %d. Response: %s",
+			resp.StatusCode, body.Bytes())
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
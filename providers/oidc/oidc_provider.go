@@ -0,0 +1,295 @@
+package oidc
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/go-rancher/client"
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+//Constants for oidc
+const (
+	Name                 = "oidc"
+	Config               = Name + "config"
+	TokenType            = Name + "jwt"
+	UserType             = Name + "_user"
+	GroupType            = Name + "_group"
+	issuerSetting        = "api.auth.oidc.issuer"
+	clientIDSetting      = "api.auth.oidc.client.id"
+	clientSecretSetting  = "api.auth.oidc.client.secret"
+	redirectURLSetting   = "api.auth.oidc.redirect.url"
+	scopesSetting        = "api.auth.oidc.scopes"
+	userIDClaimSetting   = "api.auth.oidc.user.id.claim"
+	usernameClaimSetting = "api.auth.oidc.username.claim"
+	nameClaimSetting     = "api.auth.oidc.name.claim"
+	groupsClaimSetting   = "api.auth.oidc.groups.claim"
+)
+
+//InitializeProvider returns a new instance of the provider
+func InitializeProvider() *OProvider {
+	oidcClient := &OClient{}
+	oidcClient.httpClient = &http.Client{}
+
+	oidcProvider := &OProvider{}
+	oidcProvider.oidcClient = oidcClient
+
+	return oidcProvider
+}
+
+//OProvider implements an IdentityProvider for OpenID Connect issuers
+type OProvider struct {
+	oidcClient *OClient
+}
+
+//GetName returns the name of the provider
+func (o *OProvider) GetName() string {
+	return Name
+}
+
+//GetEndpoint returns the issuer url
+func (o *OProvider) GetEndpoint() string {
+	return o.oidcClient.config.Issuer
+}
+
+//GetLoginURL returns the authorization endpoint url the browser is sent to for the login
+func (o *OProvider) GetLoginURL(state string) (string, error) {
+	return o.oidcClient.authorizationURL(state)
+}
+
+//GenerateToken exchanges the authorization code, verifies the ID token and returns the token
+func (o *OProvider) GenerateToken(securityCode string) (model.Token, error) {
+	log.Debug("OIDCIdentityProvider GenerateToken called")
+	tokenResp, err := o.oidcClient.exchangeCode(securityCode)
+	if err != nil {
+		log.Errorf("Error exchanging the code with the OIDC issuer %v", err)
+		return model.Token{}, err
+	}
+
+	claims, err := o.oidcClient.verifyIDToken(tokenResp.IDToken, false)
+	if err != nil {
+		log.Errorf("Error verifying the id_token from the OIDC issuer %v", err)
+		return model.Token{}, err
+	}
+
+	user, groups, err := o.oidcClient.claimsToAccounts(claims)
+	if err != nil {
+		return model.Token{}, err
+	}
+	return o.createToken(encodeSession(tokenResp.AccessToken, tokenResp.IDToken), user, groups), nil
+}
+
+//IsSessionAccessToken tells if the access token is a session carrying the id_token of the login, the
+//service only accepts it from the encrypted claim of the tokens it issued
+func (o *OProvider) IsSessionAccessToken(accessToken string) bool {
+	return isSession(accessToken)
+}
+
+func (o *OProvider) createToken(accessToken string, user Account, groups []Account) model.Token {
+	var token model.Token
+	token.AccessToken = accessToken
+	token.IdentityList = toIdentities(user, groups)
+	token.Type = TokenType
+	token.ExternalAccountID = user.ID
+	return token
+}
+
+func toIdentities(user Account, groups []Account) []client.Identity {
+	var identities []client.Identity
+
+	userIdentity := client.Identity{Resource: client.Resource{
+		Type: "identity",
+	}}
+	user.toIdentity(UserType, &userIdentity)
+	identities = append(identities, userIdentity)
+
+	for _, group := range groups {
+		groupIdentity := client.Identity{Resource: client.Resource{
+			Type: "identity",
+		}}
+		group.toIdentity(GroupType, &groupIdentity)
+		identities = append(identities, groupIdentity)
+	}
+
+	return identities
+}
+
+//RefreshToken reads the claims from the userinfo endpoint and generate a new token, the groups asserted
+//by the id_token at login are kept when the userinfo endpoint does not return them
+func (o *OProvider) RefreshToken(accessToken string) (model.Token, error) {
+	log.Debug("OIDCIdentityProvider RefreshToken called")
+	claims, err := o.oidcClient.getSessionClaims(accessToken)
+	if err != nil {
+		return model.Token{}, err
+	}
+	user, groups, err := o.oidcClient.claimsToAccounts(claims)
+	if err != nil {
+		return model.Token{}, err
+	}
+	return o.createToken(accessToken, user, groups), nil
+}
+
+//GetIdentities returns list of user and group identities associated to this token
+func (o *OProvider) GetIdentities(accessToken string) ([]client.Identity, error) {
+	claims, err := o.oidcClient.getSessionClaims(accessToken)
+	if err != nil {
+		return []client.Identity{}, err
+	}
+	user, groups, err := o.oidcClient.claimsToAccounts(claims)
+	if err != nil {
+		return []client.Identity{}, err
+	}
+	return toIdentities(user, groups), nil
+}
+
+//GetIdentity returns the identity by externalID and externalIDType, OpenID Connect offers no directory
+//lookup so the identity is built from the id unless it is the user owning the token
+func (o *OProvider) GetIdentity(externalID string, externalIDType string, accessToken string) (client.Identity, error) {
+	identity := client.Identity{Resource: client.Resource{
+		Type: "identity",
+	}}
+
+	switch externalIDType {
+	case UserType, GroupType:
+		if accessToken != "" {
+			if identities, err := o.GetIdentities(accessToken); err == nil {
+				for _, known := range identities {
+					if known.ExternalIdType == externalIDType && known.ExternalId == externalID {
+						return known, nil
+					}
+				}
+			}
+		}
+		account := Account{ID: externalID, Login: externalID}
+		account.toIdentity(externalIDType, &identity)
+		return identity, nil
+	default:
+		log.Debugf("Cannot get the oidc account due to invalid externalIDType %v", externalIDType)
+		return identity, fmt.Errorf("Cannot get the oidc account due to invalid externalIDType %v", externalIDType)
+	}
+}
+
+//SearchIdentities returns the identity by name, OpenID Connect offers no search so the identities
+//of the token are filtered, an exact name not found there is returned as a group
+func (o *OProvider) SearchIdentities(name string, exactMatch bool, accessToken string) ([]client.Identity, error) {
+	var identities []client.Identity
+
+	if accessToken != "" {
+		if known, err := o.GetIdentities(accessToken); err == nil {
+			for _, identity := range known {
+				if identity.Login == name || identity.Name == name || (!exactMatch && strings.HasPrefix(identity.Login, name)) {
+					identities = append(identities, identity)
+				}
+			}
+		}
+	}
+
+	if exactMatch && len(identities) == 0 {
+		groupIdentity := client.Identity{Resource: client.Resource{
+			Type: "identity",
+		}}
+		group := Account{ID: name, Login: name, Name: name}
+		group.toIdentity(GroupType, &groupIdentity)
+		identities = append(identities, groupIdentity)
+	}
+
+	return identities, nil
+}
+
+//LoadConfig initializes the provider with the passes config
+func (o *OProvider) LoadConfig(authConfig model.AuthConfig) error {
+	configObj := authConfig.OIDCConfig
+	if configObj.Issuer == "" {
+		return fmt.Errorf("Missing Issuer in oidcConfig")
+	}
+	if configObj.ClientID == "" || configObj.ClientSecret == "" {
+		return fmt.Errorf("Missing ClientID or ClientSecret in oidcConfig")
+	}
+	if configObj.Scopes == "" {
+		configObj.Scopes = "openid profile email"
+	}
+	if configObj.UserIDClaim == "" {
+		configObj.UserIDClaim = "sub"
+	}
+	if configObj.UsernameClaim == "" {
+		configObj.UsernameClaim = "preferred_username"
+	}
+	if configObj.NameClaim == "" {
+		configObj.NameClaim = "name"
+	}
+	if configObj.GroupsClaim == "" {
+		configObj.GroupsClaim = "groups"
+	}
+	o.oidcClient.config = &configObj
+	return nil
+}
+
+//GetConfig returns the provider config
+func (o *OProvider) GetConfig() model.AuthConfig {
+	log.Debug("In oidc getConfig")
+
+	authConfig := model.AuthConfig{Resource: client.Resource{
+		Type: "config",
+	}}
+
+	authConfig.Provider = Config
+	authConfig.OIDCConfig = *o.oidcClient.config
+
+	authConfig.OIDCConfig.Resource = client.Resource{
+		Type: "oidcconfig",
+	}
+
+	return authConfig
+}
+
+//GetSettings transforms the provider config to db settings
+func (o *OProvider) GetSettings() map[string]string {
+	settings := make(map[string]string)
+
+	settings[issuerSetting] = o.oidcClient.config.Issuer
+	settings[clientIDSetting] = o.oidcClient.config.ClientID
+	settings[clientSecretSetting] = o.oidcClient.config.ClientSecret
+	settings[redirectURLSetting] = o.oidcClient.config.RedirectURL
+	settings[scopesSetting] = o.oidcClient.config.Scopes
+	settings[userIDClaimSetting] = o.oidcClient.config.UserIDClaim
+	settings[usernameClaimSetting] = o.oidcClient.config.UsernameClaim
+	settings[nameClaimSetting] = o.oidcClient.config.NameClaim
+	settings[groupsClaimSetting] = o.oidcClient.config.GroupsClaim
+
+	return settings
+}
+
+//GetProviderSettingList returns the provider specific db setting list
+func (o *OProvider) GetProviderSettingList() []string {
+	var settings []string
+	settings = append(settings, issuerSetting)
+	settings = append(settings, clientIDSetting)
+	settings = append(settings, clientSecretSetting)
+	settings = append(settings, redirectURLSetting)
+	settings = append(settings, scopesSetting)
+	settings = append(settings, userIDClaimSetting)
+	settings = append(settings, usernameClaimSetting)
+	settings = append(settings, nameClaimSetting)
+	settings = append(settings, groupsClaimSetting)
+	return settings
+}
+
+//AddProviderConfig adds the provider config into the generic config using the settings from db
+func (o *OProvider) AddProviderConfig(authConfig *model.AuthConfig, providerSettings map[string]string) {
+	oidcConfig := model.OIDCConfig{Resource: client.Resource{
+		Type: "oidcconfig",
+	}}
+	oidcConfig.Issuer = providerSettings[issuerSetting]
+	oidcConfig.ClientID = providerSettings[clientIDSetting]
+	oidcConfig.ClientSecret = providerSettings[clientSecretSetting]
+	oidcConfig.RedirectURL = providerSettings[redirectURLSetting]
+	oidcConfig.Scopes = providerSettings[scopesSetting]
+	oidcConfig.UserIDClaim = providerSettings[userIDClaimSetting]
+	oidcConfig.UsernameClaim = providerSettings[usernameClaimSetting]
+	oidcConfig.NameClaim = providerSettings[nameClaimSetting]
+	oidcConfig.GroupsClaim = providerSettings[groupsClaimSetting]
+
+	authConfig.OIDCConfig = oidcConfig
+}
@@ -0,0 +1,310 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+const (
+	testClientID     = "rancher"
+	testClientSecret = "s3cret"
+	testCode         = "code-1"
+	testAccessToken  = "issuer-access-token"
+)
+
+//fakeIssuer is an OpenID Connect issuer serving the discovery, JWKS, token and userinfo endpoints
+type fakeIssuer struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	idToken  string
+	userinfo map[string]interface{}
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &fakeIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discoveryDocument{
+			Issuer:                issuer.server.URL,
+			AuthorizationEndpoint: issuer.server.URL + "/authorize",
+			TokenEndpoint:         issuer.server.URL + "/token",
+			UserinfoEndpoint:      issuer.server.URL + "/userinfo",
+			JwksURI:               issuer.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []jsonWebKey{{
+			Kid: "k1",
+			Kty: "RSA",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, _ := r.BasicAuth()
+		if clientID != testClientID || clientSecret != testClientSecret || r.FormValue("code") != testCode {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": testAccessToken,
+			"id_token":     issuer.idToken,
+			"token_type":   "Bearer",
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testAccessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(issuer.userinfo)
+	})
+	issuer.server = httptest.NewServer(mux)
+
+	issuer.userinfo = map[string]interface{}{
+		"sub":                "u-1",
+		"preferred_username": "alice",
+		"name":               "Alice",
+	}
+	issuer.idToken = issuer.sign(t, key, time.Now().Add(time.Hour))
+	return issuer
+}
+
+func (f *fakeIssuer) sign(t *testing.T, key *rsa.PrivateKey, expiry time.Time) string {
+	return f.signIssuedAt(t, key, time.Now(), expiry)
+}
+
+func (f *fakeIssuer) signIssuedAt(t *testing.T, key *rsa.PrivateKey, issuedAt time.Time, expiry time.Time) string {
+	token := jwt.New(jwt.SigningMethodRS256)
+	token.Header["kid"] = "k1"
+	token.Claims["iss"] = f.server.URL
+	token.Claims["aud"] = testClientID
+	token.Claims["sub"] = "u-1"
+	token.Claims["preferred_username"] = "alice"
+	token.Claims["name"] = "Alice"
+	token.Claims["groups"] = []string{"admins", "devs"}
+	token.Claims["iat"] = issuedAt.Unix()
+	token.Claims["exp"] = expiry.Unix()
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func newTestProvider(t *testing.T, issuer *fakeIssuer) *OProvider {
+	provider := InitializeProvider()
+	err := provider.LoadConfig(model.AuthConfig{OIDCConfig: model.OIDCConfig{
+		Issuer:       issuer.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  "https://rancher.example.com/login/oidc",
+		Scopes:       "profile groups",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func identityIDs(token model.Token) []string {
+	var ids []string
+	for _, identity := range token.IdentityList {
+		ids = append(ids, identity.Id)
+	}
+	return ids
+}
+
+func TestGenerateAndRefreshTokenKeepTheIDTokenGroups(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.server.Close()
+	provider := newTestProvider(t, issuer)
+
+	token, err := provider.GenerateToken(testCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "oidc_user:u-1,oidc_group:admins,oidc_group:devs"
+	if ids := strings.Join(identityIDs(token), ","); ids != expected {
+		t.Fatalf("Unexpected identities %v, expected %v", ids, expected)
+	}
+	if token.ExternalAccountID != "u-1" {
+		t.Fatalf("Unexpected account %v", token.ExternalAccountID)
+	}
+
+	//the userinfo endpoint does not return the groups, they come from the id_token of the session
+	refreshed, err := provider.RefreshToken(token.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := strings.Join(identityIDs(refreshed), ","); ids != expected {
+		t.Fatalf("Unexpected refreshed identities %v, expected %v", ids, expected)
+	}
+
+	identities, err := provider.GetIdentities(token.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 3 {
+		t.Fatalf("Expected the user and 2 groups, got %v", identities)
+	}
+}
+
+func TestRefreshTokenPrefersTheUserinfoGroups(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.server.Close()
+	provider := newTestProvider(t, issuer)
+
+	token, err := provider.GenerateToken(testCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer.userinfo["groups"] = []string{"ops"}
+	refreshed, err := provider.RefreshToken(token.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := strings.Join(identityIDs(refreshed), ","); ids != "oidc_user:u-1,oidc_group:ops" {
+		t.Fatalf("Unexpected refreshed identities %v", ids)
+	}
+}
+
+func TestRefreshTokenAcceptsTheExpiredIDTokenOfTheSession(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.server.Close()
+	provider := newTestProvider(t, issuer)
+
+	session := encodeSession(testAccessToken, issuer.sign(t, issuer.key, time.Now().Add(-time.Hour)))
+	refreshed, err := provider.RefreshToken(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refreshed.IdentityList) != 3 {
+		t.Fatalf("Expected the user and 2 groups, got %v", identityIDs(refreshed))
+	}
+}
+
+func TestRefreshTokenRejectsAnIDTokenOlderThanTheSessionTTL(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.server.Close()
+	provider := newTestProvider(t, issuer)
+
+	issuedAt := time.Now().Add(-sessionTTL - time.Minute)
+	session := encodeSession(testAccessToken, issuer.signIssuedAt(t, issuer.key, issuedAt, issuedAt.Add(time.Hour)))
+	if _, err := provider.RefreshToken(session); err == nil {
+		t.Fatal("Expected the id_token issued before the session ttl to be rejected")
+	}
+	if _, err := provider.GetIdentities(session); err == nil {
+		t.Fatal("Expected the id_token issued before the session ttl to be rejected")
+	}
+}
+
+func TestIsSessionAccessToken(t *testing.T) {
+	provider := InitializeProvider()
+	if !provider.IsSessionAccessToken(encodeSession(testAccessToken, "id-token")) {
+		t.Fatal("Expected the encoded session to be a session")
+	}
+	if provider.IsSessionAccessToken(testAccessToken) {
+		t.Fatal("Expected the issuer access token not to be a session")
+	}
+}
+
+func TestRefreshTokenRejectsAForgedIDToken(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.server.Close()
+	provider := newTestProvider(t, issuer)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := encodeSession(testAccessToken, issuer.sign(t, otherKey, time.Now().Add(time.Hour)))
+	if _, err := provider.RefreshToken(session); err == nil {
+		t.Fatal("Expected the id_token signed by another key to be rejected")
+	}
+}
+
+func TestGetIdentitiesWithTheIssuerAccessToken(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.server.Close()
+	provider := newTestProvider(t, issuer)
+
+	identities, err := provider.GetIdentities(testAccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 1 || identities[0].Id != "oidc_user:u-1" {
+		t.Fatalf("Expected only the user identity, got %v", identities)
+	}
+}
+
+func TestGenerateTokenRejectsAnExpiredIDToken(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.server.Close()
+	provider := newTestProvider(t, issuer)
+
+	issuer.idToken = issuer.sign(t, issuer.key, time.Now().Add(-time.Hour))
+	if _, err := provider.GenerateToken(testCode); err == nil {
+		t.Fatal("Expected the expired id_token to be rejected")
+	}
+}
+
+func TestGenerateTokenRejectsAnInvalidCode(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.server.Close()
+	provider := newTestProvider(t, issuer)
+
+	if _, err := provider.GenerateToken("wrong"); err == nil {
+		t.Fatal("Expected the invalid code to be rejected")
+	}
+}
+
+func TestGetLoginURL(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	defer issuer.server.Close()
+	provider := newTestProvider(t, issuer)
+
+	loginURL, err := provider.GetLoginURL("xyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(loginURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(loginURL, issuer.server.URL+"/authorize?") {
+		t.Fatalf("Unexpected authorization endpoint %v", loginURL)
+	}
+	query := parsed.Query()
+	for name, expected := range map[string]string{
+		"response_type": "code",
+		"client_id":     testClientID,
+		"redirect_uri":  "https://rancher.example.com/login/oidc",
+		"scope":         "openid profile groups",
+		"state":         "xyz",
+	} {
+		if value := query.Get(name); value != expected {
+			t.Errorf("Unexpected %v %v, expected %v", name, value, expected)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package saml
+
+import (
+	"fmt"
+
+	gosaml "github.com/crewjam/saml"
+	"github.com/rancher/go-rancher/client"
+)
+
+//Account defines properties a user or group asserted by the IdP has
+type Account struct {
+	ID    string
+	Login string
+	Name  string
+}
+
+func (a *Account) toIdentity(externalIDType string, identity *client.Identity) {
+	identity.ExternalId = a.ID
+	identity.Resource.Id = externalIDType + ":" + a.ID
+	identity.ExternalIdType = externalIDType
+	if a.Name != "" {
+		identity.Name = a.Name
+	} else {
+		identity.Name = a.Login
+	}
+	identity.Login = a.Login
+}
+
+//assertionToAccounts maps the configured assertion attributes into the user and group accounts,
+//the subject NameID is used as the user id when no uid attribute is configured
+func (s *SClient) assertionToAccounts(assertion *gosaml.Assertion) (Account, []Account, error) {
+	attributes := make(map[string][]string)
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			for _, value := range attribute.Values {
+				attributes[attribute.Name] = append(attributes[attribute.Name], value.Value)
+				if attribute.FriendlyName != "" {
+					attributes[attribute.FriendlyName] = append(attributes[attribute.FriendlyName], value.Value)
+				}
+			}
+		}
+	}
+
+	var user Account
+	if s.config.UIDField != "" {
+		user.ID = first(attributes[s.config.UIDField])
+	} else if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		user.ID = assertion.Subject.NameID.Value
+	}
+	if user.ID == "" {
+		return Account{}, nil, fmt.Errorf("No user id found in the SAML assertion")
+	}
+	user.Login = first(attributes[s.config.UserNameField])
+	if user.Login == "" {
+		user.Login = user.ID
+	}
+	user.Name = first(attributes[s.config.DisplayNameField])
+
+	var groups []Account
+	for _, group := range attributes[s.config.GroupsField] {
+		if group != "" {
+			groups = append(groups, Account{ID: group, Login: group, Name: group})
+		}
+	}
+
+	return user, groups, nil
+}
+
+func first(values []string) string {
+	if len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,211 @@
+package saml
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	gosaml "github.com/crewjam/saml"
+
+	"github.com/rancher/rancher-auth-service/model"
+	"github.com/rancher/rancher-auth-service/util"
+)
+
+const (
+	metadataPath = "/v1-rancher-auth/saml/metadata"
+	acsPath      = "/v1-rancher-auth/saml/acs"
+)
+
+//SClient wraps the SAML service provider
+type SClient struct {
+	httpClient      *http.Client
+	config          *model.SamlConfig
+	serviceProvider *gosaml.ServiceProvider
+	sessionKey      []byte
+	requestKey      []byte
+}
+
+func (s *SClient) initServiceProvider() error {
+	key, err := parsePrivateKey(s.config.SPKey)
+	if err != nil {
+		return err
+	}
+	cert, err := parseCertificate(s.config.SPCert)
+	if err != nil {
+		return err
+	}
+	idpMetadata, err := s.getIDPMetadata()
+	if err != nil {
+		return err
+	}
+
+	apiHost := strings.TrimSuffix(s.config.RancherAPIHost, "/")
+	metadataURL, err := url.Parse(apiHost + metadataPath)
+	if err != nil {
+		return err
+	}
+	acsURL, err := url.Parse(apiHost + acsPath)
+	if err != nil {
+		return err
+	}
+
+	s.serviceProvider = &gosaml.ServiceProvider{
+		EntityID:    metadataURL.String(),
+		Key:         key,
+		Certificate: cert,
+		HTTPClient:  s.httpClient,
+		MetadataURL: *metadataURL,
+		AcsURL:      *acsURL,
+		IDPMetadata: idpMetadata,
+	}
+	s.sessionKey = util.DeriveKey(key, sessionKeyLabel)
+	s.requestKey = util.DeriveKey(key, requestKeyLabel)
+	return nil
+}
+
+func parsePrivateKey(keyPEM string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("Failed to decode the SP private key PEM")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the SP private key: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("The SP private key is not an RSA key")
+	}
+	return key, nil
+}
+
+func parseCertificate(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, fmt.Errorf("Failed to decode the SP certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the SP certificate: %v", err)
+	}
+	return cert, nil
+}
+
+//getIDPMetadata reads the IdP metadata from the config content or downloads it from the metadata url
+func (s *SClient) getIDPMetadata() (*gosaml.EntityDescriptor, error) {
+	data := []byte(s.config.IDPMetadataContent)
+	if len(data) == 0 {
+		resp, err := s.httpClient.Get(s.config.IDPMetadataURL)
+		if err != nil {
+			log.Errorf("SAML getIDPMetadata: error reading %v, err: %v", s.config.IDPMetadataURL, err)
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 {
+			var body bytes.Buffer
+			io.Copy(&body, resp.Body)
+			return nil, fmt.Errorf("Request failed, got status code: %d. Response: %s",
+				resp.StatusCode, body.Bytes())
+		}
+		data, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entity := &gosaml.EntityDescriptor{}
+	if err := xml.Unmarshal(data, entity); err == nil && len(entity.IDPSSODescriptors) > 0 {
+		return entity, nil
+	}
+
+	entities := &gosaml.EntitiesDescriptor{}
+	if err := xml.Unmarshal(data, entities); err != nil {
+		return nil, fmt.Errorf("Failed to parse the IdP metadata: %v", err)
+	}
+	for i := range entities.EntityDescriptors {
+		if len(entities.EntityDescriptors[i].IDPSSODescriptors) > 0 {
+			return &entities.EntityDescriptors[i], nil
+		}
+	}
+	return nil, fmt.Errorf("No IDPSSODescriptor found in the IdP metadata")
+}
+
+//getMetadata returns the SP metadata XML
+func (s *SClient) getMetadata() ([]byte, error) {
+	return xml.MarshalIndent(s.serviceProvider.Metadata(), "", "  ")
+}
+
+//getIDPURL returns the HTTP-Redirect single sign on location of the IdP, or the metadata url when it is unknown
+func (s *SClient) getIDPURL() string {
+	if s.serviceProvider != nil {
+		if idpURL := s.serviceProvider.GetSSOBindingLocation(gosaml.HTTPRedirectBinding); idpURL != "" {
+			return idpURL
+		}
+	}
+	return s.config.IDPMetadataURL
+}
+
+//getLoginURL returns the IdP redirect url carrying a new AuthnRequest, its id is bound to the RelayState
+func (s *SClient) getLoginURL(redirectTo string) (string, error) {
+	idpURL := s.serviceProvider.GetSSOBindingLocation(gosaml.HTTPRedirectBinding)
+	if idpURL == "" {
+		return "", fmt.Errorf("The IdP metadata has no HTTP-Redirect single sign on location")
+	}
+	req, err := s.serviceProvider.MakeAuthenticationRequest(idpURL, gosaml.HTTPRedirectBinding, gosaml.HTTPPostBinding)
+	if err != nil {
+		return "", err
+	}
+	relayState, requestID, err := s.newRelayState(redirectTo)
+	if err != nil {
+		return "", err
+	}
+	req.ID = requestID
+	redirectURL, err := req.Redirect(relayState, s.serviceProvider)
+	if err != nil {
+		return "", err
+	}
+	return redirectURL.String(), nil
+}
+
+//parseResponse validates the signed base64 SAMLResponse posted to the ACS endpoint, it must answer the
+//AuthnRequest the RelayState was issued with
+func (s *SClient) parseResponse(samlResponse string, relayState string) (*gosaml.Assertion, error) {
+	decoded, err := decodeResponse(samlResponse)
+	if err != nil {
+		return nil, err
+	}
+	requestID, err := s.relayStateRequestID(relayState)
+	if err != nil {
+		return nil, err
+	}
+
+	assertion, err := s.serviceProvider.ParseXMLResponse(decoded, []string{requestID})
+	if err != nil {
+		if invalid, ok := err.(*gosaml.InvalidResponseError); ok {
+			log.Errorf("SAML parseResponse: invalid response, err: %v", invalid.PrivateErr)
+		}
+		return nil, err
+	}
+	return assertion, nil
+}
+
+func decodeResponse(samlResponse string) ([]byte, error) {
+	decoded, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode the SAMLResponse: %v", err)
+	}
+	return decoded, nil
+}
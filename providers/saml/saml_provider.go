@@ -0,0 +1,273 @@
+package saml
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/go-rancher/client"
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+//Constants for saml
+const (
+	Name                      = "saml"
+	Config                    = Name + "config"
+	TokenType                 = Name + "jwt"
+	UserType                  = Name + "_user"
+	GroupType                 = Name + "_group"
+	idpMetadataURLSetting     = "api.auth.saml.idp.metadata.url"
+	idpMetadataContentSetting = "api.auth.saml.idp.metadata.content"
+	spCertSetting             = "api.auth.saml.sp.cert"
+	spKeySetting              = "api.auth.saml.sp.key"
+	rancherAPIHostSetting     = "api.auth.saml.rancher.api.host"
+	uidFieldSetting           = "api.auth.saml.uid.field"
+	displayNameFieldSetting   = "api.auth.saml.display.name.field"
+	userNameFieldSetting      = "api.auth.saml.user.name.field"
+	groupsFieldSetting        = "api.auth.saml.groups.field"
+)
+
+//InitializeProvider returns a new instance of the provider
+func InitializeProvider() *SProvider {
+	samlClient := &SClient{}
+	samlClient.httpClient = &http.Client{}
+
+	samlProvider := &SProvider{}
+	samlProvider.samlClient = samlClient
+
+	return samlProvider
+}
+
+//SProvider implements an IdentityProvider for SAML 2.0 identity providers
+type SProvider struct {
+	samlClient *SClient
+}
+
+//GetName returns the name of the provider
+func (s *SProvider) GetName() string {
+	return Name
+}
+
+//GetEndpoint returns the IdP single sign on url, or its metadata url
+func (s *SProvider) GetEndpoint() string {
+	return s.samlClient.getIDPURL()
+}
+
+//GetMetadata returns the service provider metadata XML
+func (s *SProvider) GetMetadata() ([]byte, error) {
+	return s.samlClient.getMetadata()
+}
+
+//GetLoginURL returns the IdP url to redirect the browser to for signing in, the browser is sent back to
+//the redirectTo after the ACS
+func (s *SProvider) GetLoginURL(redirectTo string) (string, error) {
+	return s.samlClient.getLoginURL(redirectTo)
+}
+
+//GenerateToken validates the base64 SAMLResponse posted to the ACS endpoint and returns the token,
+//the securityCode is the SAMLResponse and the RelayState separated by a space
+func (s *SProvider) GenerateToken(securityCode string) (model.Token, error) {
+	log.Debug("SamlIdentityProvider GenerateToken called")
+	samlResponse, relayState := splitACSCode(securityCode)
+	assertion, err := s.samlClient.parseResponse(samlResponse, relayState)
+	if err != nil {
+		log.Errorf("Error validating the SAML response %v", err)
+		return model.Token{}, err
+	}
+
+	user, groups, err := s.samlClient.assertionToAccounts(assertion)
+	if err != nil {
+		return model.Token{}, err
+	}
+
+	accessToken, err := s.samlClient.newSession(user, groups)
+	if err != nil {
+		return model.Token{}, err
+	}
+
+	var token model.Token
+	token.AccessToken = accessToken
+	token.IdentityList = toIdentities(user, groups)
+	token.Type = TokenType
+	token.ExternalAccountID = user.ID
+	return token, nil
+}
+
+func toIdentities(user Account, groups []Account) []client.Identity {
+	var identities []client.Identity
+
+	userIdentity := client.Identity{Resource: client.Resource{
+		Type: "identity",
+	}}
+	user.toIdentity(UserType, &userIdentity)
+	identities = append(identities, userIdentity)
+
+	for _, group := range groups {
+		groupIdentity := client.Identity{Resource: client.Resource{
+			Type: "identity",
+		}}
+		group.toIdentity(GroupType, &groupIdentity)
+		identities = append(identities, groupIdentity)
+	}
+
+	return identities
+}
+
+//RefreshToken returns the token for the identities asserted at login
+func (s *SProvider) RefreshToken(accessToken string) (model.Token, error) {
+	loginSession, err := s.samlClient.getSession(accessToken)
+	if err != nil {
+		return model.Token{}, err
+	}
+
+	var token model.Token
+	token.AccessToken = accessToken
+	token.IdentityList = toIdentities(loginSession.User, loginSession.Groups)
+	token.Type = TokenType
+	token.ExternalAccountID = loginSession.User.ID
+	return token, nil
+}
+
+//GetIdentities returns list of user and group identities asserted at login for this token
+func (s *SProvider) GetIdentities(accessToken string) ([]client.Identity, error) {
+	loginSession, err := s.samlClient.getSession(accessToken)
+	if err != nil {
+		return []client.Identity{}, err
+	}
+	return toIdentities(loginSession.User, loginSession.Groups), nil
+}
+
+//GetIdentity returns the identity by externalID and externalIDType, SAML offers no directory
+//lookup so the identity is built from the id unless it was asserted for the token
+func (s *SProvider) GetIdentity(externalID string, externalIDType string, accessToken string) (client.Identity, error) {
+	identity := client.Identity{Resource: client.Resource{
+		Type: "identity",
+	}}
+
+	switch externalIDType {
+	case UserType, GroupType:
+		if known, err := s.GetIdentities(accessToken); err == nil {
+			for _, knownIdentity := range known {
+				if knownIdentity.ExternalIdType == externalIDType && knownIdentity.ExternalId == externalID {
+					return knownIdentity, nil
+				}
+			}
+		}
+		account := Account{ID: externalID, Login: externalID}
+		account.toIdentity(externalIDType, &identity)
+		return identity, nil
+	default:
+		log.Debugf("Cannot get the saml account due to invalid externalIDType %v", externalIDType)
+		return identity, fmt.Errorf("Cannot get the saml account due to invalid externalIDType %v", externalIDType)
+	}
+}
+
+//SearchIdentities returns the identity by name, SAML offers no search so the identities asserted
+//for the token are filtered, an exact name not found there is returned as a group
+func (s *SProvider) SearchIdentities(name string, exactMatch bool, accessToken string) ([]client.Identity, error) {
+	var identities []client.Identity
+
+	if known, err := s.GetIdentities(accessToken); err == nil {
+		for _, identity := range known {
+			if identity.Login == name || identity.Name == name || (!exactMatch && strings.HasPrefix(identity.Login, name)) {
+				identities = append(identities, identity)
+			}
+		}
+	}
+
+	if exactMatch && len(identities) == 0 {
+		groupIdentity := client.Identity{Resource: client.Resource{
+			Type: "identity",
+		}}
+		group := Account{ID: name, Login: name, Name: name}
+		group.toIdentity(GroupType, &groupIdentity)
+		identities = append(identities, groupIdentity)
+	}
+
+	return identities, nil
+}
+
+//LoadConfig initializes the provider with the passes config
+func (s *SProvider) LoadConfig(authConfig model.AuthConfig) error {
+	configObj := authConfig.SamlConfig
+	if configObj.SPCert == "" || configObj.SPKey == "" {
+		return fmt.Errorf("Missing SPCert or SPKey in samlConfig")
+	}
+	if configObj.IDPMetadataURL == "" && configObj.IDPMetadataContent == "" {
+		return fmt.Errorf("Missing IDPMetadataURL or IDPMetadataContent in samlConfig")
+	}
+	if configObj.RancherAPIHost == "" {
+		return fmt.Errorf("Missing RancherAPIHost in samlConfig")
+	}
+	s.samlClient.config = &configObj
+	return s.samlClient.initServiceProvider()
+}
+
+//GetConfig returns the provider config
+func (s *SProvider) GetConfig() model.AuthConfig {
+	log.Debug("In saml getConfig")
+
+	authConfig := model.AuthConfig{Resource: client.Resource{
+		Type: "config",
+	}}
+
+	authConfig.Provider = Config
+	authConfig.SamlConfig = *s.samlClient.config
+
+	authConfig.SamlConfig.Resource = client.Resource{
+		Type: "samlconfig",
+	}
+
+	return authConfig
+}
+
+//GetSettings transforms the provider config to db settings
+func (s *SProvider) GetSettings() map[string]string {
+	settings := make(map[string]string)
+
+	settings[idpMetadataURLSetting] = s.samlClient.config.IDPMetadataURL
+	settings[idpMetadataContentSetting] = s.samlClient.config.IDPMetadataContent
+	settings[spCertSetting] = s.samlClient.config.SPCert
+	settings[spKeySetting] = s.samlClient.config.SPKey
+	settings[rancherAPIHostSetting] = s.samlClient.config.RancherAPIHost
+	settings[uidFieldSetting] = s.samlClient.config.UIDField
+	settings[displayNameFieldSetting] = s.samlClient.config.DisplayNameField
+	settings[userNameFieldSetting] = s.samlClient.config.UserNameField
+	settings[groupsFieldSetting] = s.samlClient.config.GroupsField
+
+	return settings
+}
+
+//GetProviderSettingList returns the provider specific db setting list
+func (s *SProvider) GetProviderSettingList() []string {
+	var settings []string
+	settings = append(settings, idpMetadataURLSetting)
+	settings = append(settings, idpMetadataContentSetting)
+	settings = append(settings, spCertSetting)
+	settings = append(settings, spKeySetting)
+	settings = append(settings, rancherAPIHostSetting)
+	settings = append(settings, uidFieldSetting)
+	settings = append(settings, displayNameFieldSetting)
+	settings = append(settings, userNameFieldSetting)
+	settings = append(settings, groupsFieldSetting)
+	return settings
+}
+
+//AddProviderConfig adds the provider config into the generic config using the settings from db
+func (s *SProvider) AddProviderConfig(authConfig *model.AuthConfig, providerSettings map[string]string) {
+	samlConfig := model.SamlConfig{Resource: client.Resource{
+		Type: "samlconfig",
+	}}
+	samlConfig.IDPMetadataURL = providerSettings[idpMetadataURLSetting]
+	samlConfig.IDPMetadataContent = providerSettings[idpMetadataContentSetting]
+	samlConfig.SPCert = providerSettings[spCertSetting]
+	samlConfig.SPKey = providerSettings[spKeySetting]
+	samlConfig.RancherAPIHost = providerSettings[rancherAPIHostSetting]
+	samlConfig.UIDField = providerSettings[uidFieldSetting]
+	samlConfig.DisplayNameField = providerSettings[displayNameFieldSetting]
+	samlConfig.UserNameField = providerSettings[userNameFieldSetting]
+	samlConfig.GroupsField = providerSettings[groupsFieldSetting]
+
+	authConfig.SamlConfig = samlConfig
+}
@@ -0,0 +1,218 @@
+package saml
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"encoding/xml"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	gosaml "github.com/crewjam/saml"
+
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+func newKeyPair(t *testing.T, commonName string) (*rsa.PrivateKey, *x509.Certificate, string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return key, cert, string(keyPEM), string(certPEM)
+}
+
+//testIDP signs the assertions for the service provider metadata it was given
+type testIDP struct {
+	idp        *gosaml.IdentityProvider
+	spMetadata *gosaml.EntityDescriptor
+}
+
+func (i *testIDP) GetServiceProvider(r *http.Request, serviceProviderID string) (*gosaml.EntityDescriptor, error) {
+	return i.spMetadata, nil
+}
+
+func newTestIDP(t *testing.T) *testIDP {
+	key, cert, _, _ := newKeyPair(t, "idp.example.com")
+	metadataURL, _ := url.Parse("https://idp.example.com/metadata")
+	ssoURL, _ := url.Parse("https://idp.example.com/sso")
+	testIdp := &testIDP{}
+	testIdp.idp = &gosaml.IdentityProvider{
+		Key:                     key,
+		Certificate:             cert,
+		MetadataURL:             *metadataURL,
+		SSOURL:                  *ssoURL,
+		ServiceProviderProvider: testIdp,
+		AssertionMaker:          gosaml.DefaultAssertionMaker{},
+	}
+	return testIdp
+}
+
+func newTestProviders(t *testing.T, testIdp *testIDP) (*SProvider, *SProvider) {
+	idpMetadata, err := xml.Marshal(testIdp.idp.Metadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, spKey, spCert := newKeyPair(t, "rancher.example.com")
+	config := model.SamlConfig{
+		IDPMetadataContent: string(idpMetadata),
+		SPCert:             spCert,
+		SPKey:              spKey,
+		RancherAPIHost:     "https://rancher.example.com",
+		UserNameField:      "uid",
+		DisplayNameField:   "cn",
+		GroupsField:        "eduPersonAffiliation",
+	}
+
+	//two instances sharing the config, as behind a load balancer
+	var providers []*SProvider
+	for i := 0; i < 2; i++ {
+		provider := InitializeProvider()
+		if err := provider.LoadConfig(model.AuthConfig{SamlConfig: config}); err != nil {
+			t.Fatal(err)
+		}
+		providers = append(providers, provider)
+	}
+
+	spMetadata, err := providers[0].GetMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testIdp.spMetadata = &gosaml.EntityDescriptor{}
+	if err := xml.Unmarshal(spMetadata, testIdp.spMetadata); err != nil {
+		t.Fatal(err)
+	}
+	return providers[0], providers[1]
+}
+
+//login follows the IdP redirect of the provider and returns the SAMLResponse and RelayState posted back to the ACS
+func (i *testIDP) login(t *testing.T, provider *SProvider, redirectTo string) (string, string) {
+	loginURL, err := provider.GetLoginURL(redirectTo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(loginURL, "https://idp.example.com/sso?") {
+		t.Fatalf("Unexpected login url %v", loginURL)
+	}
+
+	req, err := gosaml.NewIdpAuthnRequest(i.idp, httptest.NewRequest("GET", loginURL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	err = gosaml.DefaultAssertionMaker{}.MakeAssertion(req, &gosaml.Session{
+		ID:             "s-1",
+		NameID:         "u-1",
+		UserName:       "alice",
+		UserCommonName: "Alice",
+		Groups:         []string{"admins", "devs"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	form, err := req.PostBinding()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return form.SAMLResponse, form.RelayState
+}
+
+func TestLoginOnAnotherInstance(t *testing.T) {
+	testIdp := newTestIDP(t)
+	first, second := newTestProviders(t, testIdp)
+
+	samlResponse, relayState := testIdp.login(t, first, "/dashboard")
+	if redirectTo := RelayStateRedirect(relayState); redirectTo != "/dashboard" {
+		t.Fatalf("Unexpected redirectTo %v", redirectTo)
+	}
+
+	token, err := second.GenerateToken(ACSCode(samlResponse, relayState))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, identity := range token.IdentityList {
+		ids = append(ids, identity.Id)
+	}
+	if joined := strings.Join(ids, ","); joined != "saml_user:u-1,saml_group:admins,saml_group:devs" {
+		t.Fatalf("Unexpected identities %v", joined)
+	}
+	if token.ExternalAccountID != "u-1" || token.IdentityList[0].Name != "Alice" || token.IdentityList[0].Login != "alice" {
+		t.Fatalf("Unexpected user %v", token.IdentityList[0])
+	}
+
+	//the session is held by the accessToken, the first instance refreshes it
+	refreshed, err := first.RefreshToken(token.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refreshed.IdentityList) != 3 || refreshed.ExternalAccountID != "u-1" {
+		t.Fatalf("Unexpected refreshed token %v", refreshed)
+	}
+}
+
+func TestGenerateTokenRejectsAnotherRelayState(t *testing.T) {
+	testIdp := newTestIDP(t)
+	first, second := newTestProviders(t, testIdp)
+
+	samlResponse, relayState := testIdp.login(t, first, "/dashboard")
+	_, otherRelayState := testIdp.login(t, first, "/dashboard")
+
+	for _, tampered := range []string{
+		"",
+		otherRelayState,
+		relayState[:relayPrefixSize] + "/elsewhere",
+	} {
+		if _, err := second.GenerateToken(ACSCode(samlResponse, tampered)); err == nil {
+			t.Errorf("Expected the response to be rejected with the RelayState %q", tampered)
+		}
+	}
+}
+
+func TestGetIdentitiesRejectsAForgedSession(t *testing.T) {
+	testIdp := newTestIDP(t)
+	first, _ := newTestProviders(t, testIdp)
+
+	accessToken, err := first.samlClient.newSession(Account{ID: "u-1", Login: "alice"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.GetIdentities(accessToken); err != nil {
+		t.Fatal(err)
+	}
+
+	forged, err := first.samlClient.newSession(Account{ID: "u-1", Login: "alice"}, []Account{{ID: "admins"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Split(forged, ".")[0] + "." + strings.Split(accessToken, ".")[1]
+	for _, value := range []string{tampered, "u-1", ""} {
+		if _, err := first.GetIdentities(value); err == nil {
+			t.Errorf("Expected the session %q to be rejected", value)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package saml
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//The login sessions and the AuthnRequest ids are signed with keys derived from the SP private key instead of being
+//kept in memory, so that any instance sharing the samlConfig accepts the ACS post and the tokens of the others
+const (
+	requestTTL       = 10 * time.Minute
+	sessionTTL       = 24 * time.Hour
+	relayPrefixSize  = 16
+	sessionKeyLabel  = "rancher-auth-service saml session"
+	requestKeyLabel  = "rancher-auth-service saml request"
+	requestIDPrefix  = "id-"
+	requestIDHexSize = 40
+)
+
+//session holds the accounts asserted at login, it is the signed accessToken handed out
+type session struct {
+	User    Account   `json:"user"`
+	Groups  []Account `json:"groups,omitempty"`
+	Expires int64     `json:"exp"`
+}
+
+func sign(key []byte, value string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+//newSession returns the signed accessToken holding the accounts
+func (s *SClient) newSession(user Account, groups []Account) (string, error) {
+	payload, err := json.Marshal(session{User: user, Groups: groups, Expires: time.Now().Add(sessionTTL).Unix()})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(sign(s.sessionKey, encoded)), nil
+}
+
+//getSession verifies the signature and expiry of the accessToken and returns its accounts
+func (s *SClient) getSession(accessToken string) (session, error) {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 2 {
+		return session{}, fmt.Errorf("No SAML session found for the accessToken, please login again")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, sign(s.sessionKey, parts[0])) {
+		return session{}, fmt.Errorf("No SAML session found for the accessToken, please login again")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return session{}, err
+	}
+	var loginSession session
+	if err := json.Unmarshal(payload, &loginSession); err != nil {
+		return session{}, err
+	}
+	if time.Now().Unix() > loginSession.Expires {
+		return session{}, fmt.Errorf("The SAML session expired, please login again")
+	}
+	return loginSession, nil
+}
+
+//newRelayState returns the RelayState of a new AuthnRequest and the request id it is bound to. The RelayState
+//is a prefix carrying its expiry and a nonce, followed by the redirectTo, the id is their signature
+func (s *SClient) newRelayState(redirectTo string) (string, string, error) {
+	prefix := make([]byte, 12)
+	binary.BigEndian.PutUint32(prefix, uint32(time.Now().Add(requestTTL).Unix()))
+	if _, err := rand.Read(prefix[4:]); err != nil {
+		return "", "", err
+	}
+	relayState := base64.RawURLEncoding.EncodeToString(prefix) + redirectTo
+	return relayState, s.requestID(relayState), nil
+}
+
+func (s *SClient) requestID(relayState string) string {
+	return requestIDPrefix + hex.EncodeToString(sign(s.requestKey, relayState))[:requestIDHexSize]
+}
+
+//relayStateRequestID returns the id of the AuthnRequest the RelayState was issued with, none when it expired
+func (s *SClient) relayStateRequestID(relayState string) (string, error) {
+	if len(relayState) < relayPrefixSize {
+		return "", fmt.Errorf("The RelayState was not issued by the SAML login")
+	}
+	prefix, err := base64.RawURLEncoding.DecodeString(relayState[:relayPrefixSize])
+	if err != nil || len(prefix) != 12 {
+		return "", fmt.Errorf("The RelayState was not issued by the SAML login")
+	}
+	if time.Now().Unix() > int64(binary.BigEndian.Uint32(prefix)) {
+		return "", fmt.Errorf("The SAML login expired, please login again")
+	}
+	return s.requestID(relayState), nil
+}
+
+//RelayStateRedirect returns the redirectTo the SAML login was started with
+func RelayStateRedirect(relayState string) string {
+	if len(relayState) < relayPrefixSize {
+		return ""
+	}
+	return relayState[relayPrefixSize:]
+}
+
+//ACSCode returns the security code of the SAMLResponse posted to the ACS along with its RelayState
+func ACSCode(samlResponse string, relayState string) string {
+	return samlResponse + " " + relayState
+}
+
+func splitACSCode(securityCode string) (string, string) {
+	parts := strings.SplitN(securityCode, " ", 2)
+	if len(parts) != 2 {
+		return securityCode, ""
+	}
+	return parts[0], parts[1]
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/rancher/go-rancher/client"
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+//Access modes of the auth config
+const (
+	//UnrestrictedAccessMode lets every user of the provider log in
+	UnrestrictedAccessMode = "unrestricted"
+	//RestrictedAccessMode lets in the allowed identities, the environment members are unknown to the service and have to be listed too
+	RestrictedAccessMode = "restricted"
+	//RequiredAccessMode lets in the allowed identities only
+	RequiredAccessMode = "required"
+)
+
+//AccessDeniedError is returned when none of the identities of the user is allowed by the access mode
+type AccessDeniedError struct {
+	AccountID  string
+	AccessMode string
+}
+
+func (e *AccessDeniedError) Error() string {
+	return fmt.Sprintf("Access denied for %v, the %v access mode only allows the configured allowed identities", e.AccountID, e.AccessMode)
+}
+
+func validAccessMode(accessMode string) bool {
+	switch accessMode {
+	case "", UnrestrictedAccessMode, RestrictedAccessMode, RequiredAccessMode:
+		return true
+	}
+	return false
+}
+
+//checkAccess intersects the identities of the user with the allowed identities of the access mode
+func checkAccess(accountID string, identities []client.Identity, authConfig model.AuthConfig) error {
+	switch authConfig.AccessMode {
+	case "", UnrestrictedAccessMode:
+		return nil
+	case RestrictedAccessMode, RequiredAccessMode:
+		allowed := make(map[string]bool)
+		for _, identity := range authConfig.AllowedIdentities {
+			allowed[identity.Resource.Id] = true
+		}
+		for _, identity := range identities {
+			if allowed[identity.Resource.Id] {
+				return nil
+			}
+		}
+	}
+	return &AccessDeniedError{AccountID: accountID, AccessMode: authConfig.AccessMode}
+}
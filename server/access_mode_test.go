@@ -0,0 +1,55 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/rancher/go-rancher/client"
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+func identity(id string) client.Identity {
+	return client.Identity{Resource: client.Resource{Id: id}}
+}
+
+func TestCheckAccess(t *testing.T) {
+	userIdentities := []client.Identity{identity("github_user:1"), identity("github_org:2")}
+	allowed := []client.Identity{identity("github_org:2")}
+	other := []client.Identity{identity("github_org:3")}
+
+	for _, test := range []struct {
+		accessMode string
+		allowed    []client.Identity
+		denied     bool
+	}{
+		{"", nil, false},
+		{UnrestrictedAccessMode, other, false},
+		{RestrictedAccessMode, allowed, false},
+		{RestrictedAccessMode, other, true},
+		{RequiredAccessMode, allowed, false},
+		{RequiredAccessMode, other, true},
+		{RequiredAccessMode, nil, true},
+		{"unknown", allowed, true},
+	} {
+		authConfig := model.AuthConfig{AccessMode: test.accessMode, AllowedIdentities: test.allowed}
+		err := checkAccess("1", userIdentities, authConfig)
+		if denied := err != nil; denied != test.denied {
+			t.Errorf("Unexpected access %v for the %q access mode with %v", err, test.accessMode, test.allowed)
+		}
+		if err != nil {
+			if accessDenied, ok := err.(*AccessDeniedError); !ok || accessDenied.AccountID != "1" || accessDenied.AccessMode != test.accessMode {
+				t.Errorf("Unexpected error %#v", err)
+			}
+		}
+	}
+}
+
+func TestValidAccessMode(t *testing.T) {
+	for _, accessMode := range []string{"", UnrestrictedAccessMode, RestrictedAccessMode, RequiredAccessMode} {
+		if !validAccessMode(accessMode) {
+			t.Errorf("Expected %q to be valid", accessMode)
+		}
+	}
+	if validAccessMode("Restricted") {
+		t.Error("Expected the access modes to be case sensitive")
+	}
+}
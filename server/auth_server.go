@@ -1,17 +1,18 @@
 package server
 
 import (
-	"crypto/rsa"
-	"flag"
+	"crypto/cipher"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
+	"time"
 	log "github.com/Sirupsen/logrus"
 
 	"github.com/rancher/go-rancher/client"
 	"github.com/rancher/rancher-auth-service/model"
 	"github.com/rancher/rancher-auth-service/providers"
+	"github.com/rancher/rancher-auth-service/providers/oidc"
+	"github.com/rancher/rancher-auth-service/providers/saml"
 	"github.com/rancher/rancher-auth-service/util"
 )
 
@@ -25,90 +26,13 @@ const (
 
 var (
 	provider       providers.IdentityProvider
-	privateKey     *rsa.PrivateKey
-	publicKey      *rsa.PublicKey
+	keyring        *util.Keyring
+	accessTokenSealer cipher.AEAD
+	accessTokenOpeners []cipher.AEAD
 	authConfigInMemory 	   model.AuthConfig
-	rancherClient  *client.RancherClient
-	debug          = flag.Bool("debug", false, "Debug")
-	logFile        = flag.String("log", "", "Log file")
-	publicKeyFile  = flag.String("publicKeyFile", "", "Path of file containing RSA Public key")
-	privateKeyFile = flag.String("privateKeyFile", "", "Path of file containing RSA Private key")
+	configStore    ConfigStore
 )
 
-//SetEnv sets the parameters necessary
-func SetEnv() {
-	flag.Parse()
-
-	textFormatter := &log.TextFormatter{
-		FullTimestamp: true,
-	}
-	log.SetFormatter(textFormatter)
-
-	if *debug {
-		log.SetLevel(log.DebugLevel)
-	}
-
-	if *publicKeyFile == "" {
-		log.Fatal("Please provide the RSA public key, halting")
-		return
-	}
-	publicKey = util.ParsePublicKey(*publicKeyFile)
-
-	if *privateKeyFile == "" {
-		log.Fatal("Please provide the RSA private key, halting")
-		return
-	}
-	privateKey = util.ParsePrivateKey(*privateKeyFile)
-
-	cattleURL := os.Getenv("CATTLE_URL")
-	if len(cattleURL) == 0 {
-		log.Fatalf("CATTLE_URL is not set")
-	}
-
-	cattleAPIKey := os.Getenv("CATTLE_ACCESS_KEY")
-	if len(cattleAPIKey) == 0 {
-		log.Fatalf("CATTLE_ACCESS_KEY is not set")
-	}
-
-	cattleSecretKey := os.Getenv("CATTLE_SECRET_KEY")
-	if len(cattleSecretKey) == 0 {
-		log.Fatalf("CATTLE_SECRET_KEY is not set")
-	}
-
-	//configure cattle client
-	var err error
-	rancherClient, err = newCattleClient(cattleURL, cattleAPIKey, cattleSecretKey)
-	if err != nil {
-		log.Fatalf("Failed to configure cattle client: %v", err)
-	}
-
-	err = testCattleConnect()
-	if err != nil {
-		log.Errorf("Failed to connect to rancher cattle client: %v", err)
-	}
-}
-
-func newCattleClient(cattleURL string, cattleAccessKey string, cattleSecretKey string) (*client.RancherClient, error) {
-	apiClient, err := client.NewRancherClient(&client.ClientOpts{
-		Url:       cattleURL,
-		AccessKey: cattleAccessKey,
-		SecretKey: cattleSecretKey,
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	return apiClient, nil
-}
-
-func testCattleConnect() error {
-	opts := &client.ListOpts{}
-	_, err := rancherClient.ContainerEvent.List(opts)
-	return err
-}
-
-
 func initProviderWithConfig(authConfig model.AuthConfig) (providers.IdentityProvider, error) {
 	newProvider := providers.GetProvider(authConfig.Provider)
 	if newProvider == nil {
@@ -126,30 +50,65 @@ func readSettings(settings []string) (map[string]string, error) {
 	var dbSettings = make(map[string]string)
 	
 	for _, key := range settings {
-		setting, err := rancherClient.Setting.ById(key)
+		storedValue, found, err := configStore.GetSetting(key)
 		if err != nil {
 			log.Errorf("Error reading the setting %v , error: %v", key, err)
 			return dbSettings, err
 		}
-		dbSettings[key] = setting.ActiveValue
+		if !found {
+			continue
+		}
+		value, err := decryptSetting(key, storedValue)
+		if err != nil {
+			log.Errorf("Error decrypting the setting %v , error: %v", key, err)
+			return dbSettings, err
+		}
+		dbSettings[key] = value
 	}
 	
 	return dbSettings, nil
 }
 
+//listSettings reads the settings whose key starts with the prefix
+func listSettings(prefix string) (map[string]string, error) {
+	storedSettings, err := configStore.ListSettings(prefix)
+	if err != nil {
+		log.Errorf("Error listing the settings %v, error: %v", prefix, err)
+		return nil, err
+	}
+	dbSettings := make(map[string]string)
+	for key, storedValue := range storedSettings {
+		value, err := decryptSetting(key, storedValue)
+		if err != nil {
+			log.Errorf("Error decrypting the setting %v , error: %v", key, err)
+			return nil, err
+		}
+		dbSettings[key] = value
+	}
+	return dbSettings, nil
+}
+
+func deleteSettings(keys []string) error {
+	for _, key := range keys {
+		if err := configStore.DeleteSetting(key); err != nil {
+			log.Errorf("Error deleting the setting %v, error: %v", key, err)
+			return err
+		}
+	}
+	return nil
+}
+
 func updateSettings(settings map[string]string) error {
 	for key, value := range settings {
 		if value != "" {
-			setting, err := rancherClient.Setting.ById(key)
+			value, err := encryptSetting(key, value)
 			if err != nil {
-				log.Errorf("Error getting the setting %v , error: %v", key, err)
+				log.Errorf("Error encrypting the setting %v , error: %v", key, err)
 				return err
-			}	
-			setting, err = rancherClient.Setting.Update(setting, &client.Setting{
-				Value: value,
-			})
+			}
+			err = configStore.SetSetting(key, value)
 			if err != nil {
-				log.Errorf("Error updating the setting %v to value %v, error: %v", key, value, err)
+				log.Errorf("Error updating the setting %v, error: %v", key, err)
 				return err
 			}
 		}
@@ -208,6 +167,20 @@ func getAllowedIdentities(idString string, accessToken string) []client.Identity
 
 //UpdateConfig updates the config in DB
 func UpdateConfig(authConfig model.AuthConfig) error {
+	if !validAccessMode(authConfig.AccessMode) {
+		return fmt.Errorf("Invalid accessMode %v, expected unrestricted, restricted or required", authConfig.AccessMode)
+	}
+
+	//the secrets are write-only, keep the stored ones the request omits or sends back masked
+	currentConfig, err := readProviderConfig(authConfig.Provider)
+	if err != nil {
+		log.Errorf("UpdateConfig: Cannot read the current config, error %v", err)
+		return err
+	}
+	if err := util.PreserveSecrets(&authConfig, &currentConfig); err != nil {
+		log.Errorf("UpdateConfig: Cannot update the config, error %v", err)
+		return err
+	}
 
 	newProvider, err := initProviderWithConfig(authConfig)
 	if err != nil {
@@ -237,6 +210,21 @@ func UpdateConfig(authConfig model.AuthConfig) error {
 	return nil
 }
 
+//readProviderConfig reads the provider specific config stored in the DB
+func readProviderConfig(providerName string) (model.AuthConfig, error) {
+	var config model.AuthConfig
+	storedProvider := providers.GetProvider(providerName)
+	if storedProvider == nil {
+		return config, fmt.Errorf("Could not get the %s auth provider", providerName)
+	}
+	providerSettings, err := readSettings(storedProvider.GetProviderSettingList())
+	if err != nil {
+		return config, err
+	}
+	storedProvider.AddProviderConfig(&config, providerSettings)
+	return config, nil
+}
+
 //GetConfig gets the config from DB, gathers the list of settings to read from DB
 func GetConfig(accessToken string) (model.AuthConfig, error) {
 	var config model.AuthConfig
@@ -246,6 +234,15 @@ func GetConfig(accessToken string) (model.AuthConfig, error) {
 			Type: "config",
 		}}
 
+	if accessToken != "" {
+		var err error
+		accessToken, err = resolveAccessToken(accessToken)
+		if err != nil {
+			log.Errorf("GetConfig: Error verifying the token %v", err)
+			return config, err
+		}
+	}
+
 	//add the generic settings
 	settings = append(settings, accessModeSetting)
 	settings = append(settings, allowedIdentitiesSetting)
@@ -299,45 +296,60 @@ func Reload() error {
 	}
 	provider = newProvider
 	authConfigInMemory = authConfig	
+
+	//pick up the tokens revoked through the other instances
+	if err := loadRevocations(); err != nil {
+		log.Errorf("Error reloading the revoked tokens %v", err)
+	}
 	return nil
 }
 
 //CreateToken will authenticate with provider and create a jwt token
 func CreateToken(securityCode string) (string, error) {
+	start := time.Now()
+	jwt, err := createToken(securityCode)
+	observeTokenRequest("create", err, start)
+	return jwt, err
+}
+
+func createToken(securityCode string) (string, error) {
 	if provider != nil {
 		token, err := provider.GenerateToken(securityCode)
 		if err != nil {
 			return "", err
 		}
+		if err := checkAccess(token.ExternalAccountID, token.IdentityList, authConfigInMemory); err != nil {
+			return "", err
+		}
 	
-		payload := make(map[string]interface{})
-		payload["token"] = token.Type
-		payload["account_id"] = token.ExternalAccountID
-		payload["access_token"] = token.AccessToken
-		payload["idList"] = identitiesToIDList(token.IdentityList)
-		payload["identities"] = token.IdentityList
-	
-		return util.CreateTokenWithPayload(payload, privateKey)
+		return createJWT(token)
 	} 
 	return "", fmt.Errorf("No auth provider configured")
 }
 
 //RefreshToken will refresh a jwt token
 func RefreshToken(accessToken string) (string, error) {
+	start := time.Now()
+	jwt, err := refreshToken(accessToken)
+	observeTokenRequest("refresh", err, start)
+	return jwt, err
+}
+
+func refreshToken(accessToken string) (string, error) {
 	if provider != nil {
+		accessToken, err := resolveAccessToken(accessToken)
+		if err != nil {
+			return "", err
+		}
 		token, err := provider.RefreshToken(accessToken)
 		if err != nil {
 			return "", err
 		}
+		if err := checkAccess(token.ExternalAccountID, token.IdentityList, authConfigInMemory); err != nil {
+			return "", err
+		}
 	
-		payload := make(map[string]interface{})
-		payload["token"] = token.Type
-		payload["account_id"] = token.ExternalAccountID
-		payload["access_token"] = token.AccessToken
-		payload["idList"] = identitiesToIDList(token.IdentityList)
-		payload["identities"] = token.IdentityList
-	
-		return util.CreateTokenWithPayload(payload, privateKey)
+		return createJWT(token)
 	} 
 	return "", fmt.Errorf("No auth provider configured")
 }
@@ -353,6 +365,10 @@ func identitiesToIDList(identities []client.Identity) []string {
 //GetIdentities will list all identities for token
 func GetIdentities(accessToken string) ([]client.Identity, error) {
 	if provider != nil {
+		accessToken, err := resolveAccessToken(accessToken)
+		if err != nil {
+			return []client.Identity{}, err
+		}
 		return provider.GetIdentities(accessToken)
 	}
 	return []client.Identity{}, fmt.Errorf("No auth provider configured")
@@ -361,6 +377,10 @@ func GetIdentities(accessToken string) ([]client.Identity, error) {
 //GetIdentity will list all identities for given filters
 func GetIdentity(externalID string, externalIDType string, accessToken string) (client.Identity, error) {
 	if provider != nil {
+		accessToken, err := resolveAccessToken(accessToken)
+		if err != nil {
+			return client.Identity{}, err
+		}
 		return provider.GetIdentity(externalID, externalIDType, accessToken)
 	}
 	return client.Identity{}, fmt.Errorf("No auth provider configured")
@@ -369,7 +389,48 @@ func GetIdentity(externalID string, externalIDType string, accessToken string) (
 //SearchIdentities will list all identities for given filters
 func SearchIdentities(name string, exactMatch bool, accessToken string) ([]client.Identity, error) {
 	if provider != nil {
+		accessToken, err := resolveAccessToken(accessToken)
+		if err != nil {
+			return []client.Identity{}, err
+		}
 		return provider.SearchIdentities(name, exactMatch, accessToken)
 	}
 	return []client.Identity{}, fmt.Errorf("No auth provider configured")
 }
+
+//GetSamlMetadata returns the SAML service provider metadata of the configured provider
+func GetSamlMetadata() ([]byte, error) {
+	samlProvider, ok := provider.(*saml.SProvider)
+	if !ok {
+		return nil, fmt.Errorf("SAML auth provider is not configured")
+	}
+	return samlProvider.GetMetadata()
+}
+
+//GetSamlLoginURL returns the IdP url the browser is sent to for the SAML login
+func GetSamlLoginURL(redirectTo string) (string, error) {
+	samlProvider, ok := provider.(*saml.SProvider)
+	if !ok {
+		return "", fmt.Errorf("SAML auth provider is not configured")
+	}
+	return samlProvider.GetLoginURL(redirectTo)
+}
+
+//CreateSamlToken validates the SAMLResponse posted to the ACS and returns the jwt token, along with the
+//redirectTo the login was started with
+func CreateSamlToken(samlResponse string, relayState string) (string, string, error) {
+	token, err := CreateToken(saml.ACSCode(samlResponse, relayState))
+	if err != nil {
+		return "", "", err
+	}
+	return token, saml.RelayStateRedirect(relayState), nil
+}
+
+//GetOIDCLoginURL returns the authorization endpoint url the browser is sent to for the OpenID Connect login
+func GetOIDCLoginURL(state string) (string, error) {
+	oidcProvider, ok := provider.(*oidc.OProvider)
+	if !ok {
+		return "", fmt.Errorf("OIDC auth provider is not configured")
+	}
+	return oidcProvider.GetLoginURL(state)
+}
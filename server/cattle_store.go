@@ -0,0 +1,167 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/go-rancher/client"
+	"github.com/rancher/rancher-auth-service/metrics"
+)
+
+//cattleListLimit is the page size of the settings listed by prefix
+const cattleListLimit = 100
+
+//cattleStore keeps the settings in the settings table of the Cattle database
+type cattleStore struct {
+	rancherClient *client.RancherClient
+}
+
+//newCattleStore configures the cattle client from the cattle-url, cattle-access-key and cattle-secret-key flags
+func newCattleStore() (*cattleStore, error) {
+	if len(cattleURL) == 0 {
+		return nil, fmt.Errorf("CATTLE_URL is not set")
+	}
+
+	if len(cattleAccessKey) == 0 {
+		return nil, fmt.Errorf("CATTLE_ACCESS_KEY is not set")
+	}
+
+	if len(cattleSecretKey) == 0 {
+		return nil, fmt.Errorf("CATTLE_SECRET_KEY is not set")
+	}
+
+	//configure cattle client
+	rancherClient, err := newCattleClient(cattleURL, cattleAccessKey, cattleSecretKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to configure cattle client: %v", err)
+	}
+	store := &cattleStore{rancherClient: rancherClient}
+
+	err = store.testCattleConnect()
+	if err != nil {
+		log.Errorf("Failed to connect to rancher cattle client: %v", err)
+	}
+	return store, nil
+}
+
+func newCattleClient(cattleURL string, cattleAccessKey string, cattleSecretKey string) (*client.RancherClient, error) {
+	apiClient, err := client.NewRancherClient(&client.ClientOpts{
+		Url:       cattleURL,
+		AccessKey: cattleAccessKey,
+		SecretKey: cattleSecretKey,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return apiClient, nil
+}
+
+func (c *cattleStore) testCattleConnect() error {
+	opts := &client.ListOpts{}
+	_, err := c.rancherClient.ContainerEvent.List(opts)
+	return err
+}
+
+func (c *cattleStore) GetSetting(key string) (string, bool, error) {
+	setting, err := c.rancherClient.Setting.ById(key)
+	if err != nil {
+		metrics.CountCattleSettingError("read")
+		return "", false, err
+	}
+	if setting == nil {
+		return "", false, nil
+	}
+	return setting.ActiveValue, true, nil
+}
+
+func (c *cattleStore) SetSetting(key string, value string) error {
+	err := c.setSetting(key, value)
+	if err != nil {
+		metrics.CountCattleSettingError("write")
+	}
+	return err
+}
+
+func (c *cattleStore) setSetting(key string, value string) error {
+	setting, err := c.rancherClient.Setting.ById(key)
+	if err != nil {
+		return err
+	}
+	if setting == nil {
+		//the setting is not defined yet
+		_, err = c.rancherClient.Setting.Create(&client.Setting{
+			Name:  key,
+			Value: value,
+		})
+		return err
+	}
+	_, err = c.rancherClient.Setting.Update(setting, &client.Setting{
+		Value: value,
+	})
+	return err
+}
+
+func (c *cattleStore) ListSettings(prefix string) (map[string]string, error) {
+	settings, err := c.listSettings(prefix)
+	if err != nil {
+		metrics.CountCattleSettingError("read")
+	}
+	return settings, err
+}
+
+//listSettings follows the pagination markers of the settings filtered by the name prefix
+func (c *cattleStore) listSettings(prefix string) (map[string]string, error) {
+	settings := make(map[string]string)
+	opts := &client.ListOpts{Filters: map[string]interface{}{
+		"name_prefix": prefix,
+		"limit":       cattleListLimit,
+	}}
+	for {
+		collection, err := c.rancherClient.Setting.List(opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, setting := range collection.Data {
+			if strings.HasPrefix(setting.Name, prefix) {
+				settings[setting.Name] = setting.ActiveValue
+			}
+		}
+		marker := nextMarker(collection.Pagination)
+		if marker == "" || marker == opts.Filters["marker"] {
+			return settings, nil
+		}
+		opts.Filters["marker"] = marker
+	}
+}
+
+//nextMarker returns the marker of the next page, empty on the last page
+func nextMarker(pagination *client.Pagination) string {
+	if pagination == nil || pagination.Next == "" {
+		return ""
+	}
+	next, err := url.Parse(pagination.Next)
+	if err != nil {
+		return ""
+	}
+	return next.Query().Get("marker")
+}
+
+func (c *cattleStore) DeleteSetting(key string) error {
+	err := c.deleteSetting(key)
+	if err != nil {
+		metrics.CountCattleSettingError("write")
+	}
+	return err
+}
+
+func (c *cattleStore) deleteSetting(key string) error {
+	setting, err := c.rancherClient.Setting.ById(key)
+	if err != nil || setting == nil {
+		return err
+	}
+	return c.rancherClient.Setting.Delete(setting)
+}
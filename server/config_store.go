@@ -0,0 +1,86 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//Config stores selectable with the config-store flag
+const (
+	cattleConfigStore = "cattle"
+	fileConfigStore   = "file"
+	memoryConfigStore = "memory"
+)
+
+//ConfigStore holds the settings of the service, readSettings and updateSettings go through it
+type ConfigStore interface {
+	//GetSetting returns the value of the setting, found is false when the setting is not defined
+	GetSetting(key string) (value string, found bool, err error)
+	SetSetting(key string, value string) error
+	//ListSettings returns the settings whose key starts with the prefix, the records kept one setting each
+	//are written concurrently by the instances sharing the store without overwriting each other
+	ListSettings(prefix string) (map[string]string, error)
+	//DeleteSetting removes the setting, it is not an error when the setting is not defined
+	DeleteSetting(key string) error
+}
+
+//newConfigStore returns the store named by the config-store flag
+func newConfigStore(name string) (ConfigStore, error) {
+	switch name {
+	case cattleConfigStore:
+		return newCattleStore()
+	case fileConfigStore:
+		if configFile == "" {
+			return nil, fmt.Errorf("Please provide the config-file of the file config store")
+		}
+		return newFileStore(configFile)
+	case memoryConfigStore:
+		return newMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("Unknown config store %v, expected cattle, file or memory", name)
+	}
+}
+
+//memoryStore keeps the settings in memory only, for running standalone and testing
+type memoryStore struct {
+	mutex    sync.RWMutex
+	settings map[string]string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{settings: make(map[string]string)}
+}
+
+func (m *memoryStore) GetSetting(key string) (string, bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	value, found := m.settings[key]
+	return value, found, nil
+}
+
+func (m *memoryStore) SetSetting(key string, value string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.settings[key] = value
+	return nil
+}
+
+func (m *memoryStore) ListSettings(prefix string) (map[string]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	settings := make(map[string]string)
+	for key, value := range m.settings {
+		if strings.HasPrefix(key, prefix) {
+			settings[key] = value
+		}
+	}
+	return settings, nil
+}
+
+func (m *memoryStore) DeleteSetting(key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.settings, key)
+	return nil
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+//fileStore keeps the settings as a flat JSON or YAML object in a file, YAML is used for the .yaml and .yml files
+type fileStore struct {
+	mutex    sync.Mutex
+	path     string
+	settings map[string]string
+}
+
+func newFileStore(path string) (*fileStore, error) {
+	store := &fileStore{path: path, settings: make(map[string]string)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if store.isYAML() {
+		err = yaml.Unmarshal(data, &store.settings)
+	} else if len(data) > 0 {
+		err = json.Unmarshal(data, &store.settings)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (f *fileStore) isYAML() bool {
+	ext := strings.ToLower(filepath.Ext(f.path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func (f *fileStore) GetSetting(key string) (string, bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	value, found := f.settings[key]
+	return value, found, nil
+}
+
+func (f *fileStore) ListSettings(prefix string) (map[string]string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	settings := make(map[string]string)
+	for key, value := range f.settings {
+		if strings.HasPrefix(key, prefix) {
+			settings[key] = value
+		}
+	}
+	return settings, nil
+}
+
+func (f *fileStore) SetSetting(key string, value string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	settings := f.copySettings()
+	settings[key] = value
+	return f.write(settings)
+}
+
+func (f *fileStore) DeleteSetting(key string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if _, found := f.settings[key]; !found {
+		return nil
+	}
+	settings := f.copySettings()
+	delete(settings, key)
+	return f.write(settings)
+}
+
+//copySettings returns a copy of the settings to change, the caller holds the mutex
+func (f *fileStore) copySettings() map[string]string {
+	settings := make(map[string]string)
+	for k, v := range f.settings {
+		settings[k] = v
+	}
+	return settings
+}
+
+//write writes the whole file again, through a temporary file so it is never left half written, the caller holds the mutex
+func (f *fileStore) write(settings map[string]string) error {
+	var data []byte
+	var err error
+	if f.isYAML() {
+		data, err = yaml.Marshal(settings)
+	} else {
+		data, err = json.MarshalIndent(settings, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	//the settings hold secrets
+	if err := os.Chmod(tmpFile.Name(), 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile.Name(), f.path); err != nil {
+		return err
+	}
+	f.settings = settings
+	return nil
+}
@@ -0,0 +1,294 @@
+package server
+
+import (
+	"os"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
+
+	"github.com/rancher/rancher-auth-service/providers/local"
+	"github.com/rancher/rancher-auth-service/providers/oidc"
+)
+
+//Flags are the global command line flags of the service, each one can also be set by its env var.
+//The secrets are not bound to the env by the flag so that the help does not print them
+var Flags = []cli.Flag{
+	cli.StringFlag{
+		Name:   "listen",
+		Value:  ":8090",
+		Usage:  "Address to listen on",
+		EnvVar: "RANCHER_AUTH_LISTEN",
+	},
+	cli.DurationFlag{
+		Name:   "shutdown-timeout",
+		Value:  30 * time.Second,
+		Usage:  "Time the in-flight requests are given to complete on SIGTERM or SIGINT before their connections are closed",
+		EnvVar: "RANCHER_AUTH_SHUTDOWN_TIMEOUT",
+	},
+	cli.StringFlag{
+		Name:   "tls-cert",
+		Usage:  "Path of the PEM certificate file to serve HTTPS, reloaded on change or SIGHUP",
+		EnvVar: "RANCHER_AUTH_TLS_CERT",
+	},
+	cli.StringFlag{
+		Name:   "tls-key",
+		Usage:  "Path of the PEM private key file of the tls-cert",
+		EnvVar: "RANCHER_AUTH_TLS_KEY",
+	},
+	cli.StringFlag{
+		Name:   "tls-client-ca",
+		Usage:  "Path of the PEM CA bundle verifying the client certificates, the clients are not asked for one when not set",
+		EnvVar: "RANCHER_AUTH_TLS_CLIENT_CA",
+	},
+	cli.StringFlag{
+		Name:   "tls-client-auth",
+		Value:  "require",
+		Usage:  "Client certificate verification when the tls-client-ca is set: require or optional",
+		EnvVar: "RANCHER_AUTH_TLS_CLIENT_AUTH",
+	},
+	cli.BoolFlag{
+		Name:   "debug",
+		Usage:  "Debug",
+		EnvVar: "RANCHER_AUTH_DEBUG",
+	},
+	cli.StringFlag{
+		Name:   "log",
+		Usage:  "Log file, the log goes to stderr when not set",
+		EnvVar: "RANCHER_AUTH_LOG",
+	},
+	cli.StringFlag{
+		Name:   "log-format",
+		Value:  "text",
+		Usage:  "Log format: text or json",
+		EnvVar: "RANCHER_AUTH_LOG_FORMAT",
+	},
+	cli.StringFlag{
+		Name:   "public-key-file, publicKeyFile",
+		Usage:  "Path of file containing RSA Public key",
+		EnvVar: "RANCHER_AUTH_PUBLIC_KEY_FILE",
+	},
+	cli.StringFlag{
+		Name:   "private-key-file, privateKeyFile",
+		Usage:  "Path of file containing RSA Private key",
+		EnvVar: "RANCHER_AUTH_PRIVATE_KEY_FILE",
+	},
+	cli.StringFlag{
+		Name:   "retired-public-key-files",
+		Usage:  "Comma separated paths of the RSA public keys of previous signing keys, valid for verification for the key-grace-period",
+		EnvVar: "RANCHER_AUTH_RETIRED_PUBLIC_KEY_FILES",
+	},
+	cli.DurationFlag{
+		Name:   "key-grace-period",
+		Value:  16 * time.Hour,
+		Usage:  "Time the previous signing key stays valid for verification after a new key is loaded",
+		EnvVar: "RANCHER_AUTH_KEY_GRACE_PERIOD",
+	},
+	cli.DurationFlag{
+		Name:   "token-ttl",
+		Value:  16 * time.Hour,
+		Usage:  "Lifetime of the issued JWT tokens",
+		EnvVar: "RANCHER_AUTH_TOKEN_TTL",
+	},
+	cli.StringFlag{
+		Name:   "token-issuer",
+		Value:  "rancher-auth-service",
+		Usage:  "Issuer (iss) of the issued JWT tokens",
+		EnvVar: "RANCHER_AUTH_TOKEN_ISSUER",
+	},
+	cli.StringFlag{
+		Name:   "token-audience",
+		Usage:  "Audience (aud) of the issued JWT tokens, not set nor checked when empty",
+		EnvVar: "RANCHER_AUTH_TOKEN_AUDIENCE",
+	},
+	cli.StringFlag{
+		Name:   "access-token-key-file",
+		Usage:  "Path of file containing the 32 bytes AES key encrypting the provider access token in the JWT tokens, derived from the RSA private key when not set",
+		EnvVar: "RANCHER_AUTH_ACCESS_TOKEN_KEY_FILE",
+	},
+	cli.StringFlag{
+		Name:   "admin-identities",
+		Usage:  "Comma separated identity ids, e.g. local_user:admin, whose tokens may use the admin APIs",
+		EnvVar: "RANCHER_AUTH_ADMIN_IDENTITIES",
+	},
+	cli.StringFlag{
+		Name:   "introspection-clients-file",
+		Usage:  "Path of file containing the client_id:client_secret lines of the clients allowed to introspect tokens",
+		EnvVar: "RANCHER_AUTH_INTROSPECTION_CLIENTS_FILE",
+	},
+	cli.StringFlag{
+		Name:   "config-store",
+		Value:  cattleConfigStore,
+		Usage:  "Store of the settings: cattle, file or memory",
+		EnvVar: "RANCHER_AUTH_CONFIG_STORE",
+	},
+	cli.StringFlag{
+		Name:   "config-file",
+		Usage:  "Path of the JSON or YAML (.yaml, .yml) file of the file config store",
+		EnvVar: "RANCHER_AUTH_CONFIG_FILE",
+	},
+	cli.StringFlag{
+		Name:   "cattle-url",
+		Usage:  "Url of the Cattle API of the cattle config store",
+		EnvVar: "CATTLE_URL",
+	},
+	cli.StringFlag{
+		Name:   "cattle-access-key",
+		Usage:  "Access key of the Cattle service account",
+		EnvVar: "CATTLE_ACCESS_KEY",
+	},
+	cli.StringFlag{
+		Name:  "cattle-secret-key",
+		Usage: "Secret key of the Cattle service account, the CATTLE_SECRET_KEY env var is used when not set",
+	},
+	cli.StringFlag{
+		Name:   "settings-key-file",
+		Usage:  "Path of file containing the 32 bytes AES master key encrypting the provider secrets in the settings, the SETTINGS_ENCRYPTION_KEY env var is used when not set",
+		EnvVar: "RANCHER_AUTH_SETTINGS_KEY_FILE",
+	},
+	cli.StringFlag{
+		Name:   "previous-settings-key-files",
+		Usage:  "Comma separated paths of the previous settings master keys, only used to decrypt",
+		EnvVar: "RANCHER_AUTH_PREVIOUS_SETTINGS_KEY_FILES",
+	},
+}
+
+var (
+	publicKeyFile            string
+	privateKeyFile           string
+	retiredPublicKeyFiles    string
+	keyGracePeriod           time.Duration
+	tokenTTL                 time.Duration
+	tokenIssuer              string
+	tokenAudience            string
+	accessTokenKeyFile       string
+	adminIdentities          string
+	introspectionClientsFile string
+	configStoreName          string
+	configFile               string
+	cattleURL                string
+	cattleAccessKey          string
+	cattleSecretKey          string
+	settingsKeyFile          string
+	previousSettingsKeyFiles string
+	tlsCertFile              string
+	tlsKeyFile               string
+	tlsClientCAFile          string
+	tlsClientAuth            string
+	logOutput                *os.File
+)
+
+//SetEnv sets the parameters from the flags, configures the logging and the config store
+func SetEnv(c *cli.Context) {
+	publicKeyFile = c.GlobalString("public-key-file")
+	privateKeyFile = c.GlobalString("private-key-file")
+	retiredPublicKeyFiles = c.GlobalString("retired-public-key-files")
+	keyGracePeriod = c.GlobalDuration("key-grace-period")
+	tokenTTL = c.GlobalDuration("token-ttl")
+	tokenIssuer = c.GlobalString("token-issuer")
+	tokenAudience = c.GlobalString("token-audience")
+	accessTokenKeyFile = c.GlobalString("access-token-key-file")
+	adminIdentities = c.GlobalString("admin-identities")
+	introspectionClientsFile = c.GlobalString("introspection-clients-file")
+	configStoreName = c.GlobalString("config-store")
+	configFile = c.GlobalString("config-file")
+	cattleURL = c.GlobalString("cattle-url")
+	cattleAccessKey = c.GlobalString("cattle-access-key")
+	cattleSecretKey = c.GlobalString("cattle-secret-key")
+	if cattleSecretKey == "" {
+		cattleSecretKey = os.Getenv("CATTLE_SECRET_KEY")
+	}
+	settingsKeyFile = c.GlobalString("settings-key-file")
+	previousSettingsKeyFiles = c.GlobalString("previous-settings-key-files")
+	tlsCertFile = c.GlobalString("tls-cert")
+	tlsKeyFile = c.GlobalString("tls-key")
+	tlsClientCAFile = c.GlobalString("tls-client-ca")
+	tlsClientAuth = c.GlobalString("tls-client-auth")
+
+	setupLogging(c.GlobalString("log"), c.GlobalString("log-format"), c.GlobalBool("debug"))
+
+	if tokenTTL <= 0 {
+		log.Fatal("The token-ttl must be a positive duration, halting")
+		return
+	}
+
+	//the id_token of an oidc session is refused once older than the tokens issued from it
+	oidc.SetSessionTTL(tokenTTL)
+
+	initSettingsEncryption()
+
+	var err error
+	configStore, err = newConfigStore(configStoreName)
+	if err != nil {
+		log.Fatalf("Failed to configure the %v config store: %v", configStoreName, err)
+	}
+
+	//keep the local provider users and groups in the settings
+	local.SetStore(&settingsLocalStore{})
+
+	//keep the revoked tokens in the settings
+	if err := SetRevocationBackend(&settingsRevocationBackend{}); err != nil {
+		log.Errorf("Failed to load the revoked tokens: %v", err)
+	}
+}
+
+func setupLogging(logFile string, logFormat string, debug bool) {
+	switch logFormat {
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	case "text":
+		log.SetFormatter(&log.TextFormatter{
+			FullTimestamp: true,
+		})
+	default:
+		log.Fatalf("Unknown log-format %v, expected text or json", logFormat)
+	}
+
+	if debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	if logFile != "" {
+		output, err := os.OpenFile(logFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			log.Fatalf("Failed to open the log file %v: %v", logFile, err)
+		}
+		log.SetOutput(output)
+		logOutput = output
+	}
+}
+
+//Shutdown flushes the log file before the service exits, the later logs go to stderr
+func Shutdown() {
+	if logOutput == nil {
+		return
+	}
+	log.SetOutput(os.Stderr)
+	if err := logOutput.Sync(); err != nil {
+		log.Errorf("Failed to flush the log file: %v", err)
+	}
+	logOutput.Close()
+	logOutput = nil
+}
+
+//InitTokenSigning loads the keys signing and verifying the tokens, needed to serve the API
+func InitTokenSigning() {
+	if publicKeyFile == "" {
+		log.Fatal("Please provide the RSA public key, halting")
+		return
+	}
+
+	if privateKeyFile == "" {
+		log.Fatal("Please provide the RSA private key, halting")
+		return
+	}
+
+	initKeyring()
+	initAccessTokenSealer()
+
+	if introspectionClientsFile != "" {
+		if err := loadIntrospectionClients(introspectionClientsFile); err != nil {
+			log.Fatalf("Failed to read the introspection clients: %v", err)
+		}
+	}
+}
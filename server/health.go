@@ -0,0 +1,156 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rancher/rancher-auth-service/model"
+	"github.com/rancher/rancher-auth-service/providers"
+)
+
+const (
+	endpointDialTimeout = 5 * time.Second
+	endpointCheckTTL    = 15 * time.Second
+)
+
+//endpointCheck keeps the last dial of the provider endpoint, the probes read it while it is refreshed in the background
+var endpointCheck struct {
+	sync.Mutex
+	address   string
+	status    string
+	message   string
+	checkedAt time.Time
+	dialing   bool
+}
+
+//CheckReadiness tells if the service can authenticate: the Cattle connectivity, the loaded provider and its endpoint
+func CheckReadiness() model.Readiness {
+	readiness := model.Readiness{Status: model.CheckOK}
+	for _, check := range []model.HealthCheck{
+		timeCheck("cattle", checkCattle),
+		timeCheck("provider", checkProvider),
+		timeCheck("providerEndpoint", checkProviderEndpoint),
+	} {
+		if check.Status == model.CheckFailed {
+			readiness.Status = model.CheckFailed
+		}
+		readiness.Checks = append(readiness.Checks, check)
+	}
+	return readiness
+}
+
+func timeCheck(name string, check func() (string, string)) model.HealthCheck {
+	start := time.Now()
+	status, message := check()
+	result := model.HealthCheck{
+		Name:    name,
+		Status:  status,
+		Message: message,
+	}
+	if status != model.CheckSkipped {
+		result.Duration = time.Since(start).String()
+	}
+	return result
+}
+
+func checkCattle() (string, string) {
+	cattle, ok := configStore.(*cattleStore)
+	if !ok {
+		return model.CheckSkipped, fmt.Sprintf("The settings are kept in the %v config store", configStoreName)
+	}
+	if err := cattle.testCattleConnect(); err != nil {
+		return model.CheckFailed, fmt.Sprintf("Failed to connect to cattle: %v", err)
+	}
+	return model.CheckOK, ""
+}
+
+func checkProvider() (string, string) {
+	if provider == nil {
+		return model.CheckSkipped, "No auth provider configured"
+	}
+	return model.CheckOK, provider.GetName()
+}
+
+//checkProviderEndpoint returns the last dial of the server of the provider and starts a new one when it is older
+//than the endpointCheckTTL, so that a probe never waits on the dial. The providers keeping the users locally have none
+func checkProviderEndpoint() (string, string) {
+	if provider == nil {
+		return model.CheckSkipped, "No auth provider configured"
+	}
+	endpointProvider, ok := provider.(providers.EndpointProvider)
+	if !ok {
+		return model.CheckSkipped, fmt.Sprintf("The %v provider has no remote endpoint", provider.GetName())
+	}
+	endpoint := endpointProvider.GetEndpoint()
+	if endpoint == "" {
+		return model.CheckSkipped, fmt.Sprintf("The %v provider endpoint is not known", provider.GetName())
+	}
+	address, err := endpointAddress(endpoint)
+	if err != nil {
+		return model.CheckFailed, fmt.Sprintf("Invalid %v provider endpoint %v: %v", provider.GetName(), endpoint, err)
+	}
+
+	endpointCheck.Lock()
+	defer endpointCheck.Unlock()
+	if endpointCheck.address != address {
+		endpointCheck.address = address
+		endpointCheck.status = ""
+		endpointCheck.checkedAt = time.Time{}
+	}
+	if !endpointCheck.dialing && time.Since(endpointCheck.checkedAt) > endpointCheckTTL {
+		endpointCheck.dialing = true
+		go dialEndpoint(address)
+	}
+	if endpointCheck.status == "" {
+		return model.CheckSkipped, fmt.Sprintf("The %v endpoint is being checked", address)
+	}
+	return endpointCheck.status, endpointCheck.message
+}
+
+//dialEndpoint keeps the result in the endpointCheck unless the provider changed meanwhile
+func dialEndpoint(address string) {
+	status, message := model.CheckOK, address
+	conn, err := net.DialTimeout("tcp", address, endpointDialTimeout)
+	if err != nil {
+		status, message = model.CheckFailed, fmt.Sprintf("Failed to reach %v: %v", address, err)
+	} else {
+		conn.Close()
+	}
+
+	endpointCheck.Lock()
+	defer endpointCheck.Unlock()
+	endpointCheck.dialing = false
+	if endpointCheck.address == address {
+		endpointCheck.status = status
+		endpointCheck.message = message
+		endpointCheck.checkedAt = time.Now()
+	}
+}
+
+//endpointAddress returns the host:port of an url, or the endpoint itself when it is already a host:port
+func endpointAddress(endpoint string) (string, error) {
+	if _, _, err := net.SplitHostPort(endpoint); err == nil {
+		return endpoint, nil
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if endpointURL.Host == "" {
+		return "", fmt.Errorf("no host")
+	}
+	if endpointURL.Port() != "" {
+		return endpointURL.Host, nil
+	}
+	switch endpointURL.Scheme {
+	case "http":
+		return net.JoinHostPort(endpointURL.Hostname(), "80"), nil
+	case "https":
+		return net.JoinHostPort(endpointURL.Hostname(), "443"), nil
+	default:
+		return "", fmt.Errorf("unknown scheme %v", endpointURL.Scheme)
+	}
+}
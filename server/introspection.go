@@ -0,0 +1,90 @@
+package server
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"os"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+//introspectionClients maps the client ids allowed to introspect tokens to their secret
+var introspectionClients = make(map[string]string)
+
+//loadIntrospectionClients reads the client_id:client_secret lines of the clients file
+func loadIntrospectionClients(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	clients := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			log.Warnf("Skipping malformed line in %v", path)
+			continue
+		}
+		clients[parts[0]] = parts[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	introspectionClients = clients
+	log.Infof("Loaded %d introspection clients", len(clients))
+	return nil
+}
+
+//AuthenticateIntrospectionClient checks the credential of a client calling the introspection API
+func AuthenticateIntrospectionClient(clientID string, clientSecret string) bool {
+	secret, ok := introspectionClients[clientID]
+	if !ok || clientID == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(clientSecret)) == 1
+}
+
+//Introspect returns the RFC 7662 introspection of a jwt token issued by the service, an invalid,
+//expired or revoked token is only reported as not active
+func Introspect(tokenString string) model.IntrospectionResponse {
+	claims, err := verifyClaims(tokenString)
+	if err != nil {
+		log.Debugf("Introspect found the token not active: %v", err)
+		return model.IntrospectionResponse{}
+	}
+	info, err := tokenInfo(claims)
+	if err != nil {
+		log.Debugf("Introspect failed to decode the token: %v", err)
+		return model.IntrospectionResponse{}
+	}
+
+	resp := model.IntrospectionResponse{
+		Active:     true,
+		Scope:      info.TokenType,
+		Username:   info.AccountID,
+		TokenType:  "Bearer",
+		Sub:        info.AccountID,
+		Aud:        info.Audience,
+		Iss:        info.Issuer,
+		Jti:        info.TokenID,
+		IDList:     info.IDList,
+		Identities: info.Identities,
+	}
+	resp.Exp = claimUnix(claims, "exp")
+	resp.Iat = claimUnix(claims, "iat")
+	resp.Nbf = claimUnix(claims, "nbf")
+	return resp
+}
+
+func claimUnix(claims map[string]interface{}, name string) int64 {
+	value, _ := claims[name].(float64)
+	return int64(value)
+}
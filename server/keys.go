@@ -0,0 +1,114 @@
+package server
+
+import (
+	"crypto/cipher"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/rancher-auth-service/model"
+	"github.com/rancher/rancher-auth-service/util"
+)
+
+const (
+	keyPollInterval = 10 * time.Second
+)
+
+//initKeyring loads the signing key, the retired public keys and starts watching the private key file for a new key
+func initKeyring() {
+	keyring = util.NewKeyring(keyGracePeriod)
+
+	privateKey := util.ParsePrivateKey(privateKeyFile)
+	publicKey := util.ParsePublicKey(publicKeyFile)
+	if util.KeyID(publicKey) != util.KeyID(&privateKey.PublicKey) {
+		log.Fatal("The RSA public key does not match the private key, halting")
+	}
+	log.Infof("Signing the tokens with the key %v", keyring.SetActiveKey(privateKey))
+
+	if retiredPublicKeyFiles != "" {
+		for _, path := range strings.Split(retiredPublicKeyFiles, ",") {
+			retiredKey := util.ParsePublicKey(strings.TrimSpace(path))
+			log.Infof("Accepting the retired key %v for %v", keyring.AddRetiredKey(retiredKey), keyGracePeriod)
+		}
+	}
+
+	go watchPrivateKey(privateKeyFile)
+}
+
+//initAccessTokenSealer loads the key encrypting the provider access token claim. The key derived from the
+//private key is the one loaded at startup, tokens sealed with it do not survive a restart with a new private key,
+//so the access-token-key-file is required once the signing key was rotated. The derived key still decrypts the
+//tokens issued before the access-token-key-file was set
+func initAccessTokenSealer() {
+	privateKey, _, err := keyring.SigningKey()
+	if err != nil {
+		log.Fatalf("Failed to derive the access token key: %v", err)
+	}
+	derivedSealer, err := util.NewSealer(util.DeriveKey(privateKey, "rancher-auth-service access_token"))
+	if err != nil {
+		log.Fatalf("Failed to initialize the access token encryption: %v", err)
+	}
+
+	if accessTokenKeyFile == "" {
+		if retiredPublicKeyFiles != "" {
+			log.Fatal("Please provide the access-token-key-file with the retired-public-key-files, the access token key derived from a retired private key is lost, halting")
+		}
+		accessTokenSealer = derivedSealer
+		accessTokenOpeners = []cipher.AEAD{derivedSealer}
+		return
+	}
+
+	key, err := util.ReadSecretKey(accessTokenKeyFile)
+	if err != nil {
+		log.Fatalf("Failed to read the access token key: %v", err)
+	}
+	sealer, err := util.NewSealer(key)
+	if err != nil {
+		log.Fatalf("Failed to initialize the access token encryption: %v", err)
+	}
+	accessTokenSealer = sealer
+	accessTokenOpeners = []cipher.AEAD{sealer, derivedSealer}
+}
+
+//watchPrivateKey polls the private key file and makes the key written to it the active key,
+//the previous key stays valid for verification for the grace period
+func watchPrivateKey(path string) {
+	var modTime time.Time
+	if stat, err := os.Stat(path); err == nil {
+		modTime = stat.ModTime()
+	}
+	for range time.Tick(keyPollInterval) {
+		stat, err := os.Stat(path)
+		if err != nil || stat.ModTime().Equal(modTime) {
+			continue
+		}
+		privateKey, err := util.ReadPrivateKey(path)
+		if err != nil {
+			log.Errorf("Failed to read the new private key, keeping the current signing key: %v", err)
+			continue
+		}
+		modTime = stat.ModTime()
+		log.Infof("Signing the tokens with the new key %v", keyring.SetActiveKey(privateKey))
+		if accessTokenKeyFile == "" {
+			log.Warn("The access token key stays derived from the previous private key, set the access-token-key-file before restarting or the tokens issued so far stop working")
+		}
+	}
+}
+
+//GetJWKS returns the public keys verifying the tokens of the service
+func GetJWKS() model.JSONWebKeySet {
+	jwks := model.JSONWebKeySet{Keys: []model.JSONWebKey{}}
+	for _, key := range keyring.PublicKeys() {
+		n, e := util.EncodeRSAPublicKey(key.PublicKey)
+		jwks.Keys = append(jwks.Keys, model.JSONWebKey{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: "RS256",
+			Kid: key.ID,
+			N:   n,
+			E:   e,
+		})
+	}
+	return jwks
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/rancher-auth-service/providers/local"
+)
+
+//Each local user, group and membership is a setting of its own, named by the base64url encoded names as
+//the names may hold dots. The setting values hold the records, the names are never decoded from the keys
+const (
+	localUserSettingPrefix   = "api.auth.local.user."
+	localGroupSettingPrefix  = "api.auth.local.group."
+	localMemberSettingPrefix = "api.auth.local.member."
+)
+
+//settingsLocalStore keeps the local users and groups as JSON in the settings of the config store
+type settingsLocalStore struct{}
+
+func localSettingID(name string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(name))
+}
+
+func localMemberSetting(group string, username string) string {
+	return localMemberSettingPrefix + localSettingID(group) + "." + localSettingID(username)
+}
+
+func (s *settingsLocalStore) Load() (local.Records, error) {
+	var records local.Records
+	users, err := listSettings(localUserSettingPrefix)
+	if err != nil {
+		return records, err
+	}
+	for key, value := range users {
+		var user local.User
+		if err := json.Unmarshal([]byte(value), &user); err != nil || user.Username == "" {
+			log.Errorf("Skipping the malformed local user %v: %v", key, err)
+			continue
+		}
+		records.Users = append(records.Users, user)
+	}
+
+	groups, err := listSettings(localGroupSettingPrefix)
+	if err != nil {
+		return records, err
+	}
+	for key, value := range groups {
+		var group local.Group
+		if err := json.Unmarshal([]byte(value), &group); err != nil || group.Name == "" {
+			log.Errorf("Skipping the malformed local group %v: %v", key, err)
+			continue
+		}
+		records.Groups = append(records.Groups, local.Group{Name: group.Name})
+	}
+
+	memberships, err := listSettings(localMemberSettingPrefix)
+	if err != nil {
+		return records, err
+	}
+	for key, value := range memberships {
+		var membership local.Membership
+		if err := json.Unmarshal([]byte(value), &membership); err != nil || membership.Group == "" || membership.Username == "" {
+			log.Errorf("Skipping the malformed local group membership %v: %v", key, err)
+			continue
+		}
+		records.Memberships = append(records.Memberships, membership)
+	}
+	return records, nil
+}
+
+func (s *settingsLocalStore) save(key string, record interface{}) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return updateSettings(map[string]string{key: string(value)})
+}
+
+func (s *settingsLocalStore) SaveUser(user local.User) error {
+	return s.save(localUserSettingPrefix+localSettingID(user.Username), user)
+}
+
+func (s *settingsLocalStore) DeleteUser(username string) error {
+	return deleteSettings([]string{localUserSettingPrefix + localSettingID(username)})
+}
+
+func (s *settingsLocalStore) SaveGroup(name string) error {
+	return s.save(localGroupSettingPrefix+localSettingID(name), local.Group{Name: name})
+}
+
+func (s *settingsLocalStore) DeleteGroup(name string) error {
+	return deleteSettings([]string{localGroupSettingPrefix + localSettingID(name)})
+}
+
+func (s *settingsLocalStore) AddMember(group string, username string) error {
+	return s.save(localMemberSetting(group, username), local.Membership{Group: group, Username: username})
+}
+
+func (s *settingsLocalStore) RemoveMember(group string, username string) error {
+	return deleteSettings([]string{localMemberSetting(group, username)})
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/rancher/rancher-auth-service/providers/local"
+)
+
+func TestSettingsLocalStoresWriteConcurrently(t *testing.T) {
+	previousStore := configStore
+	configStore = newMemoryStore()
+	defer func() { configStore = previousStore }()
+
+	//two instances sharing the config store add users to the same group at the same time
+	stores := []local.Store{&settingsLocalStore{}, &settingsLocalStore{}}
+	if err := stores[0].SaveGroup("ops.eu"); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for s, store := range stores {
+			wg.Add(1)
+			go func(username string, store local.Store) {
+				defer wg.Done()
+				if err := store.SaveUser(local.User{Username: username, PasswordHash: "hash"}); err != nil {
+					t.Error(err)
+				}
+				if err := store.AddMember("ops.eu", username); err != nil {
+					t.Error(err)
+				}
+			}(fmt.Sprintf("user.%v-%v", s, i), store)
+		}
+	}
+	wg.Wait()
+
+	records, err := stores[1].Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records.Users) != 40 || len(records.Memberships) != 40 {
+		t.Fatalf("Expected the 40 users and memberships, got %v and %v", len(records.Users), len(records.Memberships))
+	}
+	if !reflect.DeepEqual(records.Groups, []local.Group{{Name: "ops.eu"}}) {
+		t.Fatalf("Unexpected groups %v", records.Groups)
+	}
+}
+
+func TestSettingsLocalStoreRemovesRecords(t *testing.T) {
+	previousStore := configStore
+	configStore = newMemoryStore()
+	defer func() { configStore = previousStore }()
+
+	store := &settingsLocalStore{}
+	store.SaveUser(local.User{Username: "alice", PasswordHash: "hash"})
+	store.SaveGroup("devs")
+	store.AddMember("devs", "alice")
+	store.SaveUser(local.User{Username: "alice", Name: "Alice", PasswordHash: "hash"})
+
+	records, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records.Users) != 1 || records.Users[0].Name != "Alice" || len(records.Memberships) != 1 {
+		t.Fatalf("Unexpected records %v", records)
+	}
+
+	for _, err := range []error{store.RemoveMember("devs", "alice"), store.DeleteGroup("devs"), store.DeleteUser("alice")} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err = store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records.Users) != 0 || len(records.Groups) != 0 || len(records.Memberships) != 0 {
+		t.Fatalf("Expected no records left, got %v", records)
+	}
+}
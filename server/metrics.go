@@ -0,0 +1,22 @@
+package server
+
+import (
+	"time"
+
+	"github.com/rancher/rancher-auth-service/metrics"
+)
+
+//observeTokenRequest records a token creation or refresh with the configured provider
+func observeTokenRequest(operation string, err error, start time.Time) {
+	providerName := "none"
+	if provider != nil {
+		providerName = provider.GetName()
+	}
+	result := metrics.ResultSuccess
+	if _, ok := err.(*AccessDeniedError); ok {
+		result = metrics.ResultDenied
+	} else if err != nil {
+		result = metrics.ResultError
+	}
+	metrics.ObserveTokenRequest(operation, providerName, result, start)
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+//RevocationList holds the revoked tokens by jti and the accounts whose tokens are revoked up to a time,
+//both map to unix times after which the entry can be dropped or from which it applies
+type RevocationList struct {
+	//Tokens maps the jti of a revoked token to its expiry
+	Tokens map[string]int64 `json:"tokens"`
+	//Accounts maps the account_id to the time up to which its issued tokens are revoked
+	Accounts map[string]int64 `json:"accounts"`
+}
+
+//RevocationBackend persists the revocation list, the server plugs in a backend stored in the settings.
+//The instances sharing a backend add revocations concurrently, Add must not overwrite the revocations of the others
+type RevocationBackend interface {
+	Load() (RevocationList, error)
+	//Add persists the revocations of the list next to the ones already persisted
+	Add(list RevocationList) error
+	//Prune drops the persisted revocations that no longer apply, see pruneRevocations
+	Prune() error
+}
+
+//memoryRevocationBackend keeps the list in memory only, it is used until another backend is set
+type memoryRevocationBackend struct {
+	mutex sync.Mutex
+	list  RevocationList
+}
+
+func (m *memoryRevocationBackend) Load() (RevocationList, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return mergeRevocations(newRevocationList(), m.list), nil
+}
+
+func (m *memoryRevocationBackend) Add(list RevocationList) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.list = mergeRevocations(mergeRevocations(newRevocationList(), m.list), list)
+	return nil
+}
+
+func (m *memoryRevocationBackend) Prune() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.list = mergeRevocations(newRevocationList(), m.list)
+	pruneRevocations(&m.list)
+	return nil
+}
+
+const (
+	//revocationRefreshInterval is how long the in-memory list is used before the persisted one is read again,
+	//to pick up the tokens revoked through the other instances
+	revocationRefreshInterval = 10 * time.Second
+)
+
+var (
+	revocationMutex   sync.RWMutex
+	revocationBackend RevocationBackend = &memoryRevocationBackend{}
+	revocations       = newRevocationList()
+	//revocationsLoaded is when the list was last read or saved, revocationsVersion counts its replacements
+	revocationsLoaded  time.Time
+	revocationsVersion int
+	revocationsReading bool
+)
+
+func newRevocationList() RevocationList {
+	return RevocationList{
+		Tokens:   make(map[string]int64),
+		Accounts: make(map[string]int64),
+	}
+}
+
+//SetRevocationBackend sets the backend the revocation list is persisted in and loads the list from it
+func SetRevocationBackend(backend RevocationBackend) error {
+	revocationMutex.Lock()
+	revocationBackend = backend
+	revocationMutex.Unlock()
+	return loadRevocations()
+}
+
+//loadRevocations replaces the in-memory revocation list with the persisted one
+func loadRevocations() error {
+	revocationMutex.Lock()
+	defer revocationMutex.Unlock()
+
+	list, err := revocationBackend.Load()
+	if err != nil {
+		return err
+	}
+	setRevocations(mergeRevocations(newRevocationList(), list))
+	return nil
+}
+
+//setRevocations replaces the in-memory list, the caller holds the revocationMutex
+func setRevocations(list RevocationList) {
+	revocations = list
+	revocationsLoaded = time.Now()
+	revocationsVersion++
+}
+
+//refreshRevocations reads the persisted list again when the in-memory one is older than the revocationRefreshInterval.
+//A single caller reads it while the others keep using the current list, which is also kept on errors
+func refreshRevocations() {
+	revocationMutex.Lock()
+	if revocationsReading || time.Since(revocationsLoaded) < revocationRefreshInterval {
+		revocationMutex.Unlock()
+		return
+	}
+	revocationsReading = true
+	backend := revocationBackend
+	version := revocationsVersion
+	revocationMutex.Unlock()
+
+	list, err := backend.Load()
+
+	revocationMutex.Lock()
+	defer revocationMutex.Unlock()
+	revocationsReading = false
+	if err != nil {
+		log.Errorf("Error reading the revoked tokens, keeping the current list: %v", err)
+		revocationsLoaded = time.Now()
+		return
+	}
+	//a revocation saved meanwhile is newer than the list read
+	if version == revocationsVersion {
+		setRevocations(mergeRevocations(newRevocationList(), list))
+	}
+}
+
+//addRevocations persists the revocations of the list and reads the persisted list again, expired entries are dropped on the way
+func addRevocations(added RevocationList) error {
+	revocationMutex.Lock()
+	defer revocationMutex.Unlock()
+
+	if err := revocationBackend.Add(added); err != nil {
+		return err
+	}
+	if err := revocationBackend.Prune(); err != nil {
+		log.Errorf("Error dropping the expired revoked tokens: %v", err)
+	}
+
+	list := mergeRevocations(newRevocationList(), revocations)
+	if persisted, err := revocationBackend.Load(); err != nil {
+		log.Errorf("Error reading the revoked tokens, keeping the current list: %v", err)
+	} else {
+		list = mergeRevocations(newRevocationList(), persisted)
+	}
+	list = mergeRevocations(list, added)
+	pruneRevocations(&list)
+	setRevocations(list)
+	return nil
+}
+
+//mergeRevocations adds the revocations of other to the list, an account keeps its latest revocation
+func mergeRevocations(list RevocationList, other RevocationList) RevocationList {
+	for jti, exp := range other.Tokens {
+		list.Tokens[jti] = exp
+	}
+	for accountID, until := range other.Accounts {
+		if current, ok := list.Accounts[accountID]; !ok || until > current {
+			list.Accounts[accountID] = until
+		}
+	}
+	return list
+}
+
+//pruneRevocations drops the tokens past their expiry and the accounts revoked longer than a token lifetime ago
+func pruneRevocations(list *RevocationList) {
+	now := time.Now()
+	for jti, exp := range list.Tokens {
+		if tokenRevocationExpired(exp, now) {
+			delete(list.Tokens, jti)
+		}
+	}
+	for accountID, until := range list.Accounts {
+		if accountRevocationExpired(until, now) {
+			delete(list.Accounts, accountID)
+		}
+	}
+}
+
+//tokenRevocationExpired tells if the revoked token has expired, it is refused anyway from then on
+func tokenRevocationExpired(exp int64, now time.Time) bool {
+	return exp < now.Unix()
+}
+
+//accountRevocationExpired tells if the tokens issued before the revocation of the account have all expired
+func accountRevocationExpired(until int64, now time.Time) bool {
+	return until+int64(tokenTTL.Seconds()) < now.Unix()
+}
+
+//isRevoked tells if the verified claims belong to a revoked token
+func isRevoked(claims map[string]interface{}) bool {
+	refreshRevocations()
+
+	revocationMutex.RLock()
+	defer revocationMutex.RUnlock()
+
+	if jti, ok := claims["jti"].(string); ok {
+		if _, revoked := revocations.Tokens[jti]; revoked {
+			return true
+		}
+	}
+	accountID, _ := claims["account_id"].(string)
+	if until, revoked := revocations.Accounts[accountID]; revoked {
+		iat, ok := claims["iat"].(float64)
+		return !ok || int64(iat) <= until
+	}
+	return false
+}
+
+//Logout revokes the jwt token until its expiry
+func Logout(tokenString string) error {
+	claims, err := verifyClaims(tokenString)
+	if err != nil {
+		return err
+	}
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return fmt.Errorf("The token has no jti")
+	}
+	exp, _ := claims["exp"].(float64)
+	revoked := newRevocationList()
+	revoked.Tokens[jti] = int64(exp)
+	err = addRevocations(revoked)
+	if err != nil {
+		log.Errorf("Error revoking the token %v: %v", jti, err)
+	}
+	return err
+}
+
+//RevokeAccount revokes all the tokens issued to the account until now
+func RevokeAccount(accountID string) error {
+	if accountID == "" {
+		return fmt.Errorf("No account id provided")
+	}
+	revoked := newRevocationList()
+	revoked.Accounts[accountID] = time.Now().Unix()
+	err := addRevocations(revoked)
+	if err != nil {
+		log.Errorf("Error revoking the tokens of the account %v: %v", accountID, err)
+	}
+	return err
+}
+
+//IsAdmin verifies the jwt token and tells if one of its identities is listed in the adminIdentities
+func IsAdmin(tokenString string) (bool, error) {
+	info, err := VerifyToken(tokenString)
+	if err != nil {
+		return false, err
+	}
+	for _, admin := range strings.Split(adminIdentities, ",") {
+		admin = strings.TrimSpace(admin)
+		if admin == "" {
+			continue
+		}
+		for _, id := range info.IDList {
+			if id == admin {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+//Each revocation is a setting of its own, so that the instances sharing the config store add them without
+//reading and writing back the revocations of the others
+const (
+	revokedTokenSettingPrefix   = "api.auth.revoked.token."
+	revokedAccountSettingPrefix = "api.auth.revoked.account."
+)
+
+//accountRevocation is the value of a revoked account setting, the account id is not part of the setting name
+//as it can be any string, each revocation of an account is a new setting named by a random id
+type accountRevocation struct {
+	AccountID string `json:"accountId"`
+	Until     int64  `json:"until"`
+}
+
+//settingsRevocationBackend keeps the revocations in the settings of the config store, the token settings are
+//named by the jti and hold its expiry
+type settingsRevocationBackend struct{}
+
+func (s *settingsRevocationBackend) Load() (RevocationList, error) {
+	list := newRevocationList()
+	tokens, err := listSettings(revokedTokenSettingPrefix)
+	if err != nil {
+		return list, err
+	}
+	for key, value := range tokens {
+		exp, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			log.Errorf("Skipping the malformed revoked token %v: %v", key, err)
+			continue
+		}
+		list.Tokens[strings.TrimPrefix(key, revokedTokenSettingPrefix)] = exp
+	}
+
+	accounts, err := s.loadAccounts()
+	if err != nil {
+		return list, err
+	}
+	for _, revocation := range accounts {
+		if until, ok := list.Accounts[revocation.AccountID]; !ok || revocation.Until > until {
+			list.Accounts[revocation.AccountID] = revocation.Until
+		}
+	}
+	return list, nil
+}
+
+//loadAccounts returns the account revocations by setting name
+func (s *settingsRevocationBackend) loadAccounts() (map[string]accountRevocation, error) {
+	settings, err := listSettings(revokedAccountSettingPrefix)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make(map[string]accountRevocation)
+	for key, value := range settings {
+		var revocation accountRevocation
+		if err := json.Unmarshal([]byte(value), &revocation); err != nil || revocation.AccountID == "" {
+			log.Errorf("Skipping the malformed revoked account %v: %v", key, err)
+			continue
+		}
+		accounts[key] = revocation
+	}
+	return accounts, nil
+}
+
+func (s *settingsRevocationBackend) Add(list RevocationList) error {
+	settings := make(map[string]string)
+	for jti, exp := range list.Tokens {
+		settings[revokedTokenSettingPrefix+jti] = strconv.FormatInt(exp, 10)
+	}
+	for accountID, until := range list.Accounts {
+		id, err := newTokenID()
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(accountRevocation{AccountID: accountID, Until: until})
+		if err != nil {
+			return err
+		}
+		settings[revokedAccountSettingPrefix+id] = string(value)
+	}
+	return updateSettings(settings)
+}
+
+func (s *settingsRevocationBackend) Prune() error {
+	now := time.Now()
+	var expired []string
+	tokens, err := listSettings(revokedTokenSettingPrefix)
+	if err != nil {
+		return err
+	}
+	for key, value := range tokens {
+		if exp, err := strconv.ParseInt(value, 10, 64); err == nil && tokenRevocationExpired(exp, now) {
+			expired = append(expired, key)
+		}
+	}
+
+	accounts, err := s.loadAccounts()
+	if err != nil {
+		return err
+	}
+	for key, revocation := range accounts {
+		if accountRevocationExpired(revocation.Until, now) {
+			expired = append(expired, key)
+		}
+	}
+	return deleteSettings(expired)
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSettingsRevocationBackendsAddConcurrently(t *testing.T) {
+	previousStore := configStore
+	configStore = newMemoryStore()
+	defer func() { configStore = previousStore }()
+
+	//two instances sharing the config store revoke tokens and accounts at the same time
+	backends := []RevocationBackend{&settingsRevocationBackend{}, &settingsRevocationBackend{}}
+	exp := time.Now().Add(time.Hour).Unix()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		for b, backend := range backends {
+			wg.Add(1)
+			go func(i int, b int, backend RevocationBackend) {
+				defer wg.Done()
+				list := newRevocationList()
+				list.Tokens[fmt.Sprintf("t-%v-%v", b, i)] = exp
+				list.Accounts[fmt.Sprintf("account-%v", i%5)] = exp + int64(b)
+				if err := backend.Add(list); err != nil {
+					t.Error(err)
+				}
+			}(i, b, backend)
+		}
+	}
+	wg.Wait()
+
+	for _, backend := range backends {
+		list, err := backend.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list.Tokens) != 100 {
+			t.Fatalf("Expected the 100 revoked tokens, got %v", len(list.Tokens))
+		}
+		if len(list.Accounts) != 5 {
+			t.Fatalf("Expected the 5 revoked accounts, got %v", list.Accounts)
+		}
+		for accountID, until := range list.Accounts {
+			if until != exp+1 {
+				t.Fatalf("Expected the latest revocation of %v, got %v", accountID, until)
+			}
+		}
+	}
+}
+
+func TestSettingsRevocationBackendPrune(t *testing.T) {
+	previousStore := configStore
+	configStore = newMemoryStore()
+	defer func() { configStore = previousStore }()
+
+	previousTTL := tokenTTL
+	tokenTTL = time.Hour
+	defer func() { tokenTTL = previousTTL }()
+
+	backend := &settingsRevocationBackend{}
+	now := time.Now()
+	list := newRevocationList()
+	list.Tokens["expired"] = now.Add(-time.Minute).Unix()
+	list.Tokens["valid"] = now.Add(time.Minute).Unix()
+	list.Accounts["old"] = now.Add(-tokenTTL - time.Minute).Unix()
+	list.Accounts["recent"] = now.Unix()
+	if err := backend.Add(list); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Prune(); err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := backend.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pruned.Tokens["valid"]; !ok || len(pruned.Tokens) != 1 {
+		t.Fatalf("Unexpected revoked tokens %v", pruned.Tokens)
+	}
+	if _, ok := pruned.Accounts["recent"]; !ok || len(pruned.Accounts) != 1 {
+		t.Fatalf("Unexpected revoked accounts %v", pruned.Accounts)
+	}
+}
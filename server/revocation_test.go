@@ -0,0 +1,53 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsRevokedReadsTheRevocationsOfTheOtherInstances(t *testing.T) {
+	backend := &memoryRevocationBackend{}
+	if err := SetRevocationBackend(backend); err != nil {
+		t.Fatal(err)
+	}
+	defer SetRevocationBackend(&memoryRevocationBackend{})
+
+	claims := map[string]interface{}{"jti": "t-1", "account_id": "alice", "iat": float64(time.Now().Unix())}
+	if isRevoked(claims) {
+		t.Fatal("The token is not revoked yet")
+	}
+
+	//another instance revokes the token in the shared store
+	list := newRevocationList()
+	list.Tokens["t-1"] = time.Now().Add(time.Hour).Unix()
+	backend.Add(list)
+	if isRevoked(claims) {
+		t.Fatal("The list is read again only after the refresh interval")
+	}
+
+	revocationMutex.Lock()
+	revocationsLoaded = time.Now().Add(-revocationRefreshInterval)
+	revocationMutex.Unlock()
+	if !isRevoked(claims) {
+		t.Fatal("Expected the token revoked by the other instance to be rejected")
+	}
+}
+
+func TestRevokeAccount(t *testing.T) {
+	if err := SetRevocationBackend(&memoryRevocationBackend{}); err != nil {
+		t.Fatal(err)
+	}
+
+	issued := map[string]interface{}{"jti": "t-2", "account_id": "bob", "iat": float64(time.Now().Add(-time.Minute).Unix())}
+	if err := RevokeAccount("bob"); err != nil {
+		t.Fatal(err)
+	}
+	if !isRevoked(issued) {
+		t.Fatal("Expected the token issued before the revocation to be rejected")
+	}
+
+	later := map[string]interface{}{"jti": "t-3", "account_id": "bob", "iat": float64(time.Now().Add(time.Minute).Unix())}
+	if isRevoked(later) {
+		t.Fatal("The token issued after the revocation is valid")
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/rancher-auth-service/providers"
+	"github.com/rancher/rancher-auth-service/util"
+)
+
+const (
+	settingsKeyEnv = "SETTINGS_ENCRYPTION_KEY"
+)
+
+//the settings holding secrets are recognized by the end of their name
+var secretSettingSuffixes = []string{".secret", ".password", ".key"}
+
+//settingsEnvelope encrypts the secret settings, they are kept in plain text when no key is configured
+var settingsEnvelope *util.Envelope
+
+func isSecretSetting(key string) bool {
+	for _, suffix := range secretSettingSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+//initSettingsEncryption loads the master key from the settingsKeyFile or the SETTINGS_ENCRYPTION_KEY env var
+//and the previous master keys still decrypting the settings not re-encrypted yet
+func initSettingsEncryption() {
+	var key []byte
+	var err error
+	switch {
+	case settingsKeyFile != "":
+		key, err = util.ReadSecretKey(settingsKeyFile)
+	case os.Getenv(settingsKeyEnv) != "":
+		key, err = util.ParseSecretKey([]byte(os.Getenv(settingsKeyEnv)))
+		if err != nil {
+			err = fmt.Errorf("The %v env var %v", settingsKeyEnv, err)
+		}
+	default:
+		log.Warn("No settings encryption key is configured, the provider secrets are stored in plain text")
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to read the settings encryption key: %v", err)
+	}
+
+	var previousKeys [][]byte
+	if previousSettingsKeyFiles != "" {
+		for _, path := range strings.Split(previousSettingsKeyFiles, ",") {
+			previousKey, err := util.ReadSecretKey(strings.TrimSpace(path))
+			if err != nil {
+				log.Fatalf("Failed to read the previous settings encryption key: %v", err)
+			}
+			previousKeys = append(previousKeys, previousKey)
+		}
+	}
+
+	if settingsEnvelope, err = util.NewEnvelope(key, previousKeys...); err != nil {
+		log.Fatalf("Failed to initialize the settings encryption: %v", err)
+	}
+}
+
+func decryptSetting(key string, value string) (string, error) {
+	if !util.IsEnveloped(value) {
+		return value, nil
+	}
+	if settingsEnvelope == nil {
+		return "", fmt.Errorf("The setting %v is encrypted but no settings encryption key is configured", key)
+	}
+	return settingsEnvelope.Decrypt(value)
+}
+
+func encryptSetting(key string, value string) (string, error) {
+	if settingsEnvelope == nil || !isSecretSetting(key) {
+		return value, nil
+	}
+	return settingsEnvelope.Encrypt(value)
+}
+
+//ReencryptSettings encrypts the secret settings of all the providers with the current master key,
+//after a key rotation and for the secrets stored before the encryption was enabled
+func ReencryptSettings() (int, error) {
+	if settingsEnvelope == nil {
+		return 0, fmt.Errorf("No settings encryption key is configured")
+	}
+	var secretSettings []string
+	for _, name := range providers.ProviderNames {
+		for _, key := range providers.GetProvider(name).GetProviderSettingList() {
+			if isSecretSetting(key) {
+				secretSettings = append(secretSettings, key)
+			}
+		}
+	}
+
+	dbSettings, err := readSettings(secretSettings)
+	if err != nil {
+		return 0, err
+	}
+	if err := updateSettings(dbSettings); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, value := range dbSettings {
+		if value != "" {
+			count++
+		}
+	}
+	return count, nil
+}
+
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"crypto/tls"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/rancher-auth-service/util"
+)
+
+const (
+	tlsPollInterval = 10 * time.Second
+)
+
+//NewTLSConfig loads the tls-cert and tls-key and starts reloading them on change or SIGHUP,
+//it returns nil when they are not set and the API is served over plain HTTP
+func NewTLSConfig() *tls.Config {
+	if tlsCertFile == "" && tlsKeyFile == "" {
+		if tlsClientCAFile != "" {
+			log.Fatal("The tls-client-ca needs the tls-cert and tls-key, halting")
+		}
+		return nil
+	}
+	if tlsCertFile == "" || tlsKeyFile == "" {
+		log.Fatal("Please provide both the tls-cert and the tls-key, halting")
+	}
+
+	var clientAuth tls.ClientAuthType
+	switch {
+	case tlsClientCAFile == "":
+		clientAuth = tls.NoClientCert
+	case tlsClientAuth == "require":
+		clientAuth = tls.RequireAndVerifyClientCert
+	case tlsClientAuth == "optional":
+		clientAuth = tls.VerifyClientCertIfGiven
+	default:
+		log.Fatalf("Unknown tls-client-auth %v, expected require or optional", tlsClientAuth)
+	}
+
+	reloader, err := util.NewCertReloader(tlsCertFile, tlsKeyFile, tlsClientCAFile, clientAuth)
+	if err != nil {
+		log.Fatalf("Failed to load the TLS certificate: %v", err)
+	}
+	go watchTLSFiles(reloader)
+
+	return reloader.TLSConfig()
+}
+
+//watchTLSFiles reloads the certificate files when they change or on SIGHUP, the current certificate is kept on errors
+func watchTLSFiles(reloader *util.CertReloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(tlsPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-hup:
+			if err := reloader.Reload(); err != nil {
+				log.Errorf("Failed to reload the TLS certificate on SIGHUP, keeping the current one: %v", err)
+				continue
+			}
+			log.Info("Reloaded the TLS certificate on SIGHUP")
+		case <-ticker.C:
+			changed, err := reloader.ReloadIfChanged()
+			if err != nil {
+				log.Errorf("Failed to reload the changed TLS certificate, keeping the current one: %v", err)
+				continue
+			}
+			if changed {
+				log.Info("Reloaded the changed TLS certificate")
+			}
+		}
+	}
+}
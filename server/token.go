@@ -0,0 +1,214 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rancher/go-rancher/client"
+	"github.com/rancher/rancher-auth-service/model"
+	"github.com/rancher/rancher-auth-service/providers"
+	"github.com/rancher/rancher-auth-service/util"
+)
+
+//createJWT signs the jwt token carrying the account and the identities of the provider token
+func createJWT(token model.Token) (string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+	//the provider access token is only readable by the service
+	sealedAccessToken, err := util.SealString(accessTokenSealer, token.AccessToken)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+
+	payload := make(map[string]interface{})
+	payload["iss"] = tokenIssuer
+	if tokenAudience != "" {
+		payload["aud"] = tokenAudience
+	}
+	payload["jti"] = jti
+	payload["iat"] = now.Unix()
+	payload["nbf"] = now.Unix()
+	payload["exp"] = now.Add(tokenTTL).Unix()
+	payload["token"] = token.Type
+	payload["account_id"] = token.ExternalAccountID
+	payload["enc_access_token"] = sealedAccessToken
+	payload["idList"] = identitiesToIDList(token.IdentityList)
+	payload["identities"] = token.IdentityList
+
+	privateKey, _, err := keyring.SigningKey()
+	if err != nil {
+		return "", err
+	}
+	return util.CreateTokenWithPayload(payload, privateKey)
+}
+
+func newTokenID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("Error generating the token id: %v", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+//VerifyToken checks the signature, validity period, issuer and audience of a jwt token issued by this service and returns its decoded claims
+func VerifyToken(tokenString string) (model.TokenInfo, error) {
+	info := model.TokenInfo{Resource: client.Resource{
+		Type: "tokeninfo",
+	}}
+	claims, err := verifyClaims(tokenString)
+	if err != nil {
+		return info, err
+	}
+	return tokenInfo(claims)
+}
+
+//tokenInfo decodes the verified claims
+func tokenInfo(claims map[string]interface{}) (model.TokenInfo, error) {
+	info := model.TokenInfo{Resource: client.Resource{
+		Type: "tokeninfo",
+	}}
+	info.Issuer, _ = claims["iss"].(string)
+	info.Audience, _ = claims["aud"].(string)
+	info.TokenID, _ = claims["jti"].(string)
+	info.AccountID, _ = claims["account_id"].(string)
+	info.TokenType, _ = claims["token"].(string)
+	info.IssuedAt = claimTime(claims, "iat")
+	info.ExpiresAt = claimTime(claims, "exp")
+
+	if idList, ok := claims["idList"].([]interface{}); ok {
+		for _, id := range idList {
+			if value, ok := id.(string); ok && value != "" {
+				info.IDList = append(info.IDList, value)
+			}
+		}
+	}
+
+	//the identities were marshalled from client.Identity, decode them back the same way
+	if identities, ok := claims["identities"]; ok && identities != nil {
+		data, err := json.Marshal(identities)
+		if err != nil {
+			return info, err
+		}
+		if err := json.Unmarshal(data, &info.Identities); err != nil {
+			return info, fmt.Errorf("Error decoding the token identities: %v", err)
+		}
+	}
+	return info, nil
+}
+
+//verifyClaims returns the claims of a valid token, the registered claims are checked here rather than relying on the jwt library
+func verifyClaims(tokenString string) (map[string]interface{}, error) {
+	if tokenString == "" {
+		return nil, fmt.Errorf("No token provided")
+	}
+	claims, err := util.ParseTokenWithKeyring(tokenString, keyring)
+	if err != nil {
+		return nil, err
+	}
+
+	if iss, _ := claims["iss"].(string); iss != tokenIssuer {
+		return nil, fmt.Errorf("Unexpected token issuer %v", claims["iss"])
+	}
+	if tokenAudience != "" {
+		if aud, _ := claims["aud"].(string); aud != tokenAudience {
+			return nil, fmt.Errorf("Unexpected token audience %v", claims["aud"])
+		}
+	}
+
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("The token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0)) {
+		return nil, fmt.Errorf("The token is expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0)) {
+		return nil, fmt.Errorf("The token is not valid yet")
+	}
+
+	if accountID, _ := claims["account_id"].(string); accountID == "" {
+		return nil, fmt.Errorf("The token has no account_id")
+	}
+	if isRevoked(claims) {
+		return nil, fmt.Errorf("The token is revoked")
+	}
+	return claims, nil
+}
+
+func claimTime(claims map[string]interface{}, name string) string {
+	value, ok := claims[name].(float64)
+	if !ok {
+		return ""
+	}
+	return time.Unix(int64(value), 0).UTC().Format(time.RFC3339)
+}
+
+//resolveAccessToken returns the provider access token a bearer token stands for. The jwt tokens of this
+//service are verified and their access token claim is decrypted, any other bearer is a provider access token
+//unless the provider access token is the user identifier
+func resolveAccessToken(bearer string) (string, error) {
+	claims, err := util.ParseUnverifiedClaims(bearer)
+	if err != nil || !isServiceToken(claims) {
+		if identifierAccessToken() {
+			return "", fmt.Errorf("The %v provider only accepts the tokens issued by the service", provider.GetName())
+		}
+		if sessionAccessToken(bearer) {
+			return "", fmt.Errorf("The %v provider only accepts its sessions from the tokens issued by the service", provider.GetName())
+		}
+		return bearer, nil
+	}
+
+	claims, err = verifyClaims(bearer)
+	if err != nil {
+		return "", err
+	}
+	sealed, ok := claims["enc_access_token"].(string)
+	if !ok {
+		return "", fmt.Errorf("The token has no encrypted access token")
+	}
+	return openAccessToken(sealed)
+}
+
+//openAccessToken decrypts the access token claim with the current key or the key derived from the private key
+func openAccessToken(sealed string) (string, error) {
+	var err error
+	for _, opener := range accessTokenOpeners {
+		var accessToken string
+		if accessToken, err = util.OpenString(opener, sealed); err == nil {
+			return accessToken, nil
+		}
+	}
+	return "", err
+}
+
+//identifierAccessToken tells if the access token of the configured provider is the user identifier, which
+//anyone can guess and is only trusted from the encrypted claim
+func identifierAccessToken() bool {
+	identifierProvider, ok := provider.(providers.IdentifierAccessTokenProvider)
+	return ok && identifierProvider.AccessTokenIsIdentifier()
+}
+
+//sessionAccessToken tells if the bearer is a session of the configured provider, whose id_token is only
+//trusted from the encrypted claim
+func sessionAccessToken(bearer string) bool {
+	sessionProvider, ok := provider.(providers.SessionAccessTokenProvider)
+	return ok && sessionProvider.IsSessionAccessToken(bearer)
+}
+
+//isServiceToken tells if the claims are those of a jwt token of this service, the tokens issued before the access
+//token was encrypted carry it in the access_token claim and are refused by verifyClaims as they have no issuer
+func isServiceToken(claims map[string]interface{}) bool {
+	if _, ok := claims["account_id"]; !ok {
+		return false
+	}
+	_, sealed := claims["enc_access_token"]
+	_, plain := claims["access_token"]
+	return sealed || plain
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/rancher/rancher-auth-service/model"
+)
+
+//ReviewToken verifies the jwt token and returns the user, account_id and idList of its claims, and the requested
+//audiences the token was issued for. When audiences are requested the token must carry one of them in its aud claim
+func ReviewToken(tokenString string, audiences []string) (model.UserInfo, []string, error) {
+	var user model.UserInfo
+	info, err := VerifyToken(tokenString)
+	if err != nil {
+		return user, nil, err
+	}
+
+	var matched []string
+	for _, audience := range audiences {
+		if audience != "" && audience == info.Audience {
+			matched = append(matched, audience)
+		}
+	}
+	if len(audiences) > 0 && len(matched) == 0 {
+		return user, nil, fmt.Errorf("The token audience %v is not one of the requested audiences %v", info.Audience, audiences)
+	}
+
+	user.Username = info.AccountID
+	user.UID = info.AccountID
+	user.Groups = info.IDList
+	return user, matched, nil
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/rancher/rancher-auth-service/model"
+	"github.com/rancher/rancher-auth-service/providers"
+	"github.com/rancher/rancher-auth-service/providers/github"
+	"github.com/rancher/rancher-auth-service/providers/local"
+	"github.com/rancher/rancher-auth-service/providers/oidc"
+	"github.com/rancher/rancher-auth-service/util"
+)
+
+const (
+	testIssuer   = "rancher-auth-service"
+	testAudience = "rancher"
+)
+
+//setupTokenSigning sets a fresh signing key, access token key and revocation list, the returned func restores them
+func setupTokenSigning(t *testing.T) func() {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	sealer, err := util.NewSealer(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previousKeyring, previousSealer, previousOpeners := keyring, accessTokenSealer, accessTokenOpeners
+	previousIssuer, previousAudience, previousTTL := tokenIssuer, tokenAudience, tokenTTL
+	previousProvider := provider
+
+	keyring = util.NewKeyring(time.Hour)
+	keyring.SetActiveKey(privateKey)
+	accessTokenSealer = sealer
+	accessTokenOpeners = []cipher.AEAD{sealer}
+	tokenIssuer = testIssuer
+	tokenAudience = testAudience
+	tokenTTL = time.Hour
+	if err := SetRevocationBackend(&memoryRevocationBackend{}); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		keyring, accessTokenSealer, accessTokenOpeners = previousKeyring, previousSealer, previousOpeners
+		tokenIssuer, tokenAudience, tokenTTL = previousIssuer, previousAudience, previousTTL
+		provider = previousProvider
+		SetRevocationBackend(&memoryRevocationBackend{})
+	}
+}
+
+//serviceClaims returns the claims createJWT sets for the access token
+func serviceClaims(t *testing.T, accessToken string) map[string]interface{} {
+	sealed, err := util.SealString(accessTokenSealer, accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":              testIssuer,
+		"aud":              testAudience,
+		"jti":              "t-1",
+		"iat":              now.Unix(),
+		"nbf":              now.Unix(),
+		"exp":              now.Add(time.Hour).Unix(),
+		"token":            "githubjwt",
+		"account_id":       "1",
+		"enc_access_token": sealed,
+		"idList":           []string{"github_user:1"},
+	}
+}
+
+func signClaims(t *testing.T, claims map[string]interface{}) string {
+	privateKey, _, err := keyring.SigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := util.CreateTokenWithPayload(claims, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerifyClaims(t *testing.T) {
+	defer setupTokenSigning(t)()
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked := newRevocationList()
+	revoked.Tokens["revoked"] = time.Now().Add(time.Hour).Unix()
+	revoked.Accounts["revoked-account"] = time.Now().Unix()
+	if err := addRevocations(revoked); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		change func(claims map[string]interface{})
+		valid  bool
+	}{
+		{"valid", func(claims map[string]interface{}) {}, true},
+		{"wrong issuer", func(claims map[string]interface{}) { claims["iss"] = "other" }, false},
+		{"no issuer", func(claims map[string]interface{}) { delete(claims, "iss") }, false},
+		{"wrong audience", func(claims map[string]interface{}) { claims["aud"] = "other" }, false},
+		{"no audience", func(claims map[string]interface{}) { delete(claims, "aud") }, false},
+		{"expired", func(claims map[string]interface{}) { claims["exp"] = time.Now().Add(-time.Minute).Unix() }, false},
+		{"no expiry", func(claims map[string]interface{}) { delete(claims, "exp") }, false},
+		{"not yet valid", func(claims map[string]interface{}) { claims["nbf"] = time.Now().Add(time.Hour).Unix() }, false},
+		{"no account", func(claims map[string]interface{}) { delete(claims, "account_id") }, false},
+		{"revoked token", func(claims map[string]interface{}) { claims["jti"] = "revoked" }, false},
+		{"revoked account", func(claims map[string]interface{}) {
+			claims["account_id"] = "revoked-account"
+			claims["iat"] = time.Now().Add(-time.Minute).Unix()
+		}, false},
+		{"account revoked before the token was issued", func(claims map[string]interface{}) {
+			claims["account_id"] = "revoked-account"
+			claims["iat"] = time.Now().Add(time.Minute).Unix()
+		}, true},
+	} {
+		claims := serviceClaims(t, "gh-token")
+		test.change(claims)
+		_, err := verifyClaims(signClaims(t, claims))
+		if valid := err == nil; valid != test.valid {
+			t.Errorf("%v: unexpected verification %v", test.name, err)
+		}
+	}
+
+	forged, err := util.CreateTokenWithPayload(serviceClaims(t, "gh-token"), otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tokenString := range []string{"", "not-a-jwt", forged} {
+		if _, err := verifyClaims(tokenString); err == nil {
+			t.Errorf("Expected the token %q to be rejected", tokenString)
+		}
+	}
+}
+
+func TestResolveAccessToken(t *testing.T) {
+	defer setupTokenSigning(t)()
+
+	sealed := func(accessToken string) string {
+		return signClaims(t, serviceClaims(t, accessToken))
+	}
+	//a token carrying the access token in the access_token claim, as issued before it was encrypted
+	plaintext := func(accessToken string, legacy bool) string {
+		claims := serviceClaims(t, accessToken)
+		delete(claims, "enc_access_token")
+		claims["access_token"] = accessToken
+		if legacy {
+			for _, claim := range []string{"iss", "aud", "jti", "iat", "nbf", "exp"} {
+				delete(claims, claim)
+			}
+		}
+		return signClaims(t, claims)
+	}
+	expired := func(accessToken string) string {
+		claims := serviceClaims(t, accessToken)
+		claims["exp"] = time.Now().Add(-time.Minute).Unix()
+		return signClaims(t, claims)
+	}
+	otherSealer, err := util.NewSealer(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSealed := serviceClaims(t, "gh-token")
+	otherSealed["enc_access_token"], _ = util.SealString(otherSealer, "gh-token")
+
+	session := `{"accessToken":"issuer-token","idToken":"id-token"}`
+	for _, test := range []struct {
+		name     string
+		provider providers.IdentityProvider
+		bearer   string
+		expected string
+	}{
+		{"provider access token", github.InitializeProvider(), "gh-token", "gh-token"},
+		{"sealed claim", github.InitializeProvider(), sealed("gh-token"), "gh-token"},
+		{"sealed with another key", github.InitializeProvider(), signClaims(t, otherSealed), ""},
+		{"expired", github.InitializeProvider(), expired("gh-token"), ""},
+		{"plaintext claim", github.InitializeProvider(), plaintext("gh-token", false), ""},
+		{"legacy plaintext token", github.InitializeProvider(), plaintext("gh-token", true), ""},
+		{"identifier bearer", local.InitializeProvider(), "alice", ""},
+		{"identifier sealed claim", local.InitializeProvider(), sealed("alice"), "alice"},
+		{"identifier plaintext claim", local.InitializeProvider(), plaintext("alice", false), ""},
+		{"issuer access token", oidc.InitializeProvider(), "issuer-token", "issuer-token"},
+		{"session bearer", oidc.InitializeProvider(), session, ""},
+		{"session sealed claim", oidc.InitializeProvider(), sealed(session), session},
+	} {
+		provider = test.provider
+		accessToken, err := resolveAccessToken(test.bearer)
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf("%v: expected the bearer to be rejected, got %q", test.name, accessToken)
+			}
+			continue
+		}
+		if err != nil || accessToken != test.expected {
+			t.Errorf("%v: unexpected access token %q, %v", test.name, accessToken, err)
+		}
+	}
+}
+
+func TestCreateJWTIsVerified(t *testing.T) {
+	defer setupTokenSigning(t)()
+	provider = github.InitializeProvider()
+
+	tokenString, err := createJWT(model.Token{Type: "githubjwt", ExternalAccountID: "1", AccessToken: "gh-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := VerifyToken(tokenString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AccountID != "1" || info.Issuer != testIssuer || info.Audience != testAudience || info.TokenID == "" {
+		t.Fatalf("Unexpected token info %v", info)
+	}
+	if accessToken, err := resolveAccessToken(tokenString); err != nil || accessToken != "gh-token" {
+		t.Fatalf("Unexpected access token %q, %v", accessToken, err)
+	}
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rancher/rancher-auth-service/model"
+	"github.com/rancher/rancher-auth-service/server"
+)
+
+//Healthz is a handler for GET /healthz, it answers as long as the process serves requests
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{"status": model.CheckOK})
+}
+
+//Readyz is a handler for GET /readyz, it answers 503 when one of the readiness checks failed
+func Readyz(w http.ResponseWriter, r *http.Request) {
+	readiness := server.CheckReadiness()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if readiness.Status != model.CheckOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(readiness)
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/rancher-auth-service/server"
+)
+
+//Introspect is a handler for POST /introspect, the RFC 7662 token introspection for the clients of the introspectionClientsFile
+func Introspect(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		returnOAuthError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	//client_secret_basic, falling back to client_secret_post
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+	if !server.AuthenticateIntrospectionClient(clientID, clientSecret) {
+		log.Debugf("Introspect rejected the client %v", clientID)
+		w.Header().Set("WWW-Authenticate", `Basic realm="rancher-auth-service"`)
+		returnOAuthError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		returnOAuthError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	//the client_id is the client the token was issued to, which the jwt tokens do not record, not the caller
+	resp := server.Introspect(token)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func returnOAuthError(w http.ResponseWriter, httpStatus int, oauthError string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(map[string]string{"error": oauthError})
+}
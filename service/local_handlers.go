@@ -0,0 +1,194 @@
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/rancher/go-rancher/api"
+	"github.com/rancher/rancher-auth-service/model"
+	"github.com/rancher/rancher-auth-service/providers/local"
+)
+
+//returnLocalError maps the errors of the local user database to the http status
+func returnLocalError(w http.ResponseWriter, r *http.Request, err error) {
+	switch err.(type) {
+	case *local.InvalidError:
+		ReturnHTTPError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	switch err {
+	case local.ErrNotFound:
+		ReturnHTTPError(w, r, http.StatusNotFound, err.Error())
+	case local.ErrExists:
+		ReturnHTTPError(w, r, http.StatusConflict, err.Error())
+	default:
+		log.Errorf("Local user database request failed with error: %v", err)
+		ReturnHTTPError(w, r, http.StatusInternalServerError, "Internal Server Error")
+	}
+}
+
+func readLocalRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	bytes, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(bytes, v)
+	}
+	if err != nil {
+		log.Errorf("Reading the local user database request failed with error: %v", err)
+		ReturnHTTPError(w, r, http.StatusBadRequest, "Bad Request, Please check the request content")
+		return false
+	}
+	return true
+}
+
+//ListLocalUsers is a handler for GET /local/users and lists the local users, it requires an admin token
+func ListLocalUsers(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	users, err := local.ListUsers()
+	if err != nil {
+		returnLocalError(w, r, err)
+		return
+	}
+	resp := model.LocalUserCollection{}
+	resp.Data = users
+	api.GetApiContext(r).Write(&resp)
+}
+
+//GetLocalUser is a handler for GET /local/users/{id} and returns the local user, it requires an admin token
+func GetLocalUser(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	user, err := local.GetUser(mux.Vars(r)["id"])
+	if err != nil {
+		returnLocalError(w, r, err)
+		return
+	}
+	api.GetApiContext(r).Write(&user)
+}
+
+//CreateLocalUser is a handler for POST /local/users and adds a local user, it requires an admin token
+func CreateLocalUser(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	var user model.LocalUser
+	if !readLocalRequest(w, r, &user) {
+		return
+	}
+	user, err := local.CreateUser(user)
+	if err != nil {
+		returnLocalError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	api.GetApiContext(r).Write(&user)
+}
+
+//UpdateLocalUser is a handler for PUT /local/users/{id} and changes the name or password of the local user, it requires an admin token
+func UpdateLocalUser(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	var user model.LocalUser
+	if !readLocalRequest(w, r, &user) {
+		return
+	}
+	user, err := local.UpdateUser(mux.Vars(r)["id"], user)
+	if err != nil {
+		returnLocalError(w, r, err)
+		return
+	}
+	api.GetApiContext(r).Write(&user)
+}
+
+//DeleteLocalUser is a handler for DELETE /local/users/{id} and removes the local user, it requires an admin token
+func DeleteLocalUser(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if err := local.DeleteUser(mux.Vars(r)["id"]); err != nil {
+		returnLocalError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//ListLocalGroups is a handler for GET /local/groups and lists the local groups, it requires an admin token
+func ListLocalGroups(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	groups, err := local.ListGroups()
+	if err != nil {
+		returnLocalError(w, r, err)
+		return
+	}
+	resp := model.LocalGroupCollection{}
+	resp.Data = groups
+	api.GetApiContext(r).Write(&resp)
+}
+
+//GetLocalGroup is a handler for GET /local/groups/{id} and returns the local group, it requires an admin token
+func GetLocalGroup(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	group, err := local.GetGroup(mux.Vars(r)["id"])
+	if err != nil {
+		returnLocalError(w, r, err)
+		return
+	}
+	api.GetApiContext(r).Write(&group)
+}
+
+//CreateLocalGroup is a handler for POST /local/groups and adds a local group, it requires an admin token
+func CreateLocalGroup(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	var group model.LocalGroup
+	if !readLocalRequest(w, r, &group) {
+		return
+	}
+	group, err := local.CreateGroup(group)
+	if err != nil {
+		returnLocalError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	api.GetApiContext(r).Write(&group)
+}
+
+//UpdateLocalGroup is a handler for PUT /local/groups/{id} and replaces the members of the local group, it requires an admin token
+func UpdateLocalGroup(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	var group model.LocalGroup
+	if !readLocalRequest(w, r, &group) {
+		return
+	}
+	group, err := local.UpdateGroup(mux.Vars(r)["id"], group)
+	if err != nil {
+		returnLocalError(w, r, err)
+		return
+	}
+	api.GetApiContext(r).Write(&group)
+}
+
+//DeleteLocalGroup is a handler for DELETE /local/groups/{id} and removes the local group, it requires an admin token
+func DeleteLocalGroup(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if err := local.DeleteGroup(mux.Vars(r)["id"]); err != nil {
+		returnLocalError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
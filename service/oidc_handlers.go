@@ -0,0 +1,20 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/rancher-auth-service/server"
+)
+
+//OIDCLogin is a handler for GET /oidc/login and redirects the browser to the authorization endpoint of the issuer
+func OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	loginURL, err := server.GetOIDCLoginURL(r.URL.Query().Get("state"))
+	if err != nil {
+		log.Errorf("OIDCLogin failed with error: %v", err)
+		ReturnHTTPError(w, r, http.StatusInternalServerError, fmt.Sprintf("Error starting the OIDC login: %v", err))
+		return
+	}
+	http.Redirect(w, r, loginURL, http.StatusFound)
+}
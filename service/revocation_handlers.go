@@ -0,0 +1,67 @@
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/rancher-auth-service/server"
+)
+
+//bearerToken returns the token of the "Bearer <token>" Authorization header
+func bearerToken(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return ""
+	}
+	return strings.TrimPrefix(authHeader, "Bearer ")
+}
+
+//requireAdmin answers the request with 401 or 403 and returns false unless the Bearer token belongs to an admin
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	admin, err := server.IsAdmin(bearerToken(r))
+	if err != nil {
+		log.Debugf("Admin request rejected the token: %v", err)
+		ReturnHTTPError(w, r, http.StatusUnauthorized, "Unauthorized, please provide a valid token")
+		return false
+	}
+	if !admin {
+		ReturnHTTPError(w, r, http.StatusForbidden, "Forbidden, the token does not belong to an admin")
+		return false
+	}
+	return true
+}
+
+//Logout is a handler for POST /logout and revokes the jwt token passed as the Bearer token
+func Logout(w http.ResponseWriter, r *http.Request) {
+	err := server.Logout(bearerToken(r))
+	if err != nil {
+		log.Debugf("Logout failed with error: %v", err)
+		ReturnHTTPError(w, r, http.StatusUnauthorized, "Unauthorized, please provide a valid token")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//RevokeTokens is a handler for POST /revoke and revokes all the tokens issued to the accountId, it requires an admin token
+func RevokeTokens(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	var t map[string]string
+	bytes, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(bytes, &t)
+	}
+	if err != nil || t["accountId"] == "" {
+		ReturnHTTPError(w, r, http.StatusBadRequest, "Bad Request, Please provide the accountId")
+		return
+	}
+	if err := server.RevokeAccount(t["accountId"]); err != nil {
+		ReturnHTTPError(w, r, http.StatusInternalServerError, "Failed to revoke the tokens")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
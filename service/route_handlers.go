@@ -8,11 +8,21 @@ import (
 	"github.com/rancher/go-rancher/client"
 	"github.com/rancher/rancher-auth-service/server"
 	"github.com/rancher/rancher-auth-service/model"
+	"github.com/rancher/rancher-auth-service/util"
 	"io/ioutil"
 	"net/http"
 	"strings"
 )
 
+//returnTokenError answers a failed token request, with 403 when the access mode denied the user
+func returnTokenError(w http.ResponseWriter, r *http.Request, httpStatus int, err error) {
+	if _, ok := err.(*server.AccessDeniedError); ok {
+		ReturnHTTPError(w, r, http.StatusForbidden, err.Error())
+		return
+	}
+	ReturnHTTPError(w, r, httpStatus, fmt.Sprintf("Error getting the token: %v", err))
+}
+
 //CreateToken is a handler for route /token and returns the jwt token after authenticating the user
 func CreateToken(w http.ResponseWriter, r *http.Request) {
 	bytes, err := ioutil.ReadAll(r.Body)
@@ -25,20 +35,18 @@ func CreateToken(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Errorf("unmarshal failed with error: %v", err)
 	}
-	log.Infof("map %v", t)
 
+	//the code can be a username:password and the access token a credential, they are never logged
 	securityCode := t["code"]
 	accessToken := t["accessToken"]
-
-	log.Infof("securityCode %s", securityCode)
-	log.Infof("acessToken %s", accessToken)
+	log.Debugf("CreateToken called with a code: %v, with an access token: %v", securityCode != "", accessToken != "")
 
 	if securityCode != "" {
 		//getToken
 		token, err := server.CreateToken(securityCode)
 		if err != nil {
 			log.Errorf("GetToken failed with error: %v", err)
-			ReturnHTTPError(w, r, http.StatusInternalServerError, fmt.Sprintf("Error getting the token: %v", err))
+			returnTokenError(w, r, http.StatusInternalServerError, err)
 		} else {
 			json.NewEncoder(w).Encode(token)
 		}
@@ -47,7 +55,7 @@ func CreateToken(w http.ResponseWriter, r *http.Request) {
 		token, err := server.RefreshToken(accessToken)
 		if err != nil {
 			log.Errorf("GetToken failed with error: %v", err)
-			ReturnHTTPError(w, r, http.StatusInternalServerError, fmt.Sprintf("Error getting the token: %v", err))
+			returnTokenError(w, r, http.StatusInternalServerError, err)
 		} else {
 			json.NewEncoder(w).Encode(token)
 		}
@@ -56,6 +64,42 @@ func CreateToken(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+//VerifyToken is a handler for route /token/verify and returns the identities of a valid jwt token issued by the service
+func VerifyToken(w http.ResponseWriter, r *http.Request) {
+	apiContext := api.GetApiContext(r)
+	var t map[string]string
+
+	bytes, err := ioutil.ReadAll(r.Body)
+	if err == nil && len(bytes) > 0 {
+		if err := json.Unmarshal(bytes, &t); err != nil {
+			log.Debugf("VerifyToken unmarshal failed with error: %v", err)
+			ReturnHTTPError(w, r, http.StatusBadRequest, "Bad Request, Please check the request content")
+			return
+		}
+	}
+
+	//the token can be passed in the body or as the Bearer token
+	tokenString := t["token"]
+	if tokenString == "" {
+		tokenString = bearerToken(r)
+	}
+
+	info, err := server.VerifyToken(tokenString)
+	if err != nil {
+		log.Debugf("VerifyToken rejected the token: %v", err)
+		ReturnHTTPError(w, r, http.StatusUnauthorized, "Unauthorized, please provide a valid token")
+		return
+	}
+	apiContext.Write(&info)
+}
+
+//GetJWKS is a handler for route /.well-known/jwks.json and returns the public keys verifying the jwt tokens
+func GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "max-age=300")
+	json.NewEncoder(w).Encode(server.GetJWKS())
+}
+
 //GetIdentities is a handler for route /me/identities and returns group memberships and details of the user
 func GetIdentities(w http.ResponseWriter, r *http.Request) {
 	apiContext := api.GetApiContext(r)
@@ -142,12 +186,16 @@ func SearchIdentities(w http.ResponseWriter, r *http.Request) {
 }
 
 
-//UpdateConfig is a handler for POST /authconfig, loads the provider with the config and saves the config back to Cattle database
+//UpdateConfig is a handler for POST /authconfig, loads the provider with the config and saves the config back to Cattle database, it requires an admin token
 func UpdateConfig(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
 	bytes, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		log.Errorf("UpdateConfig failed with error: %v", err)
 		ReturnHTTPError(w, r, http.StatusBadRequest, "Bad Request, Please check the request content")
+		return
 	}
 	var authConfig model.AuthConfig
 
@@ -155,12 +203,16 @@ func UpdateConfig(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Errorf("UpdateConfig unmarshal failed with error: %v", err)
 		ReturnHTTPError(w, r, http.StatusBadRequest, "Bad Request, Please check the request content")
+		return
 	}
-	log.Infof("authConfig %v", authConfig)
+	loggedConfig := authConfig
+	util.RedactSecrets(&loggedConfig)
+	log.Infof("authConfig %v", loggedConfig)
 	
 	if authConfig.Provider == "" {
 		log.Errorf("UpdateConfig: Provider is a required field")
 		ReturnHTTPError(w, r, http.StatusBadRequest, "Bad Request, Please check the request content, Provider is a required field")
+		return
 	}
 	
 	
@@ -168,28 +220,19 @@ func UpdateConfig(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Errorf("UpdateConfig failed with error: %v", err)
 		ReturnHTTPError(w, r, http.StatusBadRequest, "Bad Request, Please check the request content")
+		return
 	}
 }
 
-//GetConfig is a handler for GET /authconfig, lists the provider config
+//GetConfig is a handler for GET /authconfig, lists the provider config with the secrets masked, it requires an admin token
 func GetConfig(w http.ResponseWriter, r *http.Request) {
-	//apiContext := api.GetApiContext(r)
-	authHeader := r.Header.Get("Authorization")
-	var accessToken string
-	// header value format will be "Bearer <token>"
-	if authHeader != "" {
-		if !strings.HasPrefix(authHeader, "Bearer ") {
-			log.Debug("GetMyIdentities Failed to find Bearer token %v", authHeader)
-			ReturnHTTPError(w, r, http.StatusUnauthorized, "Unauthorized, please provide a valid token")
-		}
-		accessToken = strings.TrimPrefix(authHeader, "Bearer ")
+	if !requireAdmin(w, r) {
+		return
 	}
-	log.Debugf("token is this  %s", accessToken)
-	
-	config, err := server.GetConfig(accessToken)
-	log.Debugf("config  %v", config)
+
+	config, err := server.GetConfig(bearerToken(r))
 	if err == nil {
-		//apiContext.Write(&config)
+		util.RedactSecrets(&config)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(config)
 	} else {
@@ -199,8 +242,11 @@ func GetConfig(w http.ResponseWriter, r *http.Request) {
 	}			
 }
 
-//Reload is a handler for POST /reloadconfig, reloads the config from Cattle database and initializes the provider 
+//Reload is a handler for POST /reloadconfig, reloads the config from Cattle database and initializes the provider, it requires an admin token
 func Reload(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
 	err := server.Reload()
 	if err != nil {
 		//failed to reload the config from DB
@@ -7,6 +7,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/rancher/go-rancher/api"
 	"github.com/rancher/go-rancher/client"
+	"github.com/rancher/rancher-auth-service/metrics"
 	"github.com/rancher/rancher-auth-service/model"
 )
 
@@ -23,6 +24,36 @@ type Routes []Route
 
 var schemas *client.Schemas
 
+//routes are the application routes, their requests are recorded in the metrics by pattern
+var routes = Routes{
+	{"UpdateConfig", "POST", "/v1-rancher-auth/config", UpdateConfig},
+	{"GetConfig", "GET", "/v1-rancher-auth/config", GetConfig},
+	{"Reload", "POST", "/v1-rancher-auth/reload", Reload},
+	{"CreateToken", "POST", "/v1-rancher-auth/token", CreateToken},
+	{"GetJWKS", "GET", "/v1-rancher-auth/.well-known/jwks.json", GetJWKS},
+	{"VerifyToken", "POST", "/v1-rancher-auth/token/verify", VerifyToken},
+	{"Logout", "POST", "/v1-rancher-auth/logout", Logout},
+	{"RevokeTokens", "POST", "/v1-rancher-auth/revoke", RevokeTokens},
+	{"Introspect", "POST", "/v1-rancher-auth/introspect", Introspect},
+	{"TokenReview", "POST", "/v1-rancher-auth/tokenreview", TokenReview},
+	{"GetIdentities", "GET", "/v1-rancher-auth/me/identities", GetIdentities},
+	{"SearchIdentities", "GET", "/v1-rancher-auth/identities", SearchIdentities},
+	{"GetSamlMetadata", "GET", "/v1-rancher-auth/saml/metadata", GetSamlMetadata},
+	{"SamlLogin", "GET", "/v1-rancher-auth/saml/login", SamlLogin},
+	{"OIDCLogin", "GET", "/v1-rancher-auth/oidc/login", OIDCLogin},
+	{"ListLocalUsers", "GET", "/v1-rancher-auth/local/users", ListLocalUsers},
+	{"CreateLocalUser", "POST", "/v1-rancher-auth/local/users", CreateLocalUser},
+	{"GetLocalUser", "GET", "/v1-rancher-auth/local/users/{id}", GetLocalUser},
+	{"UpdateLocalUser", "PUT", "/v1-rancher-auth/local/users/{id}", UpdateLocalUser},
+	{"DeleteLocalUser", "DELETE", "/v1-rancher-auth/local/users/{id}", DeleteLocalUser},
+	{"ListLocalGroups", "GET", "/v1-rancher-auth/local/groups", ListLocalGroups},
+	{"CreateLocalGroup", "POST", "/v1-rancher-auth/local/groups", CreateLocalGroup},
+	{"GetLocalGroup", "GET", "/v1-rancher-auth/local/groups/{id}", GetLocalGroup},
+	{"UpdateLocalGroup", "PUT", "/v1-rancher-auth/local/groups/{id}", UpdateLocalGroup},
+	{"DeleteLocalGroup", "DELETE", "/v1-rancher-auth/local/groups/{id}", DeleteLocalGroup},
+	{"SamlACS", "POST", "/v1-rancher-auth/saml/acs", SamlACS},
+}
+
 //NewRouter creates and configures a mux router
 func NewRouter() *mux.Router {
 	schemas = &client.Schemas{}
@@ -44,6 +75,54 @@ func NewRouter() *mux.Router {
 	githubconfig := schemas.AddType("githubconfig", model.GithubConfig{})
 	githubconfig.CollectionMethods = []string{}
 
+	// GitlabConfig
+	gitlabconfig := schemas.AddType("gitlabconfig", model.GitlabConfig{})
+	gitlabconfig.CollectionMethods = []string{}
+
+	// BitbucketConfig
+	bitbucketconfig := schemas.AddType("bitbucketconfig", model.BitbucketConfig{})
+	bitbucketconfig.CollectionMethods = []string{}
+
+	// LdapConfig
+	ldapconfig := schemas.AddType("ldapconfig", model.LdapConfig{})
+	ldapconfig.CollectionMethods = []string{}
+
+	// OpenLdapConfig
+	openldapconfig := schemas.AddType("openldapconfig", model.OpenLdapConfig{})
+	openldapconfig.CollectionMethods = []string{}
+
+	// OIDCConfig
+	oidcconfig := schemas.AddType("oidcconfig", model.OIDCConfig{})
+	oidcconfig.CollectionMethods = []string{}
+
+	// SamlConfig
+	samlconfig := schemas.AddType("samlconfig", model.SamlConfig{})
+	samlconfig.CollectionMethods = []string{}
+
+	// AzureADConfig
+	azureadconfig := schemas.AddType("azureadconfig", model.AzureADConfig{})
+	azureadconfig.CollectionMethods = []string{}
+
+	// FileConfig
+	fileconfig := schemas.AddType("fileconfig", model.FileConfig{})
+	fileconfig.CollectionMethods = []string{}
+
+	// LocalUser
+	localuser := schemas.AddType("localuser", model.LocalUser{})
+	localuser.CollectionMethods = []string{"GET", "POST"}
+	localuser.ResourceMethods = []string{"GET", "PUT", "DELETE"}
+	localuser.PluralName = "local/users"
+
+	// LocalGroup
+	localgroup := schemas.AddType("localgroup", model.LocalGroup{})
+	localgroup.CollectionMethods = []string{"GET", "POST"}
+	localgroup.ResourceMethods = []string{"GET", "PUT", "DELETE"}
+	localgroup.PluralName = "local/groups"
+
+	// TokenInfo
+	tokeninfo := schemas.AddType("tokeninfo", model.TokenInfo{})
+	tokeninfo.CollectionMethods = []string{}
+
 	// AuthConfig
 	authconfig := schemas.AddType("config", model.AuthConfig{})
 	authconfig.CollectionMethods = []string{"GET"}
@@ -64,14 +143,16 @@ func NewRouter() *mux.Router {
 	router.Methods("GET").Path("/v1-rancher-auth/schemas/{id}").Handler(api.SchemaHandler(schemas))
 	router.Methods("GET").Path("/v1-rancher-auth").Handler(api.VersionHandler(schemas, "v1-rancher-auth"))
 
+	// Health routes
+	router.Methods("GET").Path("/healthz").HandlerFunc(Healthz)
+	router.Methods("GET").Path("/readyz").HandlerFunc(Readyz)
+
 	// Application routes
-	router.Methods("POST").Path("/v1-rancher-auth/config").Handler(api.ApiHandler(schemas, http.HandlerFunc(UpdateConfig)))
-	router.Methods("GET").Path("/v1-rancher-auth/config").Handler(api.ApiHandler(schemas, http.HandlerFunc(GetConfig)))
-	router.Methods("POST").Path("/v1-rancher-auth/reload").Handler(api.ApiHandler(schemas, http.HandlerFunc(Reload)))
-	router.Methods("POST").Path("/v1-rancher-auth/token").Handler(api.ApiHandler(schemas, http.HandlerFunc(CreateToken)))
-	router.Methods("GET").Path("/v1-rancher-auth/me/identities").Handler(api.ApiHandler(schemas, http.HandlerFunc(GetIdentities)))
-	router.Methods("GET").Path("/v1-rancher-auth/identities").Handler(api.ApiHandler(schemas, http.HandlerFunc(SearchIdentities)))
+	for _, route := range routes {
+		router.Methods(route.Method).Path(route.Pattern).Name(route.Name).Handler(metrics.InstrumentRoute(route.Pattern, api.ApiHandler(schemas, route.HandlerFunc)))
+	}
 
+	router.Methods("GET").Path("/metrics").Handler(metrics.Handler())
 
 	return router
 }
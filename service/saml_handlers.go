@@ -0,0 +1,73 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/rancher-auth-service/server"
+)
+
+//GetSamlMetadata is a handler for GET /saml/metadata and returns the SAML service provider metadata
+func GetSamlMetadata(w http.ResponseWriter, r *http.Request) {
+	metadata, err := server.GetSamlMetadata()
+	if err != nil {
+		log.Errorf("GetSamlMetadata failed with error: %v", err)
+		ReturnHTTPError(w, r, http.StatusNotFound, fmt.Sprintf("Error getting the SAML metadata: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.Write(metadata)
+}
+
+//SamlLogin is a handler for GET /saml/login and redirects the browser to the IdP with a new AuthnRequest
+func SamlLogin(w http.ResponseWriter, r *http.Request) {
+	loginURL, err := server.GetSamlLoginURL(r.URL.Query().Get("redirectTo"))
+	if err != nil {
+		log.Errorf("SamlLogin failed with error: %v", err)
+		ReturnHTTPError(w, r, http.StatusInternalServerError, fmt.Sprintf("Error starting the SAML login: %v", err))
+		return
+	}
+	http.Redirect(w, r, loginURL, http.StatusFound)
+}
+
+//SamlACS is a handler for POST /saml/acs, validates the signed assertion posted by the IdP and returns the jwt token
+func SamlACS(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		log.Errorf("SamlACS failed to parse the form: %v", err)
+		ReturnHTTPError(w, r, http.StatusBadRequest, "Bad Request, Please check the request content")
+		return
+	}
+	samlResponse := r.PostForm.Get("SAMLResponse")
+	if samlResponse == "" {
+		ReturnHTTPError(w, r, http.StatusBadRequest, "Bad Request, SAMLResponse is missing")
+		return
+	}
+
+	token, redirectTo, err := server.CreateSamlToken(samlResponse, r.PostForm.Get("RelayState"))
+	if err != nil {
+		log.Errorf("SamlACS failed with error: %v", err)
+		returnTokenError(w, r, http.StatusUnauthorized, err)
+		return
+	}
+
+	if isLocalRedirect(redirectTo) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     "token",
+			Value:    token,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+		})
+		http.Redirect(w, r, redirectTo, http.StatusFound)
+		return
+	}
+	json.NewEncoder(w).Encode(token)
+}
+
+//isLocalRedirect only allows relative paths on this host so the ACS cannot be used as an open redirect
+func isLocalRedirect(redirectTo string) bool {
+	return strings.HasPrefix(redirectTo, "/") && !strings.HasPrefix(redirectTo, "//") && !strings.HasPrefix(redirectTo, "/\\")
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rancher/rancher-auth-service/model"
+	"github.com/rancher/rancher-auth-service/server"
+)
+
+const (
+	tokenReviewAPIVersion = "authentication.k8s.io/v1"
+	tokenReviewKind       = "TokenReview"
+)
+
+//TokenReview is a handler for POST /tokenreview, the Kubernetes authentication webhook verifying the jwt tokens of this service
+func TokenReview(w http.ResponseWriter, r *http.Request) {
+	bytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Errorf("TokenReview failed with error: %v", err)
+		ReturnHTTPError(w, r, http.StatusBadRequest, "Bad Request, Please check the request content")
+		return
+	}
+	var review model.TokenReview
+	if err := json.Unmarshal(bytes, &review); err != nil {
+		log.Errorf("TokenReview unmarshal failed with error: %v", err)
+		ReturnHTTPError(w, r, http.StatusBadRequest, "Bad Request, Please check the request content")
+		return
+	}
+	if review.Kind != "" && review.Kind != tokenReviewKind {
+		ReturnHTTPError(w, r, http.StatusBadRequest, "Bad Request, expected a TokenReview")
+		return
+	}
+
+	//answer in the version the API server asked with
+	if review.APIVersion == "" {
+		review.APIVersion = tokenReviewAPIVersion
+	}
+	review.Kind = tokenReviewKind
+	review.Status = model.TokenReviewStatus{}
+
+	user, audiences, err := server.ReviewToken(review.Spec.Token, review.Spec.Audiences)
+	if err != nil {
+		log.Debugf("TokenReview rejected the token: %v", err)
+		review.Status.Error = "Invalid token"
+	} else {
+		review.Status.Authenticated = true
+		review.Status.User = &user
+		review.Status.Audiences = audiences
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(review)
+}
@@ -0,0 +1,93 @@
+package util
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+//envelopePrefix marks the values encrypted by an Envelope, the other values are plain text
+const envelopePrefix = "enc:v1:"
+
+//Envelope encrypts values with a fresh data key each, the data key is stored along encrypted with the master key.
+//The previous master keys are only used to decrypt, so the values can be re-encrypted after a key rotation
+type Envelope struct {
+	activeID string
+	keys     map[string]cipher.AEAD
+}
+
+//NewEnvelope returns the envelope encrypting with the active key and decrypting with any of the keys
+func NewEnvelope(activeKey []byte, previousKeys ...[]byte) (*Envelope, error) {
+	envelope := &Envelope{keys: make(map[string]cipher.AEAD)}
+	for i, key := range append([][]byte{activeKey}, previousKeys...) {
+		sealer, err := NewSealer(key)
+		if err != nil {
+			return nil, err
+		}
+		id := masterKeyID(key)
+		if i == 0 {
+			envelope.activeID = id
+		}
+		envelope.keys[id] = sealer
+	}
+	return envelope, nil
+}
+
+func masterKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+//IsEnveloped tells if the value was encrypted by an Envelope
+func IsEnveloped(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix)
+}
+
+//Encrypt returns enc:v1:<master key id>:<encrypted data key>:<encrypted value>
+func (e *Envelope) Encrypt(value string) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	dataSealer, err := NewSealer(dataKey)
+	if err != nil {
+		return "", err
+	}
+	sealedValue, err := SealString(dataSealer, value)
+	if err != nil {
+		return "", err
+	}
+	sealedKey, err := SealString(e.keys[e.activeID], string(dataKey))
+	if err != nil {
+		return "", err
+	}
+	return envelopePrefix + e.activeID + ":" + sealedKey + ":" + sealedValue, nil
+}
+
+//Decrypt returns the value of an encrypted value, plain text values are returned as they are
+func (e *Envelope) Decrypt(value string) (string, error) {
+	if !IsEnveloped(value) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, envelopePrefix), ":")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("Malformed encrypted value")
+	}
+	masterSealer, ok := e.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("The value is encrypted with the unknown master key %v", parts[0])
+	}
+	dataKey, err := OpenString(masterSealer, parts[1])
+	if err != nil {
+		return "", err
+	}
+	dataSealer, err := NewSealer([]byte(dataKey))
+	if err != nil {
+		return "", err
+	}
+	return OpenString(dataSealer, parts[2])
+}
@@ -0,0 +1,107 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvelopeEncryptAndDecrypt(t *testing.T) {
+	envelope, err := NewEnvelope(newTestKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := envelope.Encrypt("client secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEnveloped(encrypted) || strings.Contains(encrypted, "client secret") {
+		t.Fatalf("Unexpected encrypted value %v", encrypted)
+	}
+	other, err := envelope.Encrypt("client secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == encrypted {
+		t.Fatal("Expected a fresh data key for each value")
+	}
+
+	value, err := envelope.Decrypt(encrypted)
+	if err != nil || value != "client secret" {
+		t.Fatalf("Unexpected decrypted value %q, %v", value, err)
+	}
+}
+
+func TestEnvelopeDecryptsPlainText(t *testing.T) {
+	envelope, err := NewEnvelope(newTestKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsEnveloped("client secret") {
+		t.Fatal("The plain text value is not enveloped")
+	}
+	value, err := envelope.Decrypt("client secret")
+	if err != nil || value != "client secret" {
+		t.Fatalf("Unexpected plain text value %q, %v", value, err)
+	}
+}
+
+func TestEnvelopeKeyRotation(t *testing.T) {
+	oldKey := newTestKey(t)
+	oldEnvelope, err := NewEnvelope(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := oldEnvelope.Encrypt("client secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newKey := newTestKey(t)
+	rotated, err := NewEnvelope(newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := rotated.Decrypt(encrypted)
+	if err != nil || value != "client secret" {
+		t.Fatalf("Expected the previous key to decrypt, got %q, %v", value, err)
+	}
+	reencrypted, err := rotated.Encrypt(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(reencrypted, envelopePrefix+masterKeyID(newKey)+":") {
+		t.Fatalf("Expected the value to be encrypted with the active key, got %v", reencrypted)
+	}
+
+	withoutOldKey, err := NewEnvelope(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := withoutOldKey.Decrypt(encrypted); err == nil {
+		t.Fatal("Expected the value of the unknown master key to be rejected")
+	}
+}
+
+func TestEnvelopeRejectsMalformedValues(t *testing.T) {
+	envelope, err := NewEnvelope(newTestKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := envelope.Encrypt("client secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(strings.TrimPrefix(encrypted, envelopePrefix), ":")
+	for _, value := range []string{
+		envelopePrefix + "abc",
+		envelopePrefix + parts[0] + ":" + parts[1] + ":" + parts[1],
+		envelopePrefix + parts[0] + ":" + parts[2] + ":" + parts[2],
+	} {
+		if _, err := envelope.Decrypt(value); err == nil {
+			t.Errorf("Expected %v to be rejected", value)
+		}
+	}
+	if _, err := NewEnvelope([]byte("short")); err == nil {
+		t.Error("Expected the short master key to be rejected")
+	}
+}
@@ -0,0 +1,147 @@
+package util
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+//SigningKey is a RSA key of the keyring, identified by its kid
+type SigningKey struct {
+	ID        string
+	PublicKey *rsa.PublicKey
+	//ExpiresAt is set once the key is retired, it is accepted for verification until then
+	ExpiresAt time.Time
+}
+
+//Keyring holds the active RSA key signing the tokens and the retired keys still accepted for verification
+type Keyring struct {
+	mutex       sync.RWMutex
+	gracePeriod time.Duration
+	privateKey  *rsa.PrivateKey
+	active      *SigningKey
+	retired     []*SigningKey
+}
+
+//NewKeyring returns an empty keyring keeping the retired keys for the grace period
+func NewKeyring(gracePeriod time.Duration) *Keyring {
+	return &Keyring{gracePeriod: gracePeriod}
+}
+
+//EncodeRSAPublicKey returns the base64url encoded modulus and exponent of the public key, the n and e of its JWK
+func EncodeRSAPublicKey(publicKey *rsa.PublicKey) (string, string) {
+	n := base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+	e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	return n, e
+}
+
+//KeyID returns the RFC 7638 thumbprint of the public key, used as the kid
+func KeyID(publicKey *rsa.PublicKey) string {
+	n, e := EncodeRSAPublicKey(publicKey)
+	//the members are in lexicographic order as the thumbprint requires
+	thumbprint, _ := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{E: e, Kty: "RSA", N: n})
+	sum := sha256.Sum256(thumbprint)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+//SetActiveKey makes the private key the signing key, the previous active key is retired for the grace period
+func (k *Keyring) SetActiveKey(privateKey *rsa.PrivateKey) string {
+	kid := KeyID(&privateKey.PublicKey)
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if k.active != nil && k.active.ID == kid {
+		return kid
+	}
+	if k.active != nil {
+		k.active.ExpiresAt = time.Now().Add(k.gracePeriod)
+		k.retired = append(k.retired, k.active)
+	}
+	k.privateKey = privateKey
+	k.active = &SigningKey{ID: kid, PublicKey: &privateKey.PublicKey}
+	k.pruneRetired()
+	return kid
+}
+
+//AddRetiredKey accepts the public key for verification for the grace period, e.g. the key used before a restart
+func (k *Keyring) AddRetiredKey(publicKey *rsa.PublicKey) string {
+	kid := KeyID(publicKey)
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if k.active != nil && k.active.ID == kid {
+		return kid
+	}
+	k.retired = append(k.retired, &SigningKey{
+		ID:        kid,
+		PublicKey: publicKey,
+		ExpiresAt: time.Now().Add(k.gracePeriod),
+	})
+	k.pruneRetired()
+	return kid
+}
+
+//pruneRetired drops the retired keys past their grace period, the caller holds the lock
+func (k *Keyring) pruneRetired() {
+	now := time.Now()
+	var retired []*SigningKey
+	for _, key := range k.retired {
+		if now.Before(key.ExpiresAt) {
+			retired = append(retired, key)
+		}
+	}
+	k.retired = retired
+}
+
+//SigningKey returns the active private key and its kid
+func (k *Keyring) SigningKey() (*rsa.PrivateKey, string, error) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	if k.active == nil {
+		return nil, "", fmt.Errorf("No active signing key")
+	}
+	return k.privateKey, k.active.ID, nil
+}
+
+//PublicKey returns the public key with the kid, an empty kid stands for the active key
+func (k *Keyring) PublicKey(kid string) (*rsa.PublicKey, error) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	if k.active != nil && (kid == "" || kid == k.active.ID) {
+		return k.active.PublicKey, nil
+	}
+	now := time.Now()
+	for _, key := range k.retired {
+		if key.ID == kid && now.Before(key.ExpiresAt) {
+			return key.PublicKey, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown signing key %v", kid)
+}
+
+//PublicKeys returns the active key followed by the retired keys still in their grace period
+func (k *Keyring) PublicKeys() []SigningKey {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	var keys []SigningKey
+	if k.active != nil {
+		keys = append(keys, *k.active)
+	}
+	now := time.Now()
+	for _, key := range k.retired {
+		if now.Before(key.ExpiresAt) {
+			keys = append(keys, *key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,107 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKeyIDIsTheRFC7638Thumbprint(t *testing.T) {
+	//the RSA key example of RFC 7638 section 3.1
+	n := "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"
+	e := "AQAB"
+	expected := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"
+
+	nBytes, _ := base64.RawURLEncoding.DecodeString(n)
+	eBytes, _ := base64.RawURLEncoding.DecodeString(e)
+	publicKey := &rsa.PublicKey{N: new(big.Int).SetBytes(nBytes), E: int(new(big.Int).SetBytes(eBytes).Int64())}
+	if encodedN, encodedE := EncodeRSAPublicKey(publicKey); encodedN != n || encodedE != e {
+		t.Fatalf("Unexpected encoded key %v %v", encodedN, encodedE)
+	}
+	if kid := KeyID(publicKey); kid != expected {
+		t.Fatalf("Unexpected thumbprint %v, expected %v", kid, expected)
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	keyring := NewKeyring(time.Hour)
+	if _, _, err := keyring.SigningKey(); err == nil {
+		t.Fatal("Expected no signing key in an empty keyring")
+	}
+
+	first := newRSAKey(t)
+	firstID := keyring.SetActiveKey(first)
+	if firstID != KeyID(&first.PublicKey) {
+		t.Fatalf("Unexpected kid %v", firstID)
+	}
+	if again := keyring.SetActiveKey(first); again != firstID || len(keyring.PublicKeys()) != 1 {
+		t.Fatal("Setting the active key again does not retire it")
+	}
+
+	second := newRSAKey(t)
+	secondID := keyring.SetActiveKey(second)
+	signingKey, kid, err := keyring.SigningKey()
+	if err != nil || signingKey != second || kid != secondID {
+		t.Fatalf("Expected the second key to sign, got %v %v", kid, err)
+	}
+
+	//the retired key still verifies during the grace period, an empty kid is the active key
+	for kid, expected := range map[string]*rsa.PublicKey{
+		firstID:  &first.PublicKey,
+		secondID: &second.PublicKey,
+		"":       &second.PublicKey,
+	} {
+		publicKey, err := keyring.PublicKey(kid)
+		if err != nil || publicKey != expected {
+			t.Errorf("Unexpected public key for kid %q, %v", kid, err)
+		}
+	}
+	keys := keyring.PublicKeys()
+	if len(keys) != 2 || keys[0].ID != secondID || keys[1].ID != firstID || keys[1].ExpiresAt.IsZero() {
+		t.Fatalf("Unexpected public keys %v", keys)
+	}
+	if _, err := keyring.PublicKey("unknown"); err == nil {
+		t.Fatal("Expected the unknown kid to be rejected")
+	}
+}
+
+func TestKeyringDropsTheRetiredKeysAfterTheGracePeriod(t *testing.T) {
+	keyring := NewKeyring(-time.Second)
+	keyring.SetActiveKey(newRSAKey(t))
+	retired := newRSAKey(t)
+	retiredID := keyring.AddRetiredKey(&retired.PublicKey)
+
+	if _, err := keyring.PublicKey(retiredID); err == nil {
+		t.Fatal("Expected the key past its grace period to be rejected")
+	}
+	if keys := keyring.PublicKeys(); len(keys) != 1 {
+		t.Fatalf("Expected only the active key, got %v", keys)
+	}
+}
+
+func TestKeyringAddRetiredKey(t *testing.T) {
+	keyring := NewKeyring(time.Hour)
+	active := newRSAKey(t)
+	activeID := keyring.SetActiveKey(active)
+
+	//the active key is not retired when it is also listed in the retired keys
+	if kid := keyring.AddRetiredKey(&active.PublicKey); kid != activeID || len(keyring.PublicKeys()) != 1 {
+		t.Fatal("Expected the active key not to be retired")
+	}
+	retired := newRSAKey(t)
+	retiredID := keyring.AddRetiredKey(&retired.PublicKey)
+	if publicKey, err := keyring.PublicKey(retiredID); err != nil || publicKey != &retired.PublicKey {
+		t.Fatalf("Expected the retired key to verify, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package util
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+//NewSealer returns the AES-GCM cipher sealing values with the 32 bytes key
+func NewSealer(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("The encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+//SealString encrypts the value and returns the base64url encoded nonce and ciphertext
+func SealString(sealer cipher.AEAD, value string) (string, error) {
+	nonce := make([]byte, sealer.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := sealer.Seal(nonce, nonce, []byte(value), nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+//OpenString decrypts a value sealed by SealString
+func OpenString(sealer cipher.AEAD, sealed string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < sealer.NonceSize() {
+		return "", fmt.Errorf("The sealed value is too short")
+	}
+	nonce := data[:sealer.NonceSize()]
+	value, err := sealer.Open(nil, nonce, data[sealer.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("Failed to decrypt the sealed value")
+	}
+	return string(value), nil
+}
+
+//DeriveKey derives a 32 bytes key for the purpose named by the label from the RSA private key
+func DeriveKey(privateKey *rsa.PrivateKey, label string) []byte {
+	hash := sha256.New()
+	hash.Write([]byte(label))
+	hash.Write(privateKey.D.Bytes())
+	return hash.Sum(nil)
+}
+
+//ReadSecretKey reads a 32 bytes key file, holding either the raw bytes or their base64 encoding
+func ReadSecretKey(filePath string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParseSecretKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("The key file %v %v", filePath, err)
+	}
+	return key, nil
+}
+
+//ParseSecretKey returns the 32 bytes key from the raw bytes or their base64 encoding
+func ParseSecretKey(data []byte) ([]byte, error) {
+	if len(data) == 32 {
+		return data, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("must hold 32 bytes or their base64 encoding")
+	}
+	return key, nil
+}
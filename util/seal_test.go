@@ -0,0 +1,134 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestKey(t *testing.T) []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestSealAndOpenString(t *testing.T) {
+	sealer, err := NewSealer(newTestKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := SealString(sealer, "access token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := SealString(sealer, "access token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatal("Expected a fresh nonce for each sealed value")
+	}
+	for _, sealed := range []string{first, second} {
+		value, err := OpenString(sealer, sealed)
+		if err != nil || value != "access token" {
+			t.Fatalf("Unexpected opened value %q, %v", value, err)
+		}
+	}
+}
+
+func TestOpenStringRejectsTamperedValues(t *testing.T) {
+	sealer, err := NewSealer(newTestKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSealer, err := NewSealer(newTestKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := SealString(sealer, "access token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := base64.RawURLEncoding.DecodeString(sealed)
+	data[len(data)-1] ^= 1
+	flipped := base64.RawURLEncoding.EncodeToString(data)
+
+	if _, err := OpenString(otherSealer, sealed); err == nil {
+		t.Error("Expected the value sealed with another key to be rejected")
+	}
+	for _, value := range []string{flipped, "", "c2hvcnQ", "not base64!"} {
+		if _, err := OpenString(sealer, value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestNewSealerRequires32Bytes(t *testing.T) {
+	for _, size := range []int{0, 16, 31, 33} {
+		if _, err := NewSealer(make([]byte, size)); err == nil {
+			t.Errorf("Expected a %d bytes key to be rejected", size)
+		}
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := DeriveKey(privateKey, "label")
+	if len(first) != 32 {
+		t.Fatalf("Expected a 32 bytes key, got %d", len(first))
+	}
+	if string(first) != string(DeriveKey(privateKey, "label")) {
+		t.Error("Expected the same key for the same private key and label")
+	}
+	if string(first) == string(DeriveKey(privateKey, "other label")) {
+		t.Error("Expected another key for another label")
+	}
+	if string(first) == string(DeriveKey(otherKey, "label")) {
+		t.Error("Expected another key for another private key")
+	}
+}
+
+func TestReadSecretKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secretkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key := newTestKey(t)
+
+	for name, data := range map[string][]byte{
+		"raw":    key,
+		"base64": []byte(base64.StdEncoding.EncodeToString(key) + "\n"),
+	} {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		read, err := ReadSecretKey(path)
+		if err != nil || string(read) != string(key) {
+			t.Errorf("Unexpected %v key %v, %v", name, read, err)
+		}
+	}
+
+	for _, data := range [][]byte{[]byte("short"), []byte(base64.StdEncoding.EncodeToString(key[:16]))} {
+		if _, err := ParseSecretKey(data); err == nil {
+			t.Errorf("Expected %q to be rejected", data)
+		}
+	}
+	if _, err := ReadSecretKey(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected the missing file to be rejected")
+	}
+}
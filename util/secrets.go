@@ -0,0 +1,86 @@
+package util
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+//SecretMask replaces the value of a secret field in the API responses
+const SecretMask = "********"
+
+//RedactSecrets masks the non empty string fields tagged secret:"true" of the struct pointed to, nested structs included
+func RedactSecrets(v interface{}) {
+	walkSecrets(reflect.ValueOf(v).Elem(), func(field reflect.Value) {
+		if field.String() != "" {
+			field.SetString(SecretMask)
+		}
+	})
+}
+
+//PreserveSecrets keeps the secret fields of current where the update omits them or sends them back masked,
+//both point to structs of the same type. The secrets of a struct are not kept when one of its fields tagged
+//endpoint:"true" changes, so that they are never sent to a server they were not configured for, the update
+//is then refused unless it sends again each secret that is stored
+func PreserveSecrets(update interface{}, current interface{}) error {
+	return preserveSecrets(reflect.ValueOf(update).Elem(), reflect.ValueOf(current).Elem())
+}
+
+func preserveSecrets(value reflect.Value, current reflect.Value) error {
+	keep := !endpointChanged(value, current)
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		switch {
+		case field.Kind() == reflect.Struct:
+			if err := preserveSecrets(field, current.Field(i)); err != nil {
+				return err
+			}
+		case field.Kind() == reflect.String && value.Type().Field(i).Tag.Get("secret") == "true" && field.CanSet():
+			if field.String() != "" && field.String() != SecretMask {
+				continue
+			}
+			if keep {
+				field.SetString(current.Field(i).String())
+				continue
+			}
+			//an empty secret is not written to the settings, the stored one would be kept for the new endpoint
+			if current.Field(i).String() != "" {
+				return fmt.Errorf("The secret %v is required when the endpoint changes", fieldName(value.Type().Field(i)))
+			}
+			field.SetString("")
+		}
+	}
+	return nil
+}
+
+//fieldName returns the json name of the field
+func fieldName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
+
+func endpointChanged(value reflect.Value, current reflect.Value) bool {
+	for i := 0; i < value.NumField(); i++ {
+		if value.Type().Field(i).Tag.Get("endpoint") != "true" {
+			continue
+		}
+		if !reflect.DeepEqual(value.Field(i).Interface(), current.Field(i).Interface()) {
+			return true
+		}
+	}
+	return false
+}
+
+func walkSecrets(value reflect.Value, apply func(field reflect.Value)) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		switch {
+		case field.Kind() == reflect.Struct:
+			walkSecrets(field, apply)
+		case field.Kind() == reflect.String && value.Type().Field(i).Tag.Get("secret") == "true" && field.CanSet():
+			apply(field)
+		}
+	}
+}
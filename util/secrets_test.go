@@ -0,0 +1,80 @@
+package util
+
+import (
+	"testing"
+)
+
+type testProviderConfig struct {
+	Hostname     string `json:"hostname,omitempty" endpoint:"true"`
+	ClientID     string `json:"clientId,omitempty"`
+	ClientSecret string `json:"clientSecret,omitempty" secret:"true"`
+	Password     string `json:"password,omitempty" secret:"true"`
+}
+
+type testAuthConfig struct {
+	Provider       string             `json:"provider"`
+	ProviderConfig testProviderConfig `json:"providerConfig"`
+}
+
+func storedConfig() testAuthConfig {
+	return testAuthConfig{Provider: "test", ProviderConfig: testProviderConfig{
+		Hostname:     "a.example.com",
+		ClientID:     "id",
+		ClientSecret: "stored-secret",
+	}}
+}
+
+func TestPreserveSecretsKeepsTheOmittedAndMaskedSecrets(t *testing.T) {
+	current := storedConfig()
+	for _, secret := range []string{"", SecretMask} {
+		update := storedConfig()
+		update.ProviderConfig.ClientSecret = secret
+		if err := PreserveSecrets(&update, &current); err != nil {
+			t.Fatal(err)
+		}
+		if update.ProviderConfig.ClientSecret != "stored-secret" {
+			t.Fatalf("Expected the stored secret to be kept for %q, got %q", secret, update.ProviderConfig.ClientSecret)
+		}
+	}
+
+	update := storedConfig()
+	update.ProviderConfig.ClientSecret = "new-secret"
+	if err := PreserveSecrets(&update, &current); err != nil {
+		t.Fatal(err)
+	}
+	if update.ProviderConfig.ClientSecret != "new-secret" {
+		t.Fatalf("Expected the new secret, got %q", update.ProviderConfig.ClientSecret)
+	}
+}
+
+func TestPreserveSecretsWhenTheEndpointChanges(t *testing.T) {
+	current := storedConfig()
+	for _, secret := range []string{"", SecretMask} {
+		update := storedConfig()
+		update.ProviderConfig.Hostname = "b.example.com"
+		update.ProviderConfig.ClientSecret = secret
+		if err := PreserveSecrets(&update, &current); err == nil {
+			t.Fatalf("Expected the update omitting the stored secret with %q to be refused", secret)
+		}
+	}
+
+	//the secret that was never stored is not required, a masked value is cleared
+	update := storedConfig()
+	update.ProviderConfig.Hostname = "b.example.com"
+	update.ProviderConfig.ClientSecret = "new-secret"
+	update.ProviderConfig.Password = SecretMask
+	if err := PreserveSecrets(&update, &current); err != nil {
+		t.Fatal(err)
+	}
+	if update.ProviderConfig.ClientSecret != "new-secret" || update.ProviderConfig.Password != "" {
+		t.Fatalf("Unexpected secrets %v", update.ProviderConfig)
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	config := storedConfig()
+	RedactSecrets(&config)
+	if config.ProviderConfig.ClientSecret != SecretMask || config.ProviderConfig.Password != "" || config.ProviderConfig.ClientID != "id" {
+		t.Fatalf("Unexpected redacted config %v", config.ProviderConfig)
+	}
+}
@@ -0,0 +1,136 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+//CertReloader serves the TLS certificate and client CAs read from files, they are swapped on reload
+//so that the new handshakes use the new files while the open connections are kept
+type CertReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	clientAuth   tls.ClientAuthType
+
+	mutex     sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+}
+
+//NewCertReloader loads the certificate and key files, and the CA bundle verifying the client certificates when set
+func NewCertReloader(certFile string, keyFile string, clientCAFile string, clientAuth tls.ClientAuthType) (*CertReloader, error) {
+	reloader := &CertReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+		clientAuth:   clientAuth,
+	}
+	if err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+func (r *CertReloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.clientCAFile != "" {
+		files = append(files, r.clientCAFile)
+	}
+	return files
+}
+
+//Reload reads the files again, the current certificate is kept on errors
+func (r *CertReloader) Reload() error {
+	modTimes := make(map[string]time.Time)
+	for _, path := range r.files() {
+		stat, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		modTimes[path] = stat.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	var clientCAs *x509.CertPool
+	if r.clientCAFile != "" {
+		if clientCAs, err = ReadCertPool(r.clientCAFile); err != nil {
+			return err
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	return nil
+}
+
+//ReloadIfChanged reloads the files when one of them was modified and tells if they were
+func (r *CertReloader) ReloadIfChanged() (bool, error) {
+	r.mutex.RLock()
+	changed := false
+	for path, modTime := range r.modTimes {
+		stat, err := os.Stat(path)
+		if err != nil || !stat.ModTime().Equal(modTime) {
+			changed = true
+			break
+		}
+	}
+	r.mutex.RUnlock()
+
+	if !changed {
+		return false, nil
+	}
+	return true, r.Reload()
+}
+
+//TLSConfig returns the server config picking the current certificate and client CAs on each handshake,
+//HTTP/2 is negotiated with ALPN
+func (r *CertReloader) TLSConfig() *tls.Config {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: r.clientAuth,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			r.mutex.RLock()
+			defer r.mutex.RUnlock()
+			return r.cert, nil
+		},
+	}
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mutex.RLock()
+		defer r.mutex.RUnlock()
+		return &tls.Config{
+			MinVersion:   config.MinVersion,
+			Certificates: []tls.Certificate{*r.cert},
+			ClientAuth:   r.clientAuth,
+			ClientCAs:    r.clientCAs,
+			NextProtos:   config.NextProtos,
+		}, nil
+	}
+	return config
+}
+
+//ReadCertPool reads the PEM encoded CA certificates of the bundle file
+func ReadCertPool(path string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("No PEM certificate found in %v", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,140 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//writeCert writes a self-signed certificate for 127.0.0.1 and its key, and returns the certificate
+func writeCert(t *testing.T, certFile string, keyFile string, serial int64) *x509.Certificate {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestCertReloaderServesHTTP2AndReloadedCertificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certreloader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	first := writeCert(t, certFile, keyFile, 1)
+
+	reloader, err := NewCertReloader(certFile, keyFile, "", tls.NoClientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", reloader.TLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	get := func(trusted *x509.Certificate) *http.Response {
+		pool := x509.NewCertPool()
+		pool.AddCert(trusted)
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: pool},
+			ForceAttemptHTTP2: true,
+		}}
+		resp, err := client.Get("https://" + listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := get(first)
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("Expected HTTP/2 to be negotiated, got %v", resp.Proto)
+	}
+
+	changed, err := reloader.ReloadIfChanged()
+	if err != nil || changed {
+		t.Fatalf("Expected no change, got %v %v", changed, err)
+	}
+
+	second := writeCert(t, certFile, keyFile, 2)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	changed, err = reloader.ReloadIfChanged()
+	if err != nil || !changed {
+		t.Fatalf("Expected the changed files to be reloaded, got %v %v", changed, err)
+	}
+	resp = get(second)
+	if serial := resp.TLS.PeerCertificates[0].SerialNumber.Int64(); serial != 2 {
+		t.Fatalf("Expected the reloaded certificate, got serial %v", serial)
+	}
+}
+
+func TestCertReloaderKeepsTheCertificateOnErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certreloader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeCert(t, certFile, keyFile, 1)
+
+	reloader, err := NewCertReloader(certFile, keyFile, "", tls.NoClientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloader.Reload(); err == nil {
+		t.Fatal("Expected the invalid key to fail the reload")
+	}
+	cert, err := reloader.TLSConfig().GetCertificate(nil)
+	if err != nil || cert == nil {
+		t.Fatalf("Expected the current certificate to be kept, got %v", err)
+	}
+}
@@ -2,14 +2,18 @@ package util
 
 import (
 	"crypto/rsa"
+	"encoding/json"
+	"fmt"
 	log "github.com/Sirupsen/logrus"
 	jwt "github.com/dgrijalva/jwt-go"
 	"io/ioutil"
+	"strings"
 )
 
 //CreateTokenWithPayload returns signed jwt token 
 func CreateTokenWithPayload(payload map[string]interface{}, privateKey *rsa.PrivateKey) (string, error) {
 	token := jwt.New(jwt.GetSigningMethod("RS256"))
+	token.Header["kid"] = KeyID(&privateKey.PublicKey)
 	token.Claims = payload
 	signed, err := token.SignedString(privateKey)
 	if err != nil {
@@ -19,34 +23,73 @@ func CreateTokenWithPayload(payload map[string]interface{}, privateKey *rsa.Priv
 	return signed, nil
 }
 
-//ParsePrivateKey Parses privateKey file
-func ParsePrivateKey(filePath string) *rsa.PrivateKey {
-	keyBytes, err := ioutil.ReadFile(filePath)
+//ParseTokenWithKeyring verifies the RS256 signature of the jwt token with the keyring key named by its kid header and returns its claims
+func ParseTokenWithKeyring(tokenString string, keyring *Keyring) (map[string]interface{}, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("Unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return keyring.PublicKey(kid)
+	})
 	if err != nil {
-		log.Fatal("Failed to parse private key.", err)
+		return nil, err
 	}
+	if !token.Valid {
+		return nil, fmt.Errorf("Invalid token")
+	}
+	return token.Claims, nil
+}
 
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(keyBytes)
+//ParseUnverifiedClaims decodes the claims of the jwt token without verifying it, only to tell what kind of token it is
+func ParseUnverifiedClaims(tokenString string) (map[string]interface{}, error) {
+	parts := strings.Split(tokenString, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("Not a jwt token")
+	}
+	claimBytes, err := jwt.DecodeSegment(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(claimBytes, &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
 
+//ParsePrivateKey Parses privateKey file
+func ParsePrivateKey(filePath string) *rsa.PrivateKey {
+	privateKey, err := ReadPrivateKey(filePath)
 	if err != nil {
 		log.Fatal("Failed to parse private key.", err)
 	}
-
 	return privateKey
 }
 
-//ParsePublicKey Parses publicKey file
-func ParsePublicKey(filePath string) *rsa.PublicKey {
+//ReadPrivateKey reads the PEM encoded RSA private key file
+func ReadPrivateKey(filePath string) (*rsa.PrivateKey, error) {
 	keyBytes, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		log.Fatal("Failed to parse public key.", err)
+		return nil, err
 	}
+	return jwt.ParseRSAPrivateKeyFromPEM(keyBytes)
+}
 
-	publicKey, err := jwt.ParseRSAPublicKeyFromPEM(keyBytes)
+//ParsePublicKey Parses publicKey file
+func ParsePublicKey(filePath string) *rsa.PublicKey {
+	publicKey, err := ReadPublicKey(filePath)
 	if err != nil {
 		log.Fatal("Failed to parse public key.", err)
 	}
-
 	return publicKey
+}
 
+//ReadPublicKey reads the PEM encoded RSA public key file
+func ReadPublicKey(filePath string) (*rsa.PublicKey, error) {
+	keyBytes, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return jwt.ParseRSAPublicKeyFromPEM(keyBytes)
 }